@@ -2,16 +2,26 @@
 package main
 
 import (
+	"embed"
 	"log"
+	"os"
 	"runtime"
 
 	"github.com/pcieslar/goforge/lib/boot"
 	"github.com/pcieslar/goforge/lib/env"
+	"github.com/pcieslar/goforge/lib/routes"
 
+	"github.com/pcieslar/goforge/core/email"
 	"github.com/pcieslar/goforge/core/router"
 	"github.com/pcieslar/goforge/core/server"
 )
 
+// files contains the views, static assets, and catalogs so the binary can run
+// without the source tree when Embed is set in env.json.
+//
+//go:embed view asset/static i18n
+var files embed.FS
+
 // init sets runtime settings.
 func init() {
 	// Verbose logging with file name and line number
@@ -23,13 +33,33 @@ func init() {
 
 // main loads the configuration file, registers the services, applies the
 // middleware to the router, and then starts the HTTP and HTTPS listeners.
+//
+// Run with the routes argument to list the routes instead:
+//
+//	blueprint routes [-json] [-audit] [-require acl.DisallowAnon] [-allow names]
+//	blueprint routes -openapi [-config env.json]
 func main() {
+	// List the routes without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "routes" {
+		if err := routes.Run(os.Stdout, os.Args[2:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
 	// Load the configuration file
 	config, err := env.LoadConfig("env.json")
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	// Use the views and assets compiled into the binary
+	if config.Embed {
+		config.View.FS = files
+		config.Asset.FS = files
+		config.I18n.FS = files
+	}
+
 	// Register the services
 	boot.RegisterServices(config)
 
@@ -42,4 +72,7 @@ func main() {
 		handler,       // HTTPS handler
 		config.Server, // Server settings
 	)
+
+	// Finish sending the emails queued by the last requests
+	email.Wait()
 }
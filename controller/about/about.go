@@ -11,7 +11,7 @@ import (
 
 // Load the routes.
 func Load() {
-	router.Get("/about", Index)
+	router.Get("/about", Index).Named("about")
 }
 
 // Index displays the About page.
@@ -0,0 +1,74 @@
+// Package admin displays the pages for the users in the Admins list of the
+// config.
+package admin
+
+import (
+	"net/http"
+
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/middleware/acl"
+	"github.com/pcieslar/goforge/model/audit"
+
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// auditLimit is the number of events shown on the lockout page.
+const auditLimit = 50
+
+// Load the routes.
+func Load() {
+	g := router.Group("/admin", acl.DisallowAnon, acl.DisallowNonAdmin)
+	g.Get("/lockout", LockoutIndex).Named("admin.lockout")
+	g.Post("/lockout/unlock", LockoutUnlock).Named("admin.lockout.unlock")
+}
+
+// LockoutIndex displays the locked accounts and IP addresses with the latest
+// events from the audit log.
+func LockoutIndex(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	locked, err := c.Config.Lockout.Locked()
+	if err != nil {
+		c.FlashErrorGeneric(err)
+	}
+
+	events, err := audit.Recent(c.GORM, auditLimit)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+	}
+
+	v := c.View.New("admin/lockout")
+	v.Vars["locked"] = locked
+	v.Vars["events"] = events
+	v.Render(w, r)
+}
+
+// LockoutUnlock removes the lockout of an account or IP address and writes
+// it to the audit log.
+func LockoutUnlock(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	// Validate with required fields
+	if !c.FormValid("key") {
+		http.Redirect(w, r, "/admin/lockout", http.StatusFound)
+		return
+	}
+
+	key := r.FormValue("key")
+
+	if err := c.Config.Lockout.Unlock(key); err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/admin/lockout", http.StatusFound)
+		return
+	}
+
+	email, _ := c.Sess.Values["email"].(string)
+	if err := audit.Create(c.GORM, audit.EventUnlock, key, email, c.IP()); err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/admin/lockout", http.StatusFound)
+		return
+	}
+
+	c.FlashSuccess("Unlocked: %v", key)
+	http.Redirect(w, r, "/admin/lockout", http.StatusFound)
+}
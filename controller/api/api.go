@@ -0,0 +1,101 @@
+// Package api provides the versioned JSON API. Requests are authenticated
+// with bearer tokens instead of the session so the CSRF check is skipped.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pcieslar/goforge/controller/status"
+	"github.com/pcieslar/goforge/middleware/bearer"
+	"github.com/pcieslar/goforge/middleware/transaction"
+
+	"github.com/pcieslar/goforge/core/openapi"
+	"github.com/pcieslar/goforge/core/router"
+)
+
+var (
+	// Prefix is the path of every API route.
+	Prefix = "/api/v1"
+
+	// maxBodyBytes is the largest request body that is decoded.
+	maxBodyBytes int64 = 1 << 20
+
+	// errorSchema describes the JSON error that status.ErrorBody wraps in an
+	// error object.
+	errorSchema = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"error": {
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"status":  {Type: "integer", Format: "int32"},
+					"title":   {Type: "string"},
+					"message": {Type: "string"},
+				},
+				Required: []string{"status", "title", "message"},
+			},
+		},
+		Required: []string{"error"},
+	}
+)
+
+// Load the routes.
+func Load() {
+	openapi.RegisterSchema(status.ErrorBody{}, errorSchema)
+
+	g := router.Group(Prefix)
+	g.Post("/tokens", TokenStore).Named("api.tokens.store").
+		Describe("Issue a token with an email, password, and the two-factor code if it is enabled").
+		Accepts(TokenInput{}).
+		Returns(http.StatusCreated, Token{}).
+		Returns(http.StatusUnauthorized, status.ErrorBody{}).
+		Returns(http.StatusForbidden, status.ErrorBody{}).
+		Returns(http.StatusTooManyRequests, status.ErrorBody{})
+	g.Delete("/tokens", TokenDestroy, bearer.Handler).Named("api.tokens.destroy").
+		Describe("Revoke the token used to make the request").
+		Returns(http.StatusNoContent, nil).
+		Returns(http.StatusUnauthorized, status.ErrorBody{})
+
+	n := g.Group("/notes", bearer.Handler)
+	n.Get("", NoteIndex).Named("api.notes.index").
+		Describe("List a page of notes").
+		Returns(http.StatusOK, NoteList{}).
+		Returns(http.StatusUnauthorized, status.ErrorBody{})
+	n.Post("", NoteStore, transaction.Handler).Named("api.notes.store").
+		Describe("Create a note").
+		Accepts(NoteInput{}).
+		Returns(http.StatusCreated, Note{}).
+		Returns(http.StatusUnprocessableEntity, status.ErrorBody{})
+	n.Get("/:id<int>", NoteShow).Named("api.notes.show").
+		Describe("Get a note").
+		Returns(http.StatusOK, Note{}).
+		Returns(http.StatusNotFound, status.ErrorBody{})
+	n.Patch("/:id<int>", NoteUpdate, transaction.Handler).Named("api.notes.update").
+		Describe("Change the name of a note").
+		Accepts(NoteInput{}).
+		Returns(http.StatusOK, Note{}).
+		Returns(http.StatusNotFound, status.ErrorBody{}).
+		Returns(http.StatusUnprocessableEntity, status.ErrorBody{})
+	n.Delete("/:id<int>", NoteDestroy, transaction.Handler).Named("api.notes.destroy").
+		Describe("Delete a note").
+		Returns(http.StatusNoContent, nil).
+		Returns(http.StatusNotFound, status.ErrorBody{})
+}
+
+// decode reads the JSON request body into v. It writes the error and returns
+// false if the body cannot be decoded.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(v)
+	if err != nil {
+		status.WriteError(w, http.StatusBadRequest, "Request body must be valid JSON.")
+		return false
+	}
+
+	return true
+}
+
+// serverError writes the error for a failed database call.
+func serverError(w http.ResponseWriter) {
+	status.WriteError(w, http.StatusInternalServerError, "An internal server error occurred.")
+}
@@ -0,0 +1,158 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/controller/api"
+	"github.com/pcieslar/goforge/lib/boot"
+	"github.com/pcieslar/goforge/lib/env"
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/lib/gorm"
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/sqlite"
+	"github.com/pcieslar/goforge/model/user"
+
+	"github.com/pcieslar/goforge/core/passhash"
+	"github.com/pcieslar/goforge/core/router"
+	"github.com/pcieslar/goforge/core/xsrf"
+)
+
+// setup returns the API behind the middleware of every request, including
+// the CSRF check, and a user with the password "secret".
+func setup(t *testing.T) http.Handler {
+	db, err := gorm.Open("sqlite3", t.TempDir()+"/test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, q := range []string{
+		`CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, first_name TEXT, last_name TEXT, email TEXT, password TEXT, status_id INT, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`,
+		`CREATE TABLE api_token (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, token_hash TEXT UNIQUE, user_id INT, expires_at TIMESTAMP, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`,
+		`CREATE TABLE note (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, user_id INT, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`,
+		`CREATE TABLE two_factor (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INT UNIQUE, secret TEXT, last_counter INT NOT NULL DEFAULT 0, enabled_at TIMESTAMP, created_at TIMESTAMP, updated_at TIMESTAMP)`,
+		`CREATE TABLE audit_log (id INTEGER PRIMARY KEY AUTOINCREMENT, event TEXT, subject TEXT, actor TEXT, ip TEXT, created_at TIMESTAMP)`,
+	} {
+		if err := db.Exec(q).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := passhash.HashString("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.Create(db, "John", "Doe", "jdoe@domain.com", hash); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := env.LoadConfig("../../env.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Session.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Allow the next attempt right after a failure
+	config.Lockout.Delay = 0
+	if err := config.Lockout.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	flight.StoreConfig(*config)
+	flight.StoreGORM(db)
+	flight.StoreXsrf(xsrf.Info{AuthKey: config.Session.CSRFKey})
+	t.Cleanup(flight.Reset)
+
+	router.ResetConfig()
+	api.Load()
+
+	return boot.SetUpMiddleware(router.Instance())
+}
+
+// request sends the JSON body with the bearer token if there is one.
+func request(h http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/json")
+	if len(token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// TestCSRF ensures the API is not checked for a CSRF token while the other
+// routes still are.
+func TestCSRF(t *testing.T) {
+	h := setup(t)
+
+	if w := request(h, "POST", "/login", `{}`, ""); w.Code != http.StatusForbidden {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Code, http.StatusForbidden)
+	}
+
+	w := request(h, "POST", api.Prefix+"/tokens", `{"email":"jdoe@domain.com","password":"secret"}`, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("\nactual: %v\nexpected: %v\n%v", w.Code, http.StatusCreated, w.Body.String())
+	}
+
+	var token api.Token
+	if err := json.Unmarshal(w.Body.Bytes(), &token); err != nil {
+		t.Fatal(err)
+	}
+	if len(token.Token) == 0 {
+		t.Fatal("token is missing")
+	}
+	if !token.ExpiresAt.After(time.Now()) {
+		t.Errorf("\nactual: %v\nexpected: after %v", token.ExpiresAt, time.Now())
+	}
+
+	if w := request(h, "POST", api.Prefix+"/notes", `{"name":"First"}`, token.Token); w.Code != http.StatusCreated {
+		t.Errorf("\nactual: %v\nexpected: %v\n%v", w.Code, http.StatusCreated, w.Body.String())
+	}
+}
+
+// TestTokens ensures a token is only issued for the right password and stops
+// working when it is revoked.
+func TestTokens(t *testing.T) {
+	h := setup(t)
+
+	if w := request(h, "GET", api.Prefix+"/notes", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("missing: \nactual: %v\nexpected: %v", w.Code, http.StatusUnauthorized)
+	}
+	if w := request(h, "GET", api.Prefix+"/notes", "", "not-a-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("bad: \nactual: %v\nexpected: %v", w.Code, http.StatusUnauthorized)
+	}
+
+	w := request(h, "POST", api.Prefix+"/tokens", `{"email":"jdoe@domain.com","password":"wrong"}`, "")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("password: \nactual: %v\nexpected: %v", w.Code, http.StatusUnauthorized)
+	}
+
+	w = request(h, "POST", api.Prefix+"/tokens", `{"email":"jdoe@domain.com","password":"secret"}`, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("\nactual: %v\nexpected: %v\n%v", w.Code, http.StatusCreated, w.Body.String())
+	}
+
+	var token api.Token
+	if err := json.Unmarshal(w.Body.Bytes(), &token); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := request(h, "GET", api.Prefix+"/notes", "", token.Token); w.Code != http.StatusOK {
+		t.Errorf("valid: \nactual: %v\nexpected: %v", w.Code, http.StatusOK)
+	}
+
+	if w := request(h, "DELETE", api.Prefix+"/tokens", "", token.Token); w.Code != http.StatusNoContent {
+		t.Errorf("revoke: \nactual: %v\nexpected: %v", w.Code, http.StatusNoContent)
+	}
+	if w := request(h, "GET", api.Prefix+"/notes", "", token.Token); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked: \nactual: %v\nexpected: %v", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pcieslar/goforge/controller/status"
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/note"
+
+	"github.com/pcieslar/goforge/core/pagination"
+	"github.com/pcieslar/goforge/core/view"
+)
+
+const (
+	// defaultPerPage is the number of notes on a page.
+	defaultPerPage = 10
+	// maxPerPage is the largest per_page value allowed.
+	maxPerPage = 100
+)
+
+// Note is the JSON representation of a note.
+type Note struct {
+	ID        uint32     `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// NoteList is a page of notes.
+type NoteList struct {
+	Data []Note `json:"data"`
+	Meta Meta   `json:"meta"`
+}
+
+// Meta describes the page of results.
+type Meta struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// NoteInput is the request body for creating and updating a note.
+type NoteInput struct {
+	Name string `json:"name"`
+}
+
+// newNote converts the model to the JSON representation.
+func newNote(n note.Note) Note {
+	item := Note{
+		ID:   n.ID,
+		Name: n.Name,
+	}
+
+	if n.CreatedAt.Valid {
+		item.CreatedAt = &n.CreatedAt.Time
+	}
+	if n.UpdatedAt.Valid {
+		item.UpdatedAt = &n.UpdatedAt.Time
+	}
+
+	return item
+}
+
+// NoteIndex returns a page of the user's notes.
+func NoteIndex(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	} else if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	p := pagination.New(r, perPage)
+
+	items, _, err := note.ByUserIDPaginate(c.GORM, c.UserID, p.PerPage, p.Offset)
+	if err != nil && err != model.ErrNoResult {
+		log.Println(err)
+		serverError(w)
+		return
+	}
+
+	count, err := note.ByUserIDCount(c.GORM, c.UserID)
+	if err != nil {
+		log.Println(err)
+		serverError(w)
+		return
+	}
+
+	p.CalculatePages(count)
+
+	list := NoteList{
+		Data: make([]Note, 0, len(items)),
+		Meta: Meta{
+			Page:       p.Page,
+			PerPage:    p.PerPage,
+			Total:      count,
+			TotalPages: p.TotalPages,
+		},
+	}
+	for _, n := range items {
+		list.Data = append(list.Data, newNote(n))
+	}
+
+	view.JSON(w, http.StatusOK, list)
+}
+
+// NoteShow returns a single note.
+func NoteShow(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	item, ok := findNote(w, &c)
+	if !ok {
+		return
+	}
+
+	view.JSON(w, http.StatusOK, newNote(item))
+}
+
+// NoteStore creates a note.
+func NoteStore(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	var input NoteInput
+	if !decode(w, r, &input) || !validNote(w, input) {
+		return
+	}
+
+	item, err := note.Create(c.GORM, input.Name, c.UserID)
+	if err != nil {
+		log.Println(err)
+		serverError(w)
+		return
+	}
+
+	// Read the note back for the timestamps set by the database
+	item, _, err = note.ByID(c.GORM, int(item.ID), c.UserID)
+	if err != nil {
+		log.Println(err)
+		serverError(w)
+		return
+	}
+
+	view.JSON(w, http.StatusCreated, newNote(item))
+}
+
+// NoteUpdate changes the name of a note.
+func NoteUpdate(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	var input NoteInput
+	if !decode(w, r, &input) || !validNote(w, input) {
+		return
+	}
+
+	if _, ok := findNote(w, &c); !ok {
+		return
+	}
+
+	err := note.Update(c.GORM, input.Name, c.ParamInt("id"), c.UserID)
+	if err != nil {
+		log.Println(err)
+		serverError(w)
+		return
+	}
+
+	item, ok := findNote(w, &c)
+	if !ok {
+		return
+	}
+
+	view.JSON(w, http.StatusOK, newNote(item))
+}
+
+// NoteDestroy soft deletes a note.
+func NoteDestroy(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	if _, ok := findNote(w, &c); !ok {
+		return
+	}
+
+	err := note.DeleteSoft(c.GORM, c.ParamInt("id"), c.UserID)
+	if err != nil {
+		log.Println(err)
+		serverError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findNote returns the note from the URL that belongs to the user. It writes
+// the error and returns false if the note cannot be found.
+func findNote(w http.ResponseWriter, c *flight.Info) (note.Note, bool) {
+	item, missing, err := note.ByID(c.GORM, c.ParamInt("id"), c.UserID)
+	if missing {
+		status.WriteError(w, http.StatusNotFound, "Note could not be found.")
+		return item, false
+	} else if err != nil {
+		log.Println(err)
+		serverError(w)
+		return item, false
+	}
+
+	return item, true
+}
+
+// validNote writes the error and returns false if the input is not valid.
+func validNote(w http.ResponseWriter, input NoteInput) bool {
+	if len(strings.TrimSpace(input.Name)) == 0 {
+		status.WriteError(w, http.StatusUnprocessableEntity, "Field missing: name")
+		return false
+	}
+
+	return true
+}
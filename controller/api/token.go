@@ -0,0 +1,186 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pcieslar/goforge/controller/status"
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/middleware/bearer"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/apitoken"
+	"github.com/pcieslar/goforge/model/audit"
+	"github.com/pcieslar/goforge/model/twofactor"
+	"github.com/pcieslar/goforge/model/user"
+	"github.com/pcieslar/goforge/model/userstatus"
+
+	"github.com/pcieslar/goforge/core/lockout"
+	"github.com/pcieslar/goforge/core/passhash"
+	"github.com/pcieslar/goforge/core/totp"
+	"github.com/pcieslar/goforge/core/view"
+)
+
+// tokenLifetime is how long an issued token can be used.
+const tokenLifetime = 30 * 24 * time.Hour
+
+// Token is the JSON representation of a newly issued token.
+type Token struct {
+	ID        uint32    `json:"id"`
+	Name      string    `json:"name"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"` // Time the token can no longer be used
+}
+
+// TokenInput is the request body for issuing a token.
+type TokenInput struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Name     string `json:"name,omitempty"`
+	Code     string `json:"code,omitempty"` // Code from the authenticator app or a recovery code if two-factor authentication is enabled
+}
+
+// TokenStore issues a token to the user with the email and password. The
+// token is only returned in this response.
+func TokenStore(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	var input TokenInput
+	if !decode(w, r, &input) {
+		return
+	}
+
+	if len(input.Email) == 0 {
+		status.WriteError(w, http.StatusUnprocessableEntity, "Field missing: email")
+		return
+	} else if len(input.Password) == 0 {
+		status.WriteError(w, http.StatusUnprocessableEntity, "Field missing: password")
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if len(name) == 0 {
+		name = "API"
+	}
+
+	// Slow down and then stop repeated failures for the account and address
+	keys := []string{lockout.AccountKey(input.Email), lockout.IPKey(c.IP())}
+	if wait, err := c.Config.Lockout.Check(keys...); err != nil {
+		log.Println(err)
+		serverError(w)
+		return
+	} else if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+		status.WriteError(w, http.StatusTooManyRequests, "Too many failed login attempts.")
+		return
+	}
+
+	result, err := user.ByEmail(c.GORM, input.Email)
+	if err != nil && err != model.ErrNoResult {
+		release(&c, keys)
+		log.Println(err)
+		serverError(w)
+		return
+	}
+
+	if err == model.ErrNoResult || !passhash.MatchString(result.Password, input.Password) {
+		fail(&c, keys)
+		status.WriteError(w, http.StatusUnauthorized, "Email or password is incorrect.")
+		return
+	}
+
+	if result.StatusID != userstatus.Active {
+		release(&c, keys)
+		status.WriteError(w, http.StatusForbidden, "Account is inactive so login is disabled.")
+		return
+	}
+
+	// Require the code from the authenticator app like the login page
+	enabled, err := twofactor.Enabled(c.GORM, result.ID)
+	if err != nil {
+		release(&c, keys)
+		log.Println(err)
+		serverError(w)
+		return
+	} else if enabled {
+		if len(input.Code) == 0 {
+			release(&c, keys)
+			status.WriteError(w, http.StatusUnauthorized, "Two-factor code is required.")
+			return
+		}
+
+		_, err := twofactor.Authenticate(c.GORM, c.Config.TOTP, result.ID, input.Code, time.Now())
+		if err == totp.ErrInvalid {
+			fail(&c, keys)
+			status.WriteError(w, http.StatusUnauthorized, "Two-factor code is not valid.")
+			return
+		} else if err != nil {
+			release(&c, keys)
+			log.Println(err)
+			serverError(w)
+			return
+		}
+	}
+
+	release(&c, keys)
+	if err := c.Config.Lockout.Succeed(keys[0]); err != nil {
+		log.Println(err)
+	}
+
+	token, item, err := apitoken.Create(c.GORM, name, result.ID, tokenLifetime)
+	if err != nil {
+		log.Println(err)
+		serverError(w)
+		return
+	}
+
+	view.JSON(w, http.StatusCreated, Token{
+		ID:        item.ID,
+		Name:      item.Name,
+		Token:     token,
+		ExpiresAt: item.ExpiresAt,
+	})
+}
+
+// release takes back the login attempt that did not fail.
+func release(c *flight.Info, keys []string) {
+	if err := c.Config.Lockout.Release(keys...); err != nil {
+		log.Println(err)
+	}
+}
+
+// fail counts the failed login and writes the lockouts it causes to the
+// audit log.
+func fail(c *flight.Info, keys []string) {
+	locked, err := c.Config.Lockout.Fail(keys...)
+	if err != nil {
+		log.Println(err)
+	}
+
+	for _, key := range locked {
+		if err := audit.Create(c.GORM, audit.EventLockout, key, "", c.IP()); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// TokenDestroy revokes the token used to make the request.
+func TokenDestroy(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	item, ok := bearer.Token(r)
+	if !ok {
+		status.WriteError(w, http.StatusUnauthorized, "Bearer token is missing.")
+		return
+	}
+
+	if err := apitoken.DeleteSoft(c.GORM, item.ID, item.UserID); err != nil {
+		log.Println(err)
+		serverError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,23 +3,35 @@ package controller
 
 import (
 	"github.com/pcieslar/goforge/controller/about"
+	"github.com/pcieslar/goforge/controller/admin"
+	"github.com/pcieslar/goforge/controller/api"
 	"github.com/pcieslar/goforge/controller/debug"
+	"github.com/pcieslar/goforge/controller/external"
 	"github.com/pcieslar/goforge/controller/home"
+	"github.com/pcieslar/goforge/controller/locale"
 	"github.com/pcieslar/goforge/controller/login"
 	"github.com/pcieslar/goforge/controller/notepad"
+	"github.com/pcieslar/goforge/controller/password"
 	"github.com/pcieslar/goforge/controller/register"
 	"github.com/pcieslar/goforge/controller/static"
 	"github.com/pcieslar/goforge/controller/status"
+	"github.com/pcieslar/goforge/controller/twofactor"
 )
 
 // LoadRoutes loads the routes for each of the controllers.
 func LoadRoutes() {
 	about.Load()
+	admin.Load()
 	debug.Load()
 	register.Load()
 	login.Load()
+	twofactor.Load()
+	external.Load()
+	password.Load()
 	home.Load()
+	locale.Load()
 	static.Load()
 	status.Load()
 	notepad.Load()
+	api.Load()
 }
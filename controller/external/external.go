@@ -0,0 +1,325 @@
+// Package external logs in users with the OpenID Connect providers in the
+// config and links the providers to the accounts.
+package external
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/pcieslar/goforge/controller/login"
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/middleware/acl"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/identity"
+	"github.com/pcieslar/goforge/model/user"
+	"github.com/pcieslar/goforge/model/userstatus"
+
+	"github.com/pcieslar/goforge/core/oidc"
+	"github.com/pcieslar/goforge/core/router"
+)
+
+var (
+	uri = "/external"
+)
+
+// Load the routes.
+func Load() {
+	router.Get("/login/external/:provider", Login, acl.DisallowAuth).Named("external.login")
+	router.Get("/login/external/:provider/callback", Callback).Named("external.callback")
+
+	g := router.Group(uri, acl.DisallowAnon)
+	g.Get("", Index).Named("external.index")
+	g.Post("/:provider/link", Link).Named("external.link")
+	g.Post("/:provider/unlink", Unlink).Named("external.unlink")
+}
+
+// providerLink is a provider and whether the user linked it.
+type providerLink struct {
+	Key    string
+	Name   string
+	Linked bool
+}
+
+// userID returns the ID of the logged in user.
+func userID(c *flight.Info) (uint32, error) {
+	id, err := strconv.ParseUint(c.UserID, 10, 32)
+	return uint32(id), err
+}
+
+// Index displays the providers and whether they are linked.
+func Index(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	id, err := userID(&c)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	items, err := identity.ByUserID(c.GORM, id)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	linked := make(map[string]bool)
+	for _, item := range items {
+		linked[item.Provider] = true
+	}
+
+	var providers []providerLink
+	for _, p := range c.Config.OIDC.Providers {
+		providers = append(providers, providerLink{
+			Key:    p.Key,
+			Name:   p.Name,
+			Linked: linked[p.Key],
+		})
+	}
+
+	v := c.View.New("external/index")
+	v.Vars["providers"] = providers
+	v.Render(w, r)
+}
+
+// Login sends the user to the provider to log in.
+func Login(w http.ResponseWriter, r *http.Request) {
+	begin(w, r, false)
+}
+
+// Link sends the user to the provider to link it to the account.
+func Link(w http.ResponseWriter, r *http.Request) {
+	begin(w, r, true)
+}
+
+// begin keeps the values of the login in the session and redirects to the
+// provider.
+func begin(w http.ResponseWriter, r *http.Request, link bool) {
+	c := flight.Context(w, r)
+
+	back := "/login"
+	if link {
+		back = uri
+	}
+
+	req, err := oidc.NewRequest(c.Param("provider"))
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, back, http.StatusFound)
+		return
+	}
+
+	redirectURI, err := c.PublicURL("external.callback", "provider", req.Provider)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, back, http.StatusFound)
+		return
+	}
+
+	u, err := c.Config.OIDC.AuthURL(req, redirectURI)
+	if err == oidc.ErrProvider {
+		c.FlashWarning("Login provider is not available.")
+		http.Redirect(w, r, back, http.StatusFound)
+		return
+	} else if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, back, http.StatusFound)
+		return
+	}
+
+	c.Sess.Values["external.provider"] = req.Provider
+	c.Sess.Values["external.state"] = req.State
+	c.Sess.Values["external.nonce"] = req.Nonce
+	c.Sess.Values["external.verifier"] = req.Verifier
+	if link {
+		c.Sess.Values["external.link"] = c.UserID
+	}
+	c.Sess.Save(r, w)
+
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+// Callback handles the redirect back from the provider. It logs in the user
+// linked to the subject or links the subject to the logged in user.
+func Callback(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	// The values of the login can only be used once. Every response below
+	// saves the session.
+	req := oidc.Request{}
+	req.Provider, _ = c.Sess.Values["external.provider"].(string)
+	req.State, _ = c.Sess.Values["external.state"].(string)
+	req.Nonce, _ = c.Sess.Values["external.nonce"].(string)
+	req.Verifier, _ = c.Sess.Values["external.verifier"].(string)
+	linkID, _ := c.Sess.Values["external.link"].(string)
+	for _, key := range []string{"external.provider", "external.state", "external.nonce", "external.verifier", "external.link"} {
+		delete(c.Sess.Values, key)
+	}
+
+	back := "/login"
+	if len(linkID) > 0 {
+		back = uri
+	}
+
+	// The state ties the callback to the browser that started the login
+	state := r.FormValue("state")
+	if req.Provider != c.Param("provider") || len(req.State) == 0 ||
+		subtle.ConstantTimeCompare([]byte(req.State), []byte(state)) != 1 {
+		c.FlashWarning("The login with the provider could not be completed. Please try again.")
+		http.Redirect(w, r, back, http.StatusFound)
+		return
+	}
+
+	p, err := c.Config.OIDC.Provider(req.Provider)
+	if err != nil {
+		c.FlashWarning("Login provider is not available.")
+		http.Redirect(w, r, back, http.StatusFound)
+		return
+	}
+
+	// The user cancelled or the provider refused the login
+	if len(r.FormValue("error")) > 0 {
+		c.FlashNotice("The login with %v was cancelled.", p.Name)
+		http.Redirect(w, r, back, http.StatusFound)
+		return
+	}
+
+	redirectURI, err := c.PublicURL("external.callback", "provider", req.Provider)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, back, http.StatusFound)
+		return
+	}
+
+	claims, err := c.Config.OIDC.Exchange(req, redirectURI, r.FormValue("code"))
+	if err != nil {
+		log.Println("External login:", err)
+		c.FlashWarning("The login with the provider could not be completed. Please try again.")
+		http.Redirect(w, r, back, http.StatusFound)
+		return
+	}
+
+	if len(linkID) > 0 {
+		link(&c, w, r, p, claims, linkID)
+		return
+	}
+
+	logIn(&c, w, r, p, claims)
+}
+
+// link links the subject to the user who started the link.
+func link(c *flight.Info, w http.ResponseWriter, r *http.Request, p oidc.Provider, claims oidc.Claims, linkID string) {
+	// The user logged out or another user logged in since the link started
+	if linkID != c.UserID {
+		c.FlashWarning("The login with the provider could not be completed. Please try again.")
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	id, err := userID(c)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	item, err := identity.BySubject(c.GORM, p.Key, claims.Subject)
+	if err == nil && item.UserID == id {
+		c.FlashNotice("Your %v login is already linked.", p.Name)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	} else if err == nil {
+		c.FlashWarning("This %v login is linked to another account.", p.Name)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	} else if err != model.ErrNoResult {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	if err := identity.Create(c.GORM, id, p.Key, claims.Subject); err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	c.FlashSuccess("Your %v login is now linked.", p.Name)
+	http.Redirect(w, r, uri, http.StatusFound)
+}
+
+// logIn logs in the user linked to the subject. When the provider is trusted
+// to verify email addresses, the subject is linked to the user with the same
+// address on the first login.
+func logIn(c *flight.Info, w http.ResponseWriter, r *http.Request, p oidc.Provider, claims oidc.Claims) {
+	item, err := identity.BySubject(c.GORM, p.Key, claims.Subject)
+	if err == model.ErrNoResult && p.LinkByEmail && bool(claims.EmailVerified) && len(claims.Email) > 0 {
+		var u user.User
+		u, err = user.ByEmail(c.GORM, claims.Email)
+		if err == nil {
+			err = identity.Create(c.GORM, u.ID, p.Key, claims.Subject)
+			item.UserID = u.ID
+		}
+	}
+
+	if err == model.ErrNoResult {
+		c.FlashNotice("No account is linked to this %v login. Log in with your password and link it on the Linked Logins page.", p.Name)
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	} else if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	result, err := user.ByID(c.GORM, item.UserID)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	if result.StatusID == userstatus.Pending {
+		c.FlashWarning("Email address is not verified. Click the link in the email sent to %v or request a new one.", result.Email)
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	} else if result.StatusID != userstatus.Active {
+		c.FlashNotice("Account is inactive so login is disabled.")
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	// Login successfully or ask for the code from the authenticator app
+	login.Finish(c, w, r, result)
+}
+
+// Unlink removes the link of the provider from the account.
+func Unlink(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	id, err := userID(&c)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	p, err := c.Config.OIDC.Provider(c.Param("provider"))
+	if err != nil {
+		c.FlashWarning("Login provider is not available.")
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	if err := identity.Delete(c.GORM, id, p.Key); err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	c.FlashNotice("Your %v login is no longer linked.", p.Name)
+	http.Redirect(w, r, uri, http.StatusFound)
+}
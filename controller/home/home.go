@@ -11,7 +11,7 @@ import (
 
 // Load the routes.
 func Load() {
-	router.Get("/", Index)
+	router.Get("/", Index).Named("home")
 }
 
 // Index displays the home page.
@@ -0,0 +1,69 @@
+// Package locale changes the language of the pages.
+package locale
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/flight"
+
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// cookieAge is how long the chosen locale is remembered.
+const cookieAge = 365 * 24 * time.Hour
+
+// Load the routes.
+func Load() {
+	router.Get("/locale/:locale<[A-Za-z_-]+>", Change).Named("locale")
+}
+
+// Change stores the locale in the session and the cookie and then redirects
+// back to the page. A locale without a catalog stores the default locale.
+func Change(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	locale := c.I18n.Match(c.Param("locale"))
+
+	if name := c.Config.I18n.Cookie; len(name) > 0 {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    locale,
+			Path:     "/",
+			MaxAge:   int(cookieAge / time.Second),
+			HttpOnly: true,
+			Secure:   c.Config.Session.Options.Secure,
+		})
+	}
+
+	c.Sess.Values["locale"] = locale
+	c.Sess.Save(r, w)
+
+	c.Redirect(back(r))
+}
+
+// back returns the path of the referring page on the same host. A path that
+// starts with // or /\ is read by browsers as another host so it is refused.
+func back(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil || u.Host != r.Host {
+		return "/"
+	}
+
+	if !strings.HasPrefix(u.Path, "/") ||
+		strings.HasPrefix(u.Path, "//") ||
+		strings.HasPrefix(u.Path, "/\\") {
+		return "/"
+	}
+
+	// Keep the escaping so the browser reads the same path
+	path := u.EscapedPath()
+
+	if len(u.RawQuery) > 0 {
+		return path + "?" + u.RawQuery
+	}
+
+	return path
+}
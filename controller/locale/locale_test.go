@@ -0,0 +1,57 @@
+package locale_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pcieslar/goforge/lib/boot"
+	"github.com/pcieslar/goforge/lib/env"
+	"github.com/pcieslar/goforge/lib/flight"
+
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// TestBack ensures the redirect goes back to the referring page only when it
+// is on the same site.
+func TestBack(t *testing.T) {
+	config, err := env.LoadConfig("../../env.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Asset.Folder = "../../asset"
+	config.I18n.Folder = "../../i18n"
+	config.View.Folder = "../../view"
+	config.Session.Store = "memory"
+
+	router.ResetConfig()
+	boot.RegisterServices(config)
+	t.Cleanup(flight.Reset)
+	h := flight.Handler(router.Instance())
+
+	for _, tt := range []struct {
+		referer  string
+		expected string
+	}{
+		{"http://example.com/notepad?page=2", "/notepad?page=2"},
+		{"http://example.com/", "/"},
+		{"", "/"},
+		{"http://evil.test/notepad", "/"},
+		{"http://example.com//evil.test/x", "/"},
+		{`http://example.com/\evil.test`, "/"},
+		{"http://example.com/%2F%2Fevil.test", "/"},
+		{"http://example.com/%09/evil.test", "/%09/evil.test"},
+	} {
+		r := httptest.NewRequest("GET", "http://example.com/locale/pl", nil)
+		r.Header.Set("Referer", tt.referer)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("%v: \nactual: %v\nexpected: %v", tt.referer, w.Code, http.StatusFound)
+		}
+		if received := w.Header().Get("Location"); received != tt.expected {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", tt.referer, received, tt.expected)
+		}
+	}
+}
@@ -2,25 +2,38 @@
 package login
 
 import (
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/pcieslar/goforge/lib/flight"
 	"github.com/pcieslar/goforge/middleware/acl"
 	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/audit"
+	"github.com/pcieslar/goforge/model/twofactor"
 	"github.com/pcieslar/goforge/model/user"
+	"github.com/pcieslar/goforge/model/userstatus"
 
 	"github.com/pcieslar/goforge/core/flash"
 	"github.com/pcieslar/goforge/core/form"
+	"github.com/pcieslar/goforge/core/lockout"
 	"github.com/pcieslar/goforge/core/passhash"
 	"github.com/pcieslar/goforge/core/router"
 	"github.com/pcieslar/goforge/core/session"
+	"github.com/pcieslar/goforge/core/totp"
 )
 
+// twoFactorLifetime is how long the code can be entered after the password.
+const twoFactorLifetime = 5 * time.Minute
+
 // Load the routes.
 func Load() {
-	router.Get("/login", Index, acl.DisallowAuth)
-	router.Post("/login", Store, acl.DisallowAuth)
-	router.Get("/logout", Logout)
+	router.Get("/login", Index, acl.DisallowAuth).Named("login")
+	router.Post("/login", Store, acl.DisallowAuth).Named("login.store")
+	router.Get("/login/twofactor", TwoFactorIndex, acl.DisallowAuth).Named("login.twofactor")
+	router.Post("/login/twofactor", TwoFactorStore, acl.DisallowAuth).Named("login.twofactor.store")
+	router.Get("/logout", Logout).Named("logout")
+	router.Post("/logout/all", LogoutAll, acl.DisallowAnon).Named("logout.all")
 }
 
 // Index displays the login page.
@@ -28,6 +41,7 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	c := flight.Context(w, r)
 
 	v := c.View.New("login/index")
+	v.Vars["providers"] = c.Config.OIDC.Providers
 	form.Repopulate(r.Form, v.Vars, "email")
 	v.Render(w, r)
 }
@@ -46,36 +60,240 @@ func Store(w http.ResponseWriter, r *http.Request) {
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 
+	// Slow down and then stop repeated failures for the account and address
+	keys := []string{lockout.AccountKey(email), lockout.IPKey(c.IP())}
+	if throttled(&c, keys) {
+		Index(w, r)
+		return
+	}
+
 	// Get database result
-	result, err := user.ByEmail(email)
+	result, err := user.ByEmail(c.GORM, email)
 
 	// Determine if user exists
 	if err != nil && err != model.ErrNoResult {
 		// Display error message
+		release(&c, keys)
 		c.FlashErrorGeneric(err)
 	} else if passhash.MatchString(result.Password, password) {
-		if err == model.ErrNoResult {
+		// The code from the authenticator app is counted on its own
+		release(&c, keys)
+
+		if result.StatusID == userstatus.Pending {
+			// Email address not verified and display how to verify it
+			c.FlashWarning("Email address is not verified. Click the link in the email sent to %v or request a new one.", email)
+		} else if result.StatusID != userstatus.Active {
 			// User inactive and display inactive message
 			c.FlashNotice("Account is inactive so login is disabled.")
 		} else {
-			// Login successfully
-			session.Empty(c.Sess)
-			c.Sess.AddFlash(flash.Info{"Login successful!", flash.Success})
-			c.Sess.Values["id"] = result.ID
-			c.Sess.Values["email"] = email
-			c.Sess.Values["first_name"] = result.FirstName
-			c.Sess.Save(r, w)
-			http.Redirect(w, r, "/", http.StatusFound)
+			// Login successfully or ask for the code from the authenticator app
+			Finish(&c, w, r, result)
 			return
 		}
 	} else {
 		c.FlashWarning("Password is incorrect")
+		fail(&c, keys)
 	}
 
 	// Show the login page again
 	Index(w, r)
 }
 
+// Finish logs in the user who proved who they are with the password or an
+// external provider. The user must still enter a code from the authenticator
+// app when two-factor authentication is enabled.
+func Finish(c *flight.Info, w http.ResponseWriter, r *http.Request, u user.User) {
+	enabled, err := twofactor.Enabled(c.GORM, u.ID)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	} else if enabled {
+		session.Empty(c.Sess)
+		if err := c.Config.Session.Regenerate(c.Sess); err != nil {
+			c.FlashErrorGeneric(err)
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		c.Sess.Values["twofactor.id"] = u.ID
+		c.Sess.Values["twofactor.expires"] = time.Now().Add(twoFactorLifetime).Unix()
+		c.Sess.Save(r, w)
+		http.Redirect(w, r, "/login/twofactor", http.StatusFound)
+		return
+	}
+
+	// Login successfully with a new session ID
+	if err := start(c, u); err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	c.Sess.Save(r, w)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// TwoFactorIndex displays the page to enter the code from the authenticator
+// app after the password.
+func TwoFactorIndex(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	if _, ok := pending(&c); !ok {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	v := c.View.New("login/twofactor")
+	v.Render(w, r)
+}
+
+// TwoFactorStore logs in the user when the code from the authenticator app
+// or a recovery code matches.
+func TwoFactorStore(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	id, ok := pending(&c)
+	if !ok {
+		c.FlashNotice("The time to enter the code has passed. Log in again.")
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	// Validate with required fields
+	if !c.FormValid("code") {
+		TwoFactorIndex(w, r)
+		return
+	}
+
+	// Get database result
+	result, err := user.ByID(c.GORM, id)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		TwoFactorIndex(w, r)
+		return
+	} else if result.StatusID != userstatus.Active {
+		session.Empty(c.Sess)
+		c.FlashNotice("Account is inactive so login is disabled.")
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	// The codes count as login attempts so they cannot be guessed
+	keys := []string{lockout.AccountKey(result.Email), lockout.IPKey(c.IP())}
+	if throttled(&c, keys) {
+		TwoFactorIndex(w, r)
+		return
+	}
+
+	recovery, err := twofactor.Authenticate(c.GORM, c.Config.TOTP, id, r.FormValue("code"), time.Now())
+	if err == totp.ErrInvalid {
+		c.FlashWarning("Code is not valid.")
+		fail(&c, keys)
+		TwoFactorIndex(w, r)
+		return
+	}
+
+	release(&c, keys)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		TwoFactorIndex(w, r)
+		return
+	}
+
+	// Login successfully with a new session ID
+	if err := start(&c, result); err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	// Remind the user to create new codes before they run out
+	if recovery {
+		left, err := twofactor.RecoveryCodesLeft(c.GORM, id)
+		if err != nil {
+			log.Println("Two-factor:", err)
+		}
+		c.Sess.AddFlash(flash.Info{c.TN("You used a recovery code. You have %d recovery code left.", left), flash.Warning})
+	}
+
+	c.Sess.Save(r, w)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// pending returns the ID of the user who entered the password and must still
+// enter a code.
+func pending(c *flight.Info) (uint32, bool) {
+	id, ok := c.Sess.Values["twofactor.id"].(uint32)
+	if !ok {
+		return 0, false
+	}
+
+	expires, _ := c.Sess.Values["twofactor.expires"].(int64)
+	if time.Now().Unix() > expires {
+		delete(c.Sess.Values, "twofactor.id")
+		delete(c.Sess.Values, "twofactor.expires")
+		return 0, false
+	}
+
+	return id, true
+}
+
+// start logs in the user with a new session ID. Save the session after it.
+func start(c *flight.Info, u user.User) error {
+	if err := c.Config.Lockout.Succeed(lockout.AccountKey(u.Email)); err != nil {
+		log.Println("Lockout:", err)
+	}
+
+	session.Empty(c.Sess)
+	if err := c.Config.Session.Regenerate(c.Sess); err != nil {
+		return err
+	}
+
+	c.Sess.AddFlash(flash.Info{c.T("Login successful!"), flash.Success})
+	c.Sess.Values["id"] = u.ID
+	c.Sess.Values["email"] = u.Email
+	c.Sess.Values["first_name"] = u.FirstName
+	return nil
+}
+
+// throttled returns true and adds a flash when the account or the address
+// must wait before the next login attempt.
+func throttled(c *flight.Info, keys []string) bool {
+	wait, err := c.Config.Lockout.Check(keys...)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		return true
+	} else if wait > 0 {
+		seconds := int((wait + time.Second - 1) / time.Second)
+		c.Sess.AddFlash(flash.Info{c.TN("Too many failed login attempts. Try again in %d second.", seconds), flash.Warning})
+		c.Sess.Save(c.R, c.W)
+		return true
+	}
+
+	return false
+}
+
+// release takes back the login attempt that did not fail.
+func release(c *flight.Info, keys []string) {
+	if err := c.Config.Lockout.Release(keys...); err != nil {
+		log.Println("Lockout:", err)
+	}
+}
+
+// fail counts the failed login and writes the lockouts it causes to the
+// audit log.
+func fail(c *flight.Info, keys []string) {
+	locked, err := c.Config.Lockout.Fail(keys...)
+	if err != nil {
+		log.Println("Lockout:", err)
+	}
+
+	for _, key := range locked {
+		if err := audit.Create(c.GORM, audit.EventLockout, key, "", c.IP()); err != nil {
+			log.Println("Audit:", err)
+		}
+	}
+}
+
 // Logout clears the session and logs the user out.
 func Logout(w http.ResponseWriter, r *http.Request) {
 	c := flight.Context(w, r)
@@ -83,8 +301,40 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 	// If user is authenticated
 	if c.Sess.Values["id"] != nil {
 		session.Empty(c.Sess)
+		if err := c.Config.Session.Regenerate(c.Sess); err != nil {
+			c.FlashErrorGeneric(err)
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
 		c.FlashNotice("Goodbye!")
 	}
 
 	http.Redirect(w, r, "/", http.StatusFound)
 }
+
+// LogoutAll removes every session of the user so all devices are logged out.
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	err := c.Config.Session.DestroyUser(c.UserID)
+	if err == session.ErrCookieStore {
+		c.FlashWarning("Other devices cannot be logged out because the sessions are kept in the cookie.")
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	} else if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	// The current session was removed too so start a new one
+	session.Empty(c.Sess)
+	if err := c.Config.Session.Regenerate(c.Sess); err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	c.FlashNotice("All devices have been logged out.")
+
+	http.Redirect(w, r, "/", http.StatusFound)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/pcieslar/goforge/lib/flight"
 	"github.com/pcieslar/goforge/middleware/acl"
+	"github.com/pcieslar/goforge/middleware/transaction"
 	"github.com/pcieslar/goforge/model/note"
 
 	"github.com/pcieslar/goforge/core/pagination"
@@ -18,14 +19,14 @@ var (
 
 // Load the routes.
 func Load() {
-	c := router.Chain(acl.DisallowAnon)
-	router.Get(uri, Index, c...)
-	router.Get(uri+"/create", Create, c...)
-	router.Post(uri+"/create", Store, c...)
-	router.Get(uri+"/view/:id", Show, c...)
-	router.Get(uri+"/edit/:id", Edit, c...)
-	router.Patch(uri+"/edit/:id", Update, c...)
-	router.Delete(uri+"/:id", Destroy, c...)
+	g := router.Group(uri, acl.DisallowAnon)
+	g.Get("", Index).Named("notepad.index")
+	g.Get("/create", Create).Named("notepad.create")
+	g.Post("/create", Store, transaction.Handler).Named("notepad.store")
+	g.Get("/view/:id<int>", Show).Named("notepad.show")
+	g.Get("/edit/:id<int>", Edit).Named("notepad.edit")
+	g.Patch("/edit/:id<int>", Update, transaction.Handler).Named("notepad.update")
+	g.Delete("/:id<int>", Destroy, transaction.Handler).Named("notepad.destroy")
 }
 
 // Index displays the items.
@@ -35,13 +36,13 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	// Create a pagination instance with a max of 10 results.
 	p := pagination.New(r, 10)
 
-	items, _, err := note.ByUserIDPaginate(c.UserID, p.PerPage, p.Offset)
+	items, _, err := note.ByUserIDPaginate(c.GORM, c.UserID, p.PerPage, p.Offset)
 	if err != nil {
 		c.FlashErrorGeneric(err)
 		items = []note.Note{}
 	}
 
-	count, err := note.ByUserIDCount(c.UserID)
+	count, err := note.ByUserIDCount(c.GORM, c.UserID)
 	if err != nil {
 		c.FlashErrorGeneric(err)
 	}
@@ -51,8 +52,9 @@ func Index(w http.ResponseWriter, r *http.Request) {
 
 	v := c.View.New("note/index")
 	v.Vars["items"] = items
+	v.Vars["count"] = count
 	v.Vars["pagination"] = p
-	v.Render(w, r)
+	v.Respond(w, r)
 }
 
 // Create displays the create form.
@@ -73,7 +75,7 @@ func Store(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := note.Create(r.FormValue("name"), c.UserID)
+	_, err := note.Create(c.GORM, r.FormValue("name"), c.UserID)
 	if err != nil {
 		c.FlashErrorGeneric(err)
 		Create(w, r)
@@ -88,7 +90,7 @@ func Store(w http.ResponseWriter, r *http.Request) {
 func Show(w http.ResponseWriter, r *http.Request) {
 	c := flight.Context(w, r)
 
-	item, _, err := note.ByID(c.Param("id"), c.UserID)
+	item, _, err := note.ByID(c.GORM, c.ParamInt("id"), c.UserID)
 	if err != nil {
 		c.FlashErrorGeneric(err)
 		c.Redirect(uri)
@@ -97,14 +99,14 @@ func Show(w http.ResponseWriter, r *http.Request) {
 
 	v := c.View.New("note/show")
 	v.Vars["item"] = item
-	v.Render(w, r)
+	v.Respond(w, r)
 }
 
 // Edit displays the edit form.
 func Edit(w http.ResponseWriter, r *http.Request) {
 	c := flight.Context(w, r)
 
-	item, _, err := note.ByID(c.Param("id"), c.UserID)
+	item, _, err := note.ByID(c.GORM, c.ParamInt("id"), c.UserID)
 	if err != nil {
 		c.FlashErrorGeneric(err)
 		c.Redirect(uri)
@@ -126,7 +128,7 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := note.Update(r.FormValue("name"), c.Param("id"), c.UserID)
+	err := note.Update(c.GORM, r.FormValue("name"), c.ParamInt("id"), c.UserID)
 	if err != nil {
 		c.FlashErrorGeneric(err)
 		Edit(w, r)
@@ -141,7 +143,7 @@ func Update(w http.ResponseWriter, r *http.Request) {
 func Destroy(w http.ResponseWriter, r *http.Request) {
 	c := flight.Context(w, r)
 
-	err := note.DeleteSoft(c.Param("id"), c.UserID)
+	err := note.DeleteSoft(c.GORM, c.ParamInt("id"), c.UserID)
 	if err != nil {
 		c.FlashErrorGeneric(err)
 	} else {
@@ -0,0 +1,221 @@
+// Package password handles the password reset for users who forgot their
+// password.
+package password
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/middleware/acl"
+	"github.com/pcieslar/goforge/middleware/transaction"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/apitoken"
+	"github.com/pcieslar/goforge/model/passwordreset"
+	"github.com/pcieslar/goforge/model/user"
+
+	"github.com/pcieslar/goforge/core/form"
+	"github.com/pcieslar/goforge/core/lockout"
+	"github.com/pcieslar/goforge/core/passhash"
+	"github.com/pcieslar/goforge/core/router"
+	"github.com/pcieslar/goforge/core/session"
+)
+
+// tokenLifetime is how long a reset link can be used.
+const tokenLifetime = time.Hour
+
+// Load the routes.
+func Load() {
+	router.Get("/password/forgot", Index, acl.DisallowAuth).Named("password.forgot")
+	router.Post("/password/forgot", Store, acl.DisallowAuth).Named("password.forgot.store")
+	router.Get("/password/reset/:token", Edit, acl.DisallowAuth).Named("password.reset")
+	router.Post("/password/reset/:token", Update, acl.DisallowAuth, transaction.Handler).Named("password.reset.update")
+}
+
+// Index displays the page to request a reset link.
+func Index(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	v := c.View.New("password/forgot")
+	form.Repopulate(r.Form, v.Vars, "email")
+	v.Render(w, r)
+}
+
+// Store emails a reset link to the user. The same message is shown whether
+// or not the account exists so the form cannot be used to find accounts. Only
+// a few emails are sent to an address so a mailbox cannot be flooded.
+func Store(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	// Validate with required fields
+	if !c.FormValid("email") {
+		Index(w, r)
+		return
+	}
+
+	email := r.FormValue("email")
+
+	// Get database result
+	result, err := user.ByEmail(c.GORM, email)
+	if err == nil && allowed(&c, email) {
+		err = sendLink(&c, result)
+	}
+
+	// Only log the error so the response is the same for every address
+	if err != nil && err != model.ErrNoResult {
+		log.Println("Password reset:", err)
+	}
+
+	c.FlashNotice("If an account exists for %v, a link to reset the password has been sent to it.", email)
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
+
+// allowed counts the email to the address and returns false when it must
+// wait because too many were sent to it.
+func allowed(c *flight.Info, email string) bool {
+	key := lockout.ResetKey(email)
+
+	wait, err := c.Config.Lockout.Check(key)
+	if err != nil {
+		log.Println("Lockout:", err)
+		return false
+	} else if wait > 0 {
+		log.Println("Password reset: too many emails to", email)
+		return false
+	}
+
+	// Keep the attempt so it counts toward the limit
+	if _, err := c.Config.Lockout.Fail(key); err != nil {
+		log.Println("Lockout:", err)
+	}
+
+	return true
+}
+
+// sendLink creates a token for the user and emails the link with it. The
+// email is sent in the background.
+func sendLink(c *flight.Info, u user.User) error {
+	token, _, err := passwordreset.Create(c.GORM, u.ID, tokenLifetime)
+	if err != nil {
+		return err
+	}
+
+	link, err := c.PublicURL("password.reset", "token", token)
+	if err != nil {
+		return err
+	}
+
+	// Render the email body in the language of the request
+	v := c.View.New("email/password_reset").Base("email/base")
+	v.Vars["locale"] = c.Locale
+	v.Vars["first_name"] = u.FirstName
+	v.Vars["link"] = link
+	v.Vars["minutes"] = int(tokenLifetime / time.Minute)
+
+	var body bytes.Buffer
+	if err := v.Execute(&body); err != nil {
+		return err
+	}
+
+	// Send after the response so its time does not show the account exists
+	c.Config.Email.SendLater(u.Email, c.T("Reset your password"), body.String(), func(err error) {
+		if err != nil {
+			log.Println("Password reset:", err)
+		}
+	})
+
+	return nil
+}
+
+// Edit displays the page to choose a new password.
+func Edit(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	token := c.Param("token")
+	if _, err := passwordreset.ByToken(c.GORM, token); err == model.ErrNoResult {
+		c.FlashWarning("The link to reset the password is invalid or has expired.")
+		http.Redirect(w, r, "/password/forgot", http.StatusFound)
+		return
+	} else if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/password/forgot", http.StatusFound)
+		return
+	}
+
+	// Keep the token out of the Referer header of the links on the page
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	v := c.View.New("password/reset")
+	v.Vars["reset_token"] = token
+	v.Render(w, r)
+}
+
+// Update changes the password, removes every reset token and API token of
+// the user, and logs out the user everywhere once the changes are saved.
+func Update(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	// Validate with required fields
+	if !c.FormValid("password", "password_verify") {
+		Edit(w, r)
+		return
+	}
+
+	// Validate passwords
+	if r.FormValue("password") != r.FormValue("password_verify") {
+		c.FlashError(errors.New("Passwords do not match."))
+		Edit(w, r)
+		return
+	}
+
+	// Hash password
+	password, err := passhash.HashString(r.FormValue("password"))
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		Edit(w, r)
+		return
+	}
+
+	// Use the token so it cannot be used again
+	item, err := passwordreset.Use(c.GORM, c.Param("token"))
+	if err == model.ErrNoResult {
+		c.FlashWarning("The link to reset the password is invalid or has expired.")
+		http.Redirect(w, r, "/password/forgot", http.StatusFound)
+		return
+	} else if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/password/forgot", http.StatusFound)
+		return
+	}
+
+	// Change the password, remove the other links sent to the user, and
+	// revoke the API tokens that may have been stolen
+	err = user.UpdatePassword(c.GORM, item.UserID, password)
+	if err == nil {
+		err = passwordreset.Invalidate(c.GORM, item.UserID)
+	}
+	if err == nil {
+		err = apitoken.DeleteByUser(c.GORM, item.UserID)
+	}
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/password/forgot", http.StatusFound)
+		return
+	}
+
+	// Log out the devices that may have been used with the old password, only
+	// after the new one is saved
+	transaction.AfterCommit(r, func() {
+		err := c.Config.Session.DestroyUser(strconv.FormatUint(uint64(item.UserID), 10))
+		if err != nil && err != session.ErrCookieStore {
+			log.Println("Password reset:", err)
+		}
+	})
+
+	c.FlashSuccess("Password changed. You can now log in with the new password.")
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
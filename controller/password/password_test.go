@@ -0,0 +1,250 @@
+package password_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/boot"
+	"github.com/pcieslar/goforge/lib/env"
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/lib/gorm"
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/sqlite"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/apitoken"
+	"github.com/pcieslar/goforge/model/user"
+
+	"github.com/pcieslar/goforge/core/email"
+	"github.com/pcieslar/goforge/core/email/smtptest"
+	"github.com/pcieslar/goforge/core/passhash"
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// link finds the reset link in an email.
+var link = regexp.MustCompile(`href="([^"]+)"`)
+
+// setup returns the app with a user with the password "secret" and the mail
+// server the links are sent to.
+func setup(t *testing.T) (http.Handler, *gorm.DB, *smtptest.Server) {
+	srv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	db, err := gorm.Open("sqlite3", t.TempDir()+"/test.db?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, q := range []string{
+		`CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, first_name TEXT, last_name TEXT, email TEXT, password TEXT, status_id INT, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`,
+		`CREATE TABLE password_reset (id INTEGER PRIMARY KEY AUTOINCREMENT, token_hash TEXT UNIQUE, user_id INT, expires_at TIMESTAMP, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`,
+		`CREATE TABLE api_token (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, token_hash TEXT UNIQUE, user_id INT, expires_at TIMESTAMP, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`,
+		`CREATE TABLE two_factor (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INT UNIQUE, secret TEXT, last_counter INT NOT NULL DEFAULT 0, enabled_at TIMESTAMP, created_at TIMESTAMP, updated_at TIMESTAMP)`,
+		`CREATE TABLE audit_log (id INTEGER PRIMARY KEY AUTOINCREMENT, event TEXT, subject TEXT, actor TEXT, ip TEXT, created_at TIMESTAMP)`,
+	} {
+		if err := db.Exec(q).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := passhash.HashString("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.Create(db, "John", "Doe", "jdoe@domain.com", hash); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := env.LoadConfig("../../env.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Asset.Folder = "../../asset"
+	config.I18n.Folder = "../../i18n"
+	config.View.Folder = "../../view"
+	config.Email.Hostname = srv.Hostname
+	config.Email.Port = srv.Port
+	config.Email.From = "app@domain.com"
+	config.Session.Store = "memory"
+	config.Server.PublicURL = "https://example.com"
+
+	// Allow the next email right away but only two to the same address
+	config.Lockout.Delay = 0
+	config.Lockout.ResetAttempts = 2
+
+	router.ResetConfig()
+	boot.RegisterServices(config)
+	flight.StoreGORM(db)
+	t.Cleanup(flight.Reset)
+
+	return flight.Handler(router.Instance()), db, srv
+}
+
+// browser keeps the cookies between requests like a web browser.
+type browser struct {
+	h       http.Handler
+	cookies map[string]*http.Cookie
+}
+
+// do sends the request with the form if there is one.
+func (b *browser) do(method, path string, form url.Values) *httptest.ResponseRecorder {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, path, nil)
+	}
+
+	for _, c := range b.cookies {
+		r.AddCookie(c)
+	}
+
+	w := httptest.NewRecorder()
+	b.h.ServeHTTP(w, r)
+
+	if b.cookies == nil {
+		b.cookies = make(map[string]*http.Cookie)
+	}
+	for _, c := range w.Result().Cookies() {
+		b.cookies[c.Name] = c
+	}
+
+	return w
+}
+
+// TestReset ensures a link from the email changes the password once, revokes
+// the API tokens, and logs out the other devices.
+func TestReset(t *testing.T) {
+	h, db, srv := setup(t)
+
+	u, err := user.ByEmail(db, "jdoe@domain.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := apitoken.Create(db, "Mobile", u.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another device is logged in with the old password
+	device := &browser{h: h}
+	if w := device.do("POST", "/login", url.Values{"email": {"jdoe@domain.com"}, "password": {"secret"}}); w.Header().Get("Location") != "/" {
+		t.Fatalf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/")
+	}
+
+	b := &browser{h: h}
+
+	// Request the link
+	w := b.do("POST", "/password/forgot", url.Values{"email": {"jdoe@domain.com"}})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login" {
+		t.Fatalf("\nactual: %v %v\nexpected: %v %v", w.Code, w.Header().Get("Location"), http.StatusFound, "/login")
+	}
+
+	email.Wait()
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("\nactual: %v\nexpected: %v", len(messages), 1)
+	}
+	body, err := messages[0].Body()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := link.FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("link is missing: %v", body)
+	}
+	reset, err := url.Parse(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Open it
+	if w := b.do("GET", reset.Path, nil); w.Code != http.StatusOK {
+		t.Fatalf("\nactual: %v\nexpected: %v", w.Code, http.StatusOK)
+	}
+
+	// Change the password
+	w = b.do("POST", reset.Path, url.Values{"password": {"changed"}, "password_verify": {"changed"}})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login" {
+		t.Fatalf("\nactual: %v %v\nexpected: %v %v", w.Code, w.Header().Get("Location"), http.StatusFound, "/login")
+	}
+
+	if u, err := user.ByEmail(db, "jdoe@domain.com"); err != nil {
+		t.Fatal(err)
+	} else if !passhash.MatchString(u.Password, "changed") {
+		t.Error("password should be changed")
+	}
+	if _, err := apitoken.ByToken(db, token); err != model.ErrNoResult {
+		t.Errorf("\nactual: %v\nexpected: %v", err, model.ErrNoResult)
+	}
+
+	// The other device is logged out so it can see the guest pages again
+	if w := device.do("GET", "/password/forgot", nil); w.Code != http.StatusOK {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Code, http.StatusOK)
+	}
+
+	// Reuse the link
+	if w := b.do("GET", reset.Path, nil); w.Header().Get("Location") != "/password/forgot" {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/password/forgot")
+	}
+	b.do("POST", reset.Path, url.Values{"password": {"again"}, "password_verify": {"again"}})
+	if u, err := user.ByEmail(db, "jdoe@domain.com"); err != nil {
+		t.Fatal(err)
+	} else if !passhash.MatchString(u.Password, "changed") {
+		t.Error("password should not change again")
+	}
+}
+
+// TestHost ensures the link in the email points to the configured site even
+// when the request is sent with another host.
+func TestHost(t *testing.T) {
+	h, _, srv := setup(t)
+
+	r := httptest.NewRequest("POST", "/password/forgot", strings.NewReader(url.Values{"email": {"jdoe@domain.com"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Host = "evil.example"
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	email.Wait()
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("\nactual: %v\nexpected: %v", len(messages), 1)
+	}
+	body, err := messages[0].Body()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := link.FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("link is missing: %v", body)
+	}
+	if !strings.HasPrefix(m[1], "https://example.com/password/reset/") {
+		t.Errorf("\nactual: %v\nexpected: %v", m[1], "https://example.com/password/reset/...")
+	}
+}
+
+// TestLimit ensures only a few emails are sent to the same address.
+func TestLimit(t *testing.T) {
+	h, _, srv := setup(t)
+
+	b := &browser{h: h}
+	for i := 0; i < 4; i++ {
+		w := b.do("POST", "/password/forgot", url.Values{"email": {"jdoe@domain.com"}})
+		if w.Header().Get("Location") != "/login" {
+			t.Errorf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/login")
+		}
+	}
+
+	email.Wait()
+	if n := len(srv.Messages()); n != 2 {
+		t.Errorf("\nactual: %v\nexpected: %v", n, 2)
+	}
+}
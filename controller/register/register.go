@@ -1,24 +1,43 @@
-// Package register handles the user creation.
+// Package register handles the user creation and the verification of the
+// email address.
 package register
 
 import (
+	"bytes"
 	"errors"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/pcieslar/goforge/lib/flight"
 	"github.com/pcieslar/goforge/middleware/acl"
 	"github.com/pcieslar/goforge/model"
 	"github.com/pcieslar/goforge/model/user"
+	"github.com/pcieslar/goforge/model/userstatus"
 
 	"github.com/pcieslar/goforge/core/form"
+	"github.com/pcieslar/goforge/core/lockout"
 	"github.com/pcieslar/goforge/core/passhash"
 	"github.com/pcieslar/goforge/core/router"
 )
 
+// verifyPurpose is the name the verification tokens are signed for.
+const verifyPurpose = "verify"
+
+// verification is the value signed into the verification link. The email
+// address is included so the link stops working if the address changes.
+type verification struct {
+	ID    uint32 `json:"id"`
+	Email string `json:"email"`
+}
+
 // Load the routes.
 func Load() {
-	router.Get("/register", Index, acl.DisallowAuth)
-	router.Post("/register", Store, acl.DisallowAuth)
+	router.Get("/register", Index, acl.DisallowAuth).Named("register")
+	router.Post("/register", Store, acl.DisallowAuth).Named("register.store")
+	router.Get("/register/verify/:token", Verify).Named("register.verify")
+	router.Get("/register/resend", Resend, acl.DisallowAuth).Named("register.resend")
+	router.Post("/register/resend", ResendStore, acl.DisallowAuth).Named("register.resend.store")
 }
 
 // Index displays the register page.
@@ -29,7 +48,8 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	v.Render(w, r)
 }
 
-// Store handles the registration form submission.
+// Store handles the registration form submission. The account is pending
+// until the link emailed to the address is clicked.
 func Store(w http.ResponseWriter, r *http.Request) {
 	c := flight.Context(w, r)
 
@@ -62,24 +82,176 @@ func Store(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get database result
-	_, err := user.ByEmail(email)
+	_, err := user.ByEmail(c.GORM, email)
 
 	if err == model.ErrNoResult { // If success (no user exists with that email)
-		err = user.Create(firstName, lastName, email, password)
+		err = user.CreateWithStatus(c.GORM, firstName, lastName, email, password, userstatus.Pending)
 		// Will only error if there is a problem with the query
 		if err != nil {
 			c.FlashErrorGeneric(err)
 		} else {
-			c.FlashSuccess("Account created successfully for: " + email)
+			// The account exists so a failed email can be sent again later
+			if err := sendVerification(&c, email); err != nil {
+				log.Println("Email verification:", err)
+			}
+			c.FlashSuccess("Account created successfully for: %v", email)
+			c.FlashNotice("Click the link in the email sent to %v to verify the address before you log in.", email)
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
 	} else if err != nil { // Catch all other errors
 		c.FlashErrorGeneric(err)
 	} else { // Else the user already exists
-		c.FlashError(errors.New("Account already exists for: " + email))
+		c.FlashWarning("Account already exists for: %v", email)
 	}
 
 	// Display the page
 	Index(w, r)
 }
+
+// allowed counts an email to the address and returns false when too many
+// were sent so the form cannot be used to flood the mailbox.
+func allowed(c *flight.Info, email string) bool {
+	key := lockout.VerifyKey(email)
+
+	wait, err := c.Config.Lockout.Check(key)
+	if err != nil {
+		log.Println("Lockout:", err)
+		return false
+	} else if wait > 0 {
+		log.Println("Email verification: too many emails to", email)
+		return false
+	}
+
+	// Keep the attempt so it counts toward the limit
+	if _, err := c.Config.Lockout.Fail(key); err != nil {
+		log.Println("Lockout:", err)
+	}
+
+	return true
+}
+
+// sendVerification emails the verification link to the user with the email
+// address. The email is sent in the background.
+func sendVerification(c *flight.Info, email string) error {
+	u, err := user.ByEmail(c.GORM, email)
+	if err != nil {
+		return err
+	}
+
+	token, err := c.Config.Verify.Sign(verifyPurpose, verification{u.ID, u.Email})
+	if err != nil {
+		return err
+	}
+
+	link, err := c.PublicURL("register.verify", "token", token)
+	if err != nil {
+		return err
+	}
+
+	// Render the email body in the language of the request
+	v := c.View.New("email/verify").Base("email/base")
+	v.Vars["locale"] = c.Locale
+	v.Vars["first_name"] = u.FirstName
+	v.Vars["link"] = link
+
+	// Round up so a short lifetime is not shown as 0 hours
+	maxAge := time.Duration(c.Config.Verify.MaxAge) * time.Second
+	if maxAge >= time.Hour {
+		v.Vars["hours"] = int((maxAge + time.Hour - 1) / time.Hour)
+	} else {
+		v.Vars["minutes"] = int((maxAge + time.Minute - 1) / time.Minute)
+	}
+
+	var body bytes.Buffer
+	if err := v.Execute(&body); err != nil {
+		return err
+	}
+
+	// Send after the response so its time does not show the account exists
+	c.Config.Email.SendLater(u.Email, c.T("Verify your email address"), body.String(), func(err error) {
+		if err != nil {
+			log.Println("Email verification:", err)
+		}
+	})
+
+	return nil
+}
+
+// Verify activates the account from the link in the email.
+func Verify(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	var value verification
+	if err := c.Config.Verify.Verify(verifyPurpose, c.Param("token"), &value); err != nil {
+		c.FlashWarning("The verification link is invalid or has expired.")
+		http.Redirect(w, r, "/register/resend", http.StatusFound)
+		return
+	}
+
+	// The address must still belong to the user
+	u, err := user.ByID(c.GORM, value.ID)
+	if err == model.ErrNoResult || (err == nil && u.Email != value.Email) {
+		c.FlashWarning("The verification link is invalid or has expired.")
+		http.Redirect(w, r, "/register/resend", http.StatusFound)
+		return
+	} else if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	// Only a pending account is activated so the link cannot undo a ban
+	if err := user.Verify(c.GORM, u.ID); err == model.ErrNoResult {
+		if u.StatusID == userstatus.Active {
+			c.FlashNotice("Email address is already verified.")
+		} else {
+			c.FlashNotice("Account is inactive so login is disabled.")
+		}
+	} else if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	} else {
+		c.FlashSuccess("Email address verified. You can now log in.")
+	}
+
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
+
+// Resend displays the page to request a new verification link.
+func Resend(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+	v := c.View.New("register/resend")
+	form.Repopulate(r.Form, v.Vars, "email")
+	v.Render(w, r)
+}
+
+// ResendStore emails a new verification link. The same message is shown
+// whether or not a pending account exists so the form cannot be used to find
+// accounts.
+func ResendStore(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	// Validate with required fields
+	if !c.FormValid("email") {
+		Resend(w, r)
+		return
+	}
+
+	email := r.FormValue("email")
+
+	// Only send to an account waiting for verification
+	u, err := user.ByEmail(c.GORM, email)
+	if err == nil && u.StatusID == userstatus.Pending && allowed(&c, email) {
+		err = sendVerification(&c, email)
+	}
+
+	// Only log the error so the response is the same for every address
+	if err != nil && err != model.ErrNoResult {
+		log.Println("Email verification:", err)
+	}
+
+	c.FlashNotice("If an account waiting for verification exists for %v, a new link has been sent to it.", email)
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
@@ -0,0 +1,278 @@
+package register_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/pcieslar/goforge/lib/boot"
+	"github.com/pcieslar/goforge/lib/env"
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/lib/gorm"
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/sqlite"
+	"github.com/pcieslar/goforge/model/user"
+	"github.com/pcieslar/goforge/model/userstatus"
+
+	"github.com/pcieslar/goforge/core/email"
+	"github.com/pcieslar/goforge/core/email/smtptest"
+	"github.com/pcieslar/goforge/core/passhash"
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// link finds the verification link in an email.
+var link = regexp.MustCompile(`href="([^"]+)"`)
+
+// setup returns the app and the mail server the links are sent to.
+func setup(t *testing.T) (http.Handler, *gorm.DB, *smtptest.Server) {
+	srv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	db, err := gorm.Open("sqlite3", t.TempDir()+"/test.db?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	q := `CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, first_name TEXT, last_name TEXT, email TEXT, password TEXT, status_id INT, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`
+	if err := db.Exec(q).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := env.LoadConfig("../../env.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Asset.Folder = "../../asset"
+	config.I18n.Folder = "../../i18n"
+	config.View.Folder = "../../view"
+	config.Email.Hostname = srv.Hostname
+	config.Email.Port = srv.Port
+	config.Email.From = "app@domain.com"
+	config.Session.Store = "memory"
+	config.Server.PublicURL = "https://example.com"
+
+	// Allow the next email right away but only two to the same address
+	config.Lockout.Delay = 0
+	config.Lockout.VerifyAttempts = 2
+
+	router.ResetConfig()
+	boot.RegisterServices(config)
+	flight.StoreGORM(db)
+	t.Cleanup(flight.Reset)
+
+	return flight.Handler(router.Instance()), db, srv
+}
+
+// browser keeps the cookies between requests like a web browser.
+type browser struct {
+	h       http.Handler
+	cookies map[string]*http.Cookie
+}
+
+// do sends the request with the form if there is one.
+func (b *browser) do(method, path string, form url.Values) *httptest.ResponseRecorder {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, path, nil)
+	}
+
+	for _, c := range b.cookies {
+		r.AddCookie(c)
+	}
+
+	w := httptest.NewRecorder()
+	b.h.ServeHTTP(w, r)
+
+	if b.cookies == nil {
+		b.cookies = make(map[string]*http.Cookie)
+	}
+	for _, c := range w.Result().Cookies() {
+		b.cookies[c.Name] = c
+	}
+
+	return w
+}
+
+// signup registers the account and returns the browser.
+func signup(t *testing.T, h http.Handler) *browser {
+	b := &browser{h: h}
+	w := b.do("POST", "/register", url.Values{
+		"first_name":      {"John"},
+		"last_name":       {"Doe"},
+		"email":           {"jdoe@domain.com"},
+		"password":        {"secret"},
+		"password_verify": {"secret"},
+	})
+	if w.Header().Get("Location") != "/login" {
+		t.Fatalf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/login")
+	}
+	return b
+}
+
+// verifyPath returns the path of the link in the last email.
+func verifyPath(t *testing.T, srv *smtptest.Server) string {
+	email.Wait()
+	messages := srv.Messages()
+	if len(messages) == 0 {
+		t.Fatal("email is missing")
+	}
+	body, err := messages[len(messages)-1].Body()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := link.FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("link is missing: %v", body)
+	}
+	if !strings.HasPrefix(m[1], "https://example.com/register/verify/") {
+		t.Fatalf("\nactual: %v\nexpected: %v", m[1], "https://example.com/register/verify/...")
+	}
+	u, err := url.Parse(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Path
+}
+
+// status returns the status of the account with the address.
+func status(t *testing.T, db *gorm.DB, email string) uint8 {
+	u, err := user.ByEmail(db, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.StatusID
+}
+
+// TestStore ensures a new account is pending until the link in the email is
+// clicked.
+func TestStore(t *testing.T) {
+	h, db, srv := setup(t)
+	b := signup(t, h)
+
+	if s := status(t, db, "jdoe@domain.com"); s != userstatus.Pending {
+		t.Fatalf("\nactual: %v\nexpected: %v", s, userstatus.Pending)
+	}
+
+	// The account cannot log in yet
+	w := b.do("POST", "/login", url.Values{"email": {"jdoe@domain.com"}, "password": {"secret"}})
+	if w.Header().Get("Location") == "/" {
+		t.Error("pending account should not log in")
+	}
+
+	if w := b.do("GET", verifyPath(t, srv), nil); w.Header().Get("Location") != "/login" {
+		t.Fatalf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/login")
+	}
+	if s := status(t, db, "jdoe@domain.com"); s != userstatus.Active {
+		t.Errorf("\nactual: %v\nexpected: %v", s, userstatus.Active)
+	}
+}
+
+// TestEmailChanged ensures a link stops working when the address of the
+// account changes.
+func TestEmailChanged(t *testing.T) {
+	h, db, srv := setup(t)
+	b := signup(t, h)
+	path := verifyPath(t, srv)
+
+	if err := db.Exec("UPDATE user SET email = ? WHERE email = ?", "other@domain.com", "jdoe@domain.com").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if w := b.do("GET", path, nil); w.Header().Get("Location") != "/register/resend" {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/register/resend")
+	}
+	if s := status(t, db, "other@domain.com"); s != userstatus.Pending {
+		t.Errorf("\nactual: %v\nexpected: %v", s, userstatus.Pending)
+	}
+}
+
+// TestInactive ensures a link does not reactivate a disabled account.
+func TestInactive(t *testing.T) {
+	h, db, srv := setup(t)
+	b := signup(t, h)
+	path := verifyPath(t, srv)
+
+	if err := db.Exec("UPDATE user SET status_id = ? WHERE email = ?", userstatus.Inactive, "jdoe@domain.com").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if w := b.do("GET", path, nil); w.Header().Get("Location") != "/login" {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/login")
+	}
+	if s := status(t, db, "jdoe@domain.com"); s != userstatus.Inactive {
+		t.Errorf("\nactual: %v\nexpected: %v", s, userstatus.Inactive)
+	}
+}
+
+// TestResend ensures the response is the same whether or not an account
+// waiting for verification exists so the form cannot be used to find
+// accounts.
+func TestResend(t *testing.T) {
+	h, db, srv := setup(t)
+
+	hash, err := passhash.HashString("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.Create(db, "Jane", "Doe", "active@domain.com", hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.CreateWithStatus(db, "Jim", "Doe", "pending@domain.com", hash, userstatus.Pending); err != nil {
+		t.Fatal(err)
+	}
+
+	var expected string
+	for _, email := range []string{"pending@domain.com", "active@domain.com", "unknown@domain.com"} {
+		b := &browser{h: h}
+		w := b.do("POST", "/register/resend", url.Values{"email": {email}})
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "/login" {
+			t.Fatalf("%v: \nactual: %v %v\nexpected: %v %v", email, w.Code, w.Header().Get("Location"), http.StatusFound, "/login")
+		}
+
+		// Compare the page with the flash without the address
+		page := strings.Replace(b.do("GET", "/login", nil).Body.String(), email, "", -1)
+		if !strings.Contains(page, "a new link has been sent") {
+			t.Fatalf("%v: flash is missing: %v", email, page)
+		}
+		if len(expected) == 0 {
+			expected = page
+		} else if page != expected {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", email, page, expected)
+		}
+	}
+
+	// Only the pending account gets an email
+	email.Wait()
+	if n := len(srv.Messages()); n != 1 {
+		t.Errorf("\nactual: %v\nexpected: %v", n, 1)
+	}
+}
+
+// TestLimit ensures only a few verification emails are sent to the same
+// address.
+func TestLimit(t *testing.T) {
+	h, _, srv := setup(t)
+	b := signup(t, h)
+
+	for i := 0; i < 4; i++ {
+		w := b.do("POST", "/register/resend", url.Values{"email": {"jdoe@domain.com"}})
+		if w.Header().Get("Location") != "/login" {
+			t.Errorf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/login")
+		}
+	}
+
+	// The email from the signup and two more
+	email.Wait()
+	if n := len(srv.Messages()); n != 3 {
+		t.Errorf("\nactual: %v\nexpected: %v", n, 3)
+	}
+}
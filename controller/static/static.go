@@ -2,6 +2,7 @@
 package static
 
 import (
+	"io/fs"
 	"net/http"
 	"os"
 	"path"
@@ -25,6 +26,17 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	// File path
 	path := path.Join(c.Config.Asset.Folder, r.URL.Path[1:])
 
+	// Serve from the FS when the assets are embedded
+	if fsys := c.Config.Asset.FS; fsys != nil {
+		if fi, err := fs.Stat(fsys, path); err == nil && !fi.IsDir() {
+			http.ServeFileFS(w, r, fsys, path)
+			return
+		}
+
+		status.Error404(w, r)
+		return
+	}
+
 	// Only serve files
 	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
 		http.ServeFile(w, r, path)
@@ -3,12 +3,46 @@
 package status
 
 import (
+	"encoding/json"
+	"encoding/xml"
 	"net/http"
 
 	"github.com/pcieslar/goforge/lib/flight"
 	"github.com/pcieslar/goforge/core/router"
+	"github.com/pcieslar/goforge/core/view"
 )
 
+// ErrorBody is the machine-readable error sent to JSON and XML requests. In
+// JSON it is wrapped in an error object.
+type ErrorBody struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Status  int      `json:"status" xml:"status"`
+	Title   string   `json:"title" xml:"title"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// MarshalJSON wraps the error in an error object.
+func (e ErrorBody) MarshalJSON() ([]byte, error) {
+	type body ErrorBody
+	return json.Marshal(struct {
+		Error body `json:"error"`
+	}{body(e)})
+}
+
+// NewErrorBody returns the error for the status code and message.
+func NewErrorBody(code int, message string) ErrorBody {
+	return ErrorBody{
+		Status:  code,
+		Title:   http.StatusText(code),
+		Message: message,
+	}
+}
+
+// WriteError writes the error as JSON for API requests.
+func WriteError(w http.ResponseWriter, code int, message string) error {
+	return view.JSON(w, code, NewErrorBody(code, message))
+}
+
 // Load the routes.
 func Load() {
 	router.MethodNotAllowed(Error405)
@@ -18,53 +52,52 @@ func Load() {
 // Error404 - Page Not Found.
 func Error404(w http.ResponseWriter, r *http.Request) {
 	c := flight.Context(w, r)
-	w.WriteHeader(http.StatusNotFound)
-	v := c.View.New("status/index")
-	v.Vars["title"] = "404 Not Found"
-	v.Vars["message"] = "Page could not be found."
-	v.Render(w, r)
+	v := c.View.New("status/index").Status(http.StatusNotFound)
+	v.Vars["title"] = c.T("404 Not Found")
+	v.Vars["message"] = c.T("Page could not be found.")
+	v.Payload(NewErrorBody(http.StatusNotFound, c.T("Page could not be found.")))
+	v.Respond(w, r)
 }
 
 // Error405 - Method Not Allowed.
 func Error405(allowedMethods string) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		c := flight.Context(w, r)
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		v := c.View.New("status/index")
-		v.Vars["title"] = "405 Method Not Allowed"
-		v.Vars["message"] = "Method is not allowed."
-		v.Render(w, r)
+		w.Header().Set("Allow", allowedMethods)
+		v := c.View.New("status/index").Status(http.StatusMethodNotAllowed)
+		v.Vars["title"] = c.T("405 Method Not Allowed")
+		v.Vars["message"] = c.T("Method is not allowed.")
+		v.Payload(NewErrorBody(http.StatusMethodNotAllowed, c.T("Method is not allowed.")))
+		v.Respond(w, r)
 	}
 }
 
 // Error500 - Internal Server Error.
 func Error500(w http.ResponseWriter, r *http.Request) {
 	c := flight.Context(w, r)
-	w.WriteHeader(http.StatusInternalServerError)
-	v := c.View.New("status/index")
-	v.Vars["title"] = "500 Internal Server Error"
-	v.Vars["message"] = "An internal server error occurred."
-	v.Render(w, r)
+	v := c.View.New("status/index").Status(http.StatusInternalServerError)
+	v.Vars["title"] = c.T("500 Internal Server Error")
+	v.Vars["message"] = c.T("An internal server error occurred.")
+	v.Payload(NewErrorBody(http.StatusInternalServerError, c.T("An internal server error occurred.")))
+	v.Respond(w, r)
 }
 
 // Error501 - Not Implemented.
 func Error501(w http.ResponseWriter, r *http.Request) {
 	c := flight.Context(w, r)
-	w.WriteHeader(http.StatusNotImplemented)
-	v := c.View.New("status/index")
-	v.Vars["title"] = "501 Not Implemented"
-	v.Vars["message"] = "Page is not yet implemented."
-	v.Render(w, r)
+	v := c.View.New("status/index").Status(http.StatusNotImplemented)
+	v.Vars["title"] = c.T("501 Not Implemented")
+	v.Vars["message"] = c.T("Page is not yet implemented.")
+	v.Payload(NewErrorBody(http.StatusNotImplemented, c.T("Page is not yet implemented.")))
+	v.Respond(w, r)
 }
 
 // InvalidToken shows a page in response to CSRF attacks.
 func InvalidToken(w http.ResponseWriter, r *http.Request) {
 	c := flight.Context(w, r)
-	w.WriteHeader(http.StatusForbidden)
-	v := c.View.New("status/index")
-	v.Vars["title"] = "Invalid Token"
-	v.Vars["message"] = `Your token <strong>expired</strong>,
-		click <a href="javascript:void(0)" onclick="location.replace(document.referrer)">here</a>
-		to try again.`
-	v.Render(w, r)
+	v := c.View.New("status/index").Status(http.StatusForbidden)
+	v.Vars["title"] = c.T("Invalid Token")
+	v.Vars["message"] = c.T(`Your token <strong>expired</strong>, click <a href="javascript:void(0)" onclick="location.replace(document.referrer)">here</a> to try again.`)
+	v.Payload(NewErrorBody(http.StatusForbidden, c.T("Your token expired.")))
+	v.Respond(w, r)
 }
@@ -0,0 +1,333 @@
+// Package twofactor lets a user turn on two-factor authentication with an
+// authenticator app and manage the recovery codes.
+package twofactor
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/middleware/acl"
+	"github.com/pcieslar/goforge/middleware/transaction"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/audit"
+	"github.com/pcieslar/goforge/model/twofactor"
+	"github.com/pcieslar/goforge/model/user"
+
+	"github.com/pcieslar/goforge/core/flash"
+	"github.com/pcieslar/goforge/core/lockout"
+	"github.com/pcieslar/goforge/core/qrcode"
+	"github.com/pcieslar/goforge/core/router"
+	"github.com/pcieslar/goforge/core/totp"
+)
+
+var (
+	uri = "/twofactor"
+)
+
+// Load the routes.
+func Load() {
+	g := router.Group(uri, acl.DisallowAnon)
+	g.Get("", Index).Named("twofactor.index")
+	g.Post("/setup", Store).Named("twofactor.store")
+	g.Get("/setup", Setup).Named("twofactor.setup")
+	g.Post("/setup/confirm", Confirm, transaction.Handler).Named("twofactor.confirm")
+	g.Post("/recovery", Recovery, transaction.Handler).Named("twofactor.recovery")
+	g.Post("/disable", Destroy, transaction.Handler).Named("twofactor.destroy")
+}
+
+// userID returns the ID of the logged in user.
+func userID(c *flight.Info) (uint32, error) {
+	id, err := strconv.ParseUint(c.UserID, 10, 32)
+	return uint32(id), err
+}
+
+// Index displays whether two-factor authentication is turned on.
+func Index(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	id, err := userID(&c)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	enabled, err := twofactor.Enabled(c.GORM, id)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	v := c.View.New("twofactor/index")
+	v.Vars["enabled"] = enabled
+	if enabled {
+		left, err := twofactor.RecoveryCodesLeft(c.GORM, id)
+		if err != nil {
+			c.FlashErrorGeneric(err)
+		}
+		v.Vars["left"] = left
+	}
+	v.Render(w, r)
+}
+
+// Store creates a new secret that must be confirmed with a code.
+func Store(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	id, err := userID(&c)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	secret, err := totp.Secret()
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	err = twofactor.Begin(c.GORM, id, secret)
+	if err == twofactor.ErrEnabled {
+		c.FlashNotice("Two-factor authentication is already enabled.")
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	} else if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	http.Redirect(w, r, uri+"/setup", http.StatusFound)
+}
+
+// Setup displays the secret for the authenticator app and asks for a code to
+// confirm it.
+func Setup(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	id, err := userID(&c)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	item, err := twofactor.ByUserID(c.GORM, id)
+	if err == model.ErrNoResult || (err == nil && item.Enabled()) {
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	} else if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	email, _ := c.Sess.Values["email"].(string)
+	link := c.Config.TOTP.URI(email, item.Secret)
+
+	// Show the link as a QR code for the authenticator app to scan
+	code, err := qrcode.Encode(string(link))
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	// Keep the secret out of the caches
+	w.Header().Set("Cache-Control", "no-store")
+
+	v := c.View.New("twofactor/setup")
+	v.Vars["secret"] = item.Secret
+	v.Vars["uri"] = link
+	v.Vars["qrcode"] = code.DataURI()
+	v.Render(w, r)
+}
+
+// Confirm turns on two-factor authentication when the code matches the new
+// secret and displays the recovery codes.
+func Confirm(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	// Validate with required fields
+	if !c.FormValid("code") {
+		http.Redirect(w, r, uri+"/setup", http.StatusFound)
+		return
+	}
+
+	id, err := userID(&c)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	item, err := twofactor.ByUserID(c.GORM, id)
+	if err == model.ErrNoResult || (err == nil && item.Enabled()) {
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	} else if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	counter, err := c.Config.TOTP.Validate(item.Secret, r.FormValue("code"), time.Now())
+	if err != nil {
+		c.FlashWarning("Code is not valid.")
+		http.Redirect(w, r, uri+"/setup", http.StatusFound)
+		return
+	}
+
+	if err := twofactor.Enable(c.GORM, id, counter); err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	codes, err := twofactor.CreateRecoveryCodes(c.GORM, id)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	c.FlashSuccess("Two-factor authentication is enabled.")
+	showCodes(w, r, codes)
+}
+
+// Recovery replaces the recovery codes after a code is entered and displays
+// the new ones.
+func Recovery(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	id, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := twofactor.CreateRecoveryCodes(c.GORM, id)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	c.FlashSuccess("New recovery codes were created. The old ones no longer work.")
+	showCodes(w, r, codes)
+}
+
+// Destroy turns off two-factor authentication after a code is entered.
+func Destroy(w http.ResponseWriter, r *http.Request) {
+	c := flight.Context(w, r)
+
+	id, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := twofactor.Disable(c.GORM, id); err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return
+	}
+
+	c.FlashNotice("Two-factor authentication is disabled.")
+	http.Redirect(w, r, uri, http.StatusFound)
+}
+
+// authenticate checks the code from the form and redirects back to the index
+// page if it does not match. The codes count as login attempts so they
+// cannot be guessed with a stolen session.
+func authenticate(w http.ResponseWriter, r *http.Request) (uint32, bool) {
+	c := flight.Context(w, r)
+
+	// Validate with required fields
+	if !c.FormValid("code") {
+		http.Redirect(w, r, uri, http.StatusFound)
+		return 0, false
+	}
+
+	id, err := userID(&c)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return 0, false
+	}
+
+	u, err := user.ByID(c.GORM, id)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return 0, false
+	}
+
+	keys := []string{lockout.AccountKey(u.Email), lockout.IPKey(c.IP())}
+	if wait, err := c.Config.Lockout.Check(keys...); err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return 0, false
+	} else if wait > 0 {
+		seconds := int((wait + time.Second - 1) / time.Second)
+		c.Sess.AddFlash(flash.Info{Message: c.TN("Too many failed login attempts. Try again in %d second.", seconds), Class: flash.Warning})
+		c.Sess.Save(r, w)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return 0, false
+	}
+
+	_, err = twofactor.Authenticate(c.GORM, c.Config.TOTP, id, r.FormValue("code"), time.Now())
+	if err == totp.ErrInvalid {
+		c.FlashWarning("Code is not valid.")
+		fail(&c, keys)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return 0, false
+	}
+
+	release(&c, keys)
+	if err != nil {
+		c.FlashErrorGeneric(err)
+		http.Redirect(w, r, uri, http.StatusFound)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// release takes back the attempt that did not fail.
+func release(c *flight.Info, keys []string) {
+	if err := c.Config.Lockout.Release(keys...); err != nil {
+		log.Println("Lockout:", err)
+	}
+}
+
+// fail counts the wrong code and writes the lockouts it causes to the audit
+// log.
+func fail(c *flight.Info, keys []string) {
+	locked, err := c.Config.Lockout.Fail(keys...)
+	if err != nil {
+		log.Println("Lockout:", err)
+	}
+
+	for _, key := range locked {
+		if err := audit.Create(c.GORM, audit.EventLockout, key, "", c.IP()); err != nil {
+			log.Println("Audit:", err)
+		}
+	}
+}
+
+// showCodes displays the recovery codes. They are not stored in plain text
+// so this is the only time the user can see them.
+func showCodes(w http.ResponseWriter, r *http.Request, codes []string) {
+	c := flight.Context(w, r)
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	v := c.View.New("twofactor/recovery")
+	v.Vars["codes"] = codes
+	v.Render(w, r)
+}
@@ -0,0 +1,195 @@
+package twofactor_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/boot"
+	"github.com/pcieslar/goforge/lib/env"
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/lib/gorm"
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/sqlite"
+	"github.com/pcieslar/goforge/model/twofactor"
+	"github.com/pcieslar/goforge/model/user"
+
+	"github.com/pcieslar/goforge/core/passhash"
+	"github.com/pcieslar/goforge/core/router"
+	"github.com/pcieslar/goforge/core/totp"
+)
+
+// setup returns the app with a user with the password "secret".
+func setup(t *testing.T) (http.Handler, *gorm.DB) {
+	db, err := gorm.Open("sqlite3", t.TempDir()+"/test.db?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, q := range []string{
+		`CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, first_name TEXT, last_name TEXT, email TEXT, password TEXT, status_id INT, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`,
+		`CREATE TABLE two_factor (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INT UNIQUE, secret TEXT, last_counter INT NOT NULL DEFAULT 0, enabled_at TIMESTAMP, created_at TIMESTAMP, updated_at TIMESTAMP)`,
+		`CREATE TABLE recovery_code (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INT, code_hash TEXT, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`,
+		`CREATE TABLE audit_log (id INTEGER PRIMARY KEY AUTOINCREMENT, event TEXT, subject TEXT, actor TEXT, ip TEXT, created_at TIMESTAMP)`,
+	} {
+		if err := db.Exec(q).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := passhash.HashString("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.Create(db, "John", "Doe", "jdoe@domain.com", hash); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := env.LoadConfig("../../env.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Asset.Folder = "../../asset"
+	config.I18n.Folder = "../../i18n"
+	config.View.Folder = "../../view"
+	config.Session.Store = "memory"
+
+	// Allow the next attempt right away but lock after three failures
+	config.Lockout.Delay = 0
+	config.Lockout.AccountAttempts = 3
+
+	router.ResetConfig()
+	boot.RegisterServices(config)
+	flight.StoreGORM(db)
+	t.Cleanup(flight.Reset)
+
+	return flight.Handler(router.Instance()), db
+}
+
+// browser keeps the cookies between requests like a web browser.
+type browser struct {
+	h       http.Handler
+	cookies map[string]*http.Cookie
+}
+
+// do sends the request with the form if there is one.
+func (b *browser) do(method, path string, form url.Values) *httptest.ResponseRecorder {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, path, nil)
+	}
+
+	for _, c := range b.cookies {
+		r.AddCookie(c)
+	}
+
+	w := httptest.NewRecorder()
+	b.h.ServeHTTP(w, r)
+
+	if b.cookies == nil {
+		b.cookies = make(map[string]*http.Cookie)
+	}
+	for _, c := range w.Result().Cookies() {
+		b.cookies[c.Name] = c
+	}
+
+	return w
+}
+
+// login returns a browser with the user logged in.
+func login(t *testing.T, h http.Handler) *browser {
+	b := &browser{h: h}
+	w := b.do("POST", "/login", url.Values{"email": {"jdoe@domain.com"}, "password": {"secret"}})
+	if w.Header().Get("Location") != "/" {
+		t.Fatalf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/")
+	}
+	return b
+}
+
+// TestSetup ensures the page shows the QR code and a link the authenticator
+// app can open.
+func TestSetup(t *testing.T) {
+	h, _ := setup(t)
+	b := login(t, h)
+
+	if w := b.do("POST", "/twofactor/setup", url.Values{}); w.Header().Get("Location") != "/twofactor/setup" {
+		t.Fatalf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/twofactor/setup")
+	}
+
+	w := b.do("GET", "/twofactor/setup", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("\nactual: %v\nexpected: %v", w.Code, http.StatusOK)
+	}
+
+	body := w.Body.String()
+	for _, expected := range []string{
+		`<img src="data:image/svg&#43;xml;base64,`,
+		`<a href="otpauth://totp/Blueprint:jdoe@domain.com?`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("\nactual: %v\nexpected: %v", body, expected)
+		}
+	}
+	if strings.Contains(body, "ZgotmplZ") {
+		t.Error("link should not be replaced by html/template")
+	}
+}
+
+// TestLockout ensures the codes to turn off two-factor authentication cannot
+// be guessed.
+func TestLockout(t *testing.T) {
+	h, db := setup(t)
+	b := login(t, h)
+
+	u, err := user.ByEmail(db, "jdoe@domain.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := totp.Secret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := twofactor.Begin(db, u.ID, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := twofactor.Enable(db, u.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := totp.Info{}.Code(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 0; i < 3; i++ {
+		b.do("POST", "/twofactor/disable", url.Values{"code": {wrong}})
+	}
+
+	// The right code is refused while the account is locked
+	if w := b.do("POST", "/twofactor/disable", url.Values{"code": {code}}); w.Header().Get("Location") != "/twofactor" {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/twofactor")
+	}
+	if enabled, err := twofactor.Enabled(db, u.ID); err != nil {
+		t.Fatal(err)
+	} else if !enabled {
+		t.Error("two-factor authentication should still be enabled")
+	}
+
+	var locks int
+	if err := db.Raw("SELECT COUNT(*) FROM audit_log WHERE event = 'lockout'").Row().Scan(&locks); err != nil {
+		t.Fatal(err)
+	}
+	if locks != 1 {
+		t.Errorf("\nactual: %v\nexpected: %v", locks, 1)
+	}
+}
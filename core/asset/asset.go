@@ -4,17 +4,28 @@ package asset
 
 import (
 	"fmt"
+	"hash/crc32"
 	"html/template"
+	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // Info holds the config.
 type Info struct {
 	// Folder is the parent folder path for the asset folder
 	Folder string
+
+	// FS contains the assets instead of the disk when it is set like an
+	// embed.FS. The Folder is the path in the FS.
+	FS fs.FS `json:"-"`
+
+	// sums caches the checksums of the files in the FS by name
+	sums *sync.Map
 }
 
 // *****************************************************************************
@@ -26,6 +37,11 @@ type Info struct {
 func (c Info) Map(baseURI string) template.FuncMap {
 	f := make(template.FuncMap)
 
+	// Share the checksums between the functions and every render
+	if c.sums == nil {
+		c.sums = new(sync.Map)
+	}
+
 	f["JS"] = func(fpath string) template.HTML {
 		path, err := c.assetTimePath(baseURI, fpath)
 
@@ -77,6 +93,15 @@ func (c Info) assetTimePath(baseURI, resource string) (string, error) {
 
 	resource = strings.TrimLeft(resource, "/")
 
+	if c.FS != nil {
+		time, err := c.fsFileTime(path.Join(c.Folder, resource))
+		if err != nil {
+			return "", err
+		}
+
+		return baseURI + resource + "?" + time, nil
+	}
+
 	abs, err := filepath.Abs(filepath.Join(c.Folder, resource))
 	if err != nil {
 		return "", err
@@ -99,3 +124,35 @@ func fileTime(name string) (string, error) {
 	mtime := fi.ModTime().Unix()
 	return fmt.Sprintf("%v", mtime), nil
 }
+
+// fsFileTime returns the modification time of the file in the FS. Files in an
+// embed.FS have no modification time so a checksum of the contents is
+// returned instead. They never change so the checksum is only computed once.
+func (c Info) fsFileTime(name string) (string, error) {
+	if c.sums != nil {
+		if sum, ok := c.sums.Load(name); ok {
+			return sum.(string), nil
+		}
+	}
+
+	fi, err := fs.Stat(c.FS, name)
+	if err != nil {
+		return "", err
+	}
+
+	if !fi.ModTime().IsZero() {
+		return fmt.Sprintf("%v", fi.ModTime().Unix()), nil
+	}
+
+	b, err := fs.ReadFile(c.FS, name)
+	if err != nil {
+		return "", err
+	}
+
+	sum := fmt.Sprintf("%x", crc32.ChecksumIEEE(b))
+	if c.sums != nil {
+		c.sums.Store(name, sum)
+	}
+
+	return sum, nil
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/pcieslar/goforge/core/asset"
 )
@@ -312,3 +313,68 @@ func TestBaseURI(t *testing.T) {
 		t.Errorf("\n got: %v\nwant: %v", received, expected)
 	}
 }
+
+// TestCSSFS ensures CSS from an FS without modification times has a
+// checksum of the contents.
+func TestCSSFS(t *testing.T) {
+	config := asset.Info{
+		Folder: "asset",
+		FS: fstest.MapFS{
+			"asset/test.css": {Data: []byte("body {}")},
+		},
+	}
+
+	fm := config.Map("/")
+
+	temp, err := template.New("test").Funcs(fm).Parse(`{{CSS "/test.css" "all"}}{{JS "/missing.js"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+
+	err = temp.Execute(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<link media="all" rel="stylesheet" type="text/css" href="/test.css?d850eafa" /><!-- JS Error: /missing.js -->`
+	received := buf.String()
+
+	if received != expected {
+		t.Errorf("\n got: %v\nwant: %v", received, expected)
+	}
+}
+
+// TestCSSFSCache ensures the checksum of a file in an FS without modification
+// times is only computed once.
+func TestCSSFSCache(t *testing.T) {
+	files := fstest.MapFS{
+		"asset/test.css": {Data: []byte("body {}")},
+	}
+	config := asset.Info{
+		Folder: "asset",
+		FS:     files,
+	}
+
+	temp, err := template.New("test").Funcs(config.Map("/")).Parse(`{{CSS "/test.css" "all"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<link media="all" rel="stylesheet" type="text/css" href="/test.css?d850eafa" />`
+
+	for i := 0; i < 2; i++ {
+		buf := new(bytes.Buffer)
+		if err := temp.Execute(buf, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if received := buf.String(); received != expected {
+			t.Errorf("\n got: %v\nwant: %v", received, expected)
+		}
+
+		// The contents are not read again
+		files["asset/test.css"].Data = []byte("p {}")
+	}
+}
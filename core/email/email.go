@@ -5,8 +5,12 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/smtp"
+	"sync"
 )
 
+// pending counts the emails being sent in the background.
+var pending sync.WaitGroup
+
 // Info holds the details for the SMTP server.
 type Info struct {
 	Username string
@@ -33,8 +37,11 @@ func header(c Info, to, subject, body string) map[string]string {
 
 // Send an email.
 func (c Info) Send(to, subject, body string) error {
-	// Authentication for SMTP
-	auth := smtp.PlainAuth("", c.Username, c.Password, c.Hostname)
+	// Authentication for SMTP, skipped for a server without a login
+	var auth smtp.Auth
+	if len(c.Username) > 0 {
+		auth = smtp.PlainAuth("", c.Username, c.Password, c.Hostname)
+	}
 
 	// Create header
 	header := header(c, to, subject, body)
@@ -57,3 +64,19 @@ func (c Info) Send(to, subject, body string) error {
 
 	return err
 }
+
+// SendLater sends an email in the background so the caller does not wait for
+// the SMTP server. The done function is called with the result.
+func (c Info) SendLater(to, subject, body string, done func(error)) {
+	pending.Add(1)
+	go func() {
+		defer pending.Done()
+		done(c.Send(to, subject, body))
+	}()
+}
+
+// Wait blocks until the emails sent with SendLater are finished. Call it
+// before the program exits so they are not lost.
+func Wait() {
+	pending.Wait()
+}
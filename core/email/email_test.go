@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/pcieslar/goforge/core/email"
+	"github.com/pcieslar/goforge/core/email/smtptest"
 )
 
 // TestEmailFail ensures email fails.
@@ -22,19 +23,84 @@ func TestEmailFail(t *testing.T) {
 	}
 }
 
-// TestEmailSuccess ensures email succeeds.
-// Add your own working code here.
-/*func TestEmailSuccess(t *testing.T) {
+// TestEmailSuccess ensures email is sent to the SMTP server.
+func TestEmailSuccess(t *testing.T) {
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
 	config := email.Info{
-		Username: "",
-		Password: "",
-		Hostname: "127.0.0.1",
-		Port:     25,
+		Username: "user",
+		Password: "secret",
+		Hostname: server.Hostname,
+		Port:     server.Port,
 		From:     "from@example.com",
 	}
 
-	err := config.Send("to@example.com", "Subject", "Body")
+	err = config.Send("to@example.com", "Subject", "<p>Body</p>")
+	if err != nil {
+		t.Fatalf("Error not expected: %v", err)
+	}
+
+	// Send without a login too
+	config.Username = ""
+	err = config.Send("other@example.com", "Other", "Body")
+	if err != nil {
+		t.Fatalf("Error not expected: %v", err)
+	}
+
+	list := server.Messages()
+	if len(list) != 2 {
+		t.Fatalf("\nactual: %v\nexpected: %v", len(list), 2)
+	}
+
+	m := list[0]
+	if m.From != "from@example.com" {
+		t.Errorf("\nactual: %v\nexpected: %v", m.From, "from@example.com")
+	}
+	if len(m.To) != 1 || m.To[0] != "to@example.com" {
+		t.Errorf("\nactual: %v\nexpected: %v", m.To, []string{"to@example.com"})
+	}
+	if s := m.Header("Subject"); s != "Subject" {
+		t.Errorf("\nactual: %v\nexpected: %v", s, "Subject")
+	}
+
+	body, err := m.Body()
 	if err != nil {
-		t.Errorf("Error not expected: %v", err)
+		t.Fatal(err)
 	}
-}*/
+	if body != "<p>Body</p>" {
+		t.Errorf("\nactual: %v\nexpected: %v", body, "<p>Body</p>")
+	}
+}
+
+// TestSendLater ensures the email is sent in the background and Wait returns
+// after it is finished.
+func TestSendLater(t *testing.T) {
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	config := email.Info{
+		Hostname: server.Hostname,
+		Port:     server.Port,
+		From:     "from@example.com",
+	}
+
+	var sendErr error
+	config.SendLater("to@example.com", "Subject", "Body", func(err error) {
+		sendErr = err
+	})
+	email.Wait()
+
+	if sendErr != nil {
+		t.Fatalf("Error not expected: %v", sendErr)
+	}
+	if list := server.Messages(); len(list) != 1 {
+		t.Fatalf("\nactual: %v\nexpected: %v", len(list), 1)
+	}
+}
@@ -0,0 +1,232 @@
+// Package smtptest provides an SMTP server for tests that keeps the messages
+// in memory instead of delivering them.
+package smtptest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Message is an email received by the server.
+type Message struct {
+	From string   // Sender from the MAIL command
+	To   []string // Recipients from the RCPT commands
+	Data []byte   // Headers and body as sent
+}
+
+// Header returns the value of the header.
+func (m Message) Header(key string) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Data))
+	if err != nil {
+		return ""
+	}
+
+	return msg.Header.Get(key)
+}
+
+// Body returns the body decoded from base64 when it is encoded.
+func (m Message) Body() (string, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Data))
+	if err != nil {
+		return "", err
+	}
+
+	b, err := ioutil.ReadAll(msg.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.EqualFold(msg.Header.Get("Content-Transfer-Encoding"), "base64") {
+		b, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return string(b), nil
+}
+
+// Server is an SMTP server listening on the loopback interface. It accepts
+// any login and any recipient.
+type Server struct {
+	Hostname string // Address to connect to
+	Port     int    // Port to connect to
+
+	listener net.Listener
+	messages []Message
+	mutex    sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on a random port. Call Close when done.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Hostname: "127.0.0.1",
+		Port:     l.Addr().(*net.TCPAddr).Port,
+		listener: l,
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the host and port of the server.
+func (s *Server) Addr() string {
+	return net.JoinHostPort(s.Hostname, strconv.Itoa(s.Port))
+}
+
+// Messages returns the messages received so far.
+func (s *Server) Messages() []Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	list := make([]Message, len(s.messages))
+	copy(list, s.messages)
+
+	return list
+}
+
+// Close stops the server and waits for the connections to finish.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+
+	return err
+}
+
+// serve accepts the connections until the listener is closed.
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle answers the commands of one connection.
+func (s *Server) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	reply := func(line string) bool {
+		w.WriteString(line + "\r\n")
+		return w.Flush() == nil
+	}
+
+	if !reply("220 smtptest ready") {
+		return
+	}
+
+	var msg Message
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		cmd := strings.ToUpper(line)
+		if i := strings.IndexByte(cmd, ' '); i >= 0 {
+			cmd = cmd[:i]
+		}
+
+		switch cmd {
+		case "EHLO":
+			reply("250-smtptest")
+			reply("250 AUTH PLAIN")
+		case "HELO":
+			reply("250 smtptest")
+		case "AUTH":
+			reply("235 Authentication successful")
+		case "MAIL":
+			msg = Message{From: address(line)}
+			reply("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, address(line))
+			reply("250 OK")
+		case "DATA":
+			if !reply("354 End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			msg.Data = data
+
+			s.mutex.Lock()
+			s.messages = append(s.messages, msg)
+			s.mutex.Unlock()
+
+			msg = Message{}
+			reply("250 OK")
+		case "RSET":
+			msg = Message{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// address returns the address between the angle brackets of a MAIL or RCPT
+// command.
+func address(line string) string {
+	start := strings.IndexByte(line, '<')
+	end := strings.LastIndexByte(line, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+
+	return line[start+1 : end]
+}
+
+// readData reads the message until the line with a single dot and removes
+// the dot stuffing.
+func readData(r *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return buf.Bytes(), nil
+		}
+		if strings.HasPrefix(line, ".") {
+			line = line[1:]
+		}
+		buf.WriteString(line)
+	}
+}
@@ -0,0 +1,307 @@
+// Package i18n translates messages with catalogs for each locale and chooses
+// the locale for a request.
+//
+// A catalog is a JSON file named after the locale like pl.json. The keys are
+// the English messages so a missing translation shows the key. A message with
+// plural forms is an object keyed by the CLDR plural category:
+//
+//	{
+//		"Item added.": "Dodano element.",
+//		"%d note": {"one": "%d notatka", "few": "%d notatki", "many": "%d notatek"}
+//	}
+package i18n
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNoDefault is when there is no catalog for the default locale.
+	ErrNoDefault = errors.New("Catalog for the default locale is missing.")
+)
+
+// Info holds the details for the catalogs.
+type Info struct {
+	Folder  string `json:"Folder"`  // Folder with a JSON catalog for each locale
+	Default string `json:"Default"` // Locale used when none of the requested ones match
+	Cookie  string `json:"Cookie"`  // Name of the cookie with the chosen locale
+
+	// FS contains the catalogs instead of the disk when it is set like an
+	// embed.FS. The Folder is the path in the FS.
+	FS fs.FS `json:"-"`
+}
+
+// Message is a translation with an optional plural form for each category.
+type Message struct {
+	Text  string
+	Forms map[string]string
+}
+
+// UnmarshalJSON reads a string or an object of plural forms.
+func (m *Message) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &m.Text); err == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(b, &m.Forms); err != nil {
+		return err
+	}
+
+	m.Text = m.Forms[Other]
+	return nil
+}
+
+// Catalog contains the messages for a locale by the English message.
+type Catalog map[string]Message
+
+// Bundle holds the catalogs. A nil Bundle returns the messages untranslated.
+type Bundle struct {
+	def      string
+	catalogs map[string]Catalog
+}
+
+// New returns a bundle with the catalogs by locale.
+func New(def string, catalogs map[string]Catalog) *Bundle {
+	b := &Bundle{
+		def:      normalize(def),
+		catalogs: make(map[string]Catalog),
+	}
+
+	for locale, c := range catalogs {
+		b.catalogs[normalize(locale)] = c
+	}
+
+	return b
+}
+
+// Load reads every catalog in the folder. A nil Bundle is returned when there
+// is no folder so the messages are not translated.
+func (c Info) Load() (*Bundle, error) {
+	if len(c.Folder) == 0 {
+		return nil, nil
+	}
+
+	fsys, folder := c.FS, c.Folder
+	if fsys == nil {
+		fsys, folder = os.DirFS(c.Folder), "."
+	}
+
+	files, err := fs.Glob(fsys, path.Join(folder, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	catalogs := make(map[string]Catalog)
+	for _, name := range files {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		var catalog Catalog
+		if err := json.Unmarshal(b, &catalog); err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err)
+		}
+
+		catalogs[strings.TrimSuffix(path.Base(name), ".json")] = catalog
+	}
+
+	b := New(c.Default, catalogs)
+	if _, ok := b.catalogs[b.def]; !ok {
+		return nil, ErrNoDefault
+	}
+
+	return b, nil
+}
+
+// Default returns the default locale.
+func (b *Bundle) Default() string {
+	if b == nil {
+		return ""
+	}
+
+	return b.def
+}
+
+// Locales returns the locales with a catalog in alphabetical order.
+func (b *Bundle) Locales() []string {
+	if b == nil {
+		return nil
+	}
+
+	list := make([]string, 0, len(b.catalogs))
+	for locale := range b.catalogs {
+		list = append(list, locale)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// Match returns the first supported locale from the preferences in order.
+// Each preference can be a single locale or an Accept-Language header. A
+// language like pl-PL matches pl when there is no catalog for the region.
+// The default locale is returned if nothing matches.
+func (b *Bundle) Match(preferences ...string) string {
+	if b == nil {
+		return ""
+	}
+
+	for _, p := range preferences {
+		for _, tag := range parseAcceptLanguage(p) {
+			if _, ok := b.catalogs[tag]; ok {
+				return tag
+			}
+			if i := strings.Index(tag, "-"); i > 0 {
+				if _, ok := b.catalogs[tag[:i]]; ok {
+					return tag[:i]
+				}
+			}
+		}
+	}
+
+	return b.def
+}
+
+// Translate returns the message for the locale. The args are formatted into
+// the message like fmt.Sprintf.
+func (b *Bundle) Translate(locale, key string, args ...interface{}) string {
+	text := key
+	if m, ok := b.message(locale, key); ok && len(m.Text) > 0 {
+		text = m.Text
+	}
+
+	return format(text, args)
+}
+
+// Plural returns the message for the locale in the plural form for the count.
+// The count is formatted into the message when there are no args.
+func (b *Bundle) Plural(locale, key string, n int, args ...interface{}) string {
+	if len(args) == 0 {
+		args = []interface{}{n}
+	}
+
+	text := key
+	if m, ok := b.message(locale, key); ok {
+		if form, ok := m.Forms[pluralCategory(locale, n)]; ok {
+			text = form
+		} else if len(m.Text) > 0 {
+			text = m.Text
+		}
+	}
+
+	return format(text, args)
+}
+
+// message returns the message from the catalog for the locale, its language,
+// and then the default catalog.
+func (b *Bundle) message(locale, key string) (Message, bool) {
+	if b == nil {
+		return Message{}, false
+	}
+
+	locale = normalize(locale)
+	lang := locale
+	if i := strings.Index(locale, "-"); i > 0 {
+		lang = locale[:i]
+	}
+
+	for _, l := range []string{locale, lang, b.def} {
+		if m, ok := b.catalogs[l][key]; ok {
+			return m, true
+		}
+	}
+
+	return Message{}, false
+}
+
+// Map returns a template.FuncMap for T and TN. Pass the view variables so the
+// locale is read from them:
+//
+//	{{T "About" .}}
+//	{{TN "%d note" . .count}}
+func (b *Bundle) Map() template.FuncMap {
+	f := make(template.FuncMap)
+
+	f["T"] = func(key string, m map[string]interface{}, args ...interface{}) string {
+		return b.Translate(localeOf(m), key, args...)
+	}
+
+	f["TN"] = func(key string, m map[string]interface{}, n int, args ...interface{}) string {
+		return b.Plural(localeOf(m), key, n, args...)
+	}
+
+	return f
+}
+
+// localeOf returns the locale from the view variables.
+func localeOf(m map[string]interface{}) string {
+	s, _ := m["locale"].(string)
+	return s
+}
+
+// format formats the args into the text if there are any.
+func format(text string, args []interface{}) string {
+	if len(args) == 0 {
+		return text
+	}
+
+	return fmt.Sprintf(text, args...)
+}
+
+// normalize returns the locale in lowercase with a hyphen like pt-br.
+func normalize(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// parseAcceptLanguage returns the locales in order of the quality values. A
+// single locale is returned as is.
+func parseAcceptLanguage(header string) []string {
+	type tag struct {
+		name string
+		q    float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := normalize(fields[0])
+		if len(name) == 0 || name == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q > 0 {
+			tags = append(tags, tag{name, q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	list := make([]string, len(tags))
+	for i, t := range tags {
+		list[i] = t.name
+	}
+
+	return list
+}
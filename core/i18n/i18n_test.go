@@ -0,0 +1,144 @@
+package i18n_test
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+
+	"github.com/pcieslar/goforge/core/i18n"
+)
+
+// load returns the bundle from the testdata folder.
+func load(t *testing.T) *i18n.Bundle {
+	b, err := i18n.Info{Folder: "testdata", Default: "en"}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// TestLoad ensures the catalogs are read and the default is required.
+func TestLoad(t *testing.T) {
+	b := load(t)
+
+	if locales := b.Locales(); len(locales) != 2 || locales[0] != "en" || locales[1] != "pl" {
+		t.Fatalf("\nactual: %v\nexpected: %v", locales, []string{"en", "pl"})
+	}
+
+	_, err := i18n.Info{Folder: "testdata", Default: "de"}.Load()
+	if err != i18n.ErrNoDefault {
+		t.Fatalf("\nactual: %v\nexpected: %v", err, i18n.ErrNoDefault)
+	}
+
+	b, err = i18n.Info{}.Load()
+	if b != nil || err != nil {
+		t.Fatalf("\nactual: %v, %v\nexpected: %v, %v", b, err, nil, nil)
+	}
+}
+
+// TestMatch ensures the first supported locale is chosen.
+func TestMatch(t *testing.T) {
+	b := load(t)
+
+	tests := []struct {
+		preferences []string
+		expected    string
+	}{
+		{[]string{"", "", "de-DE,pl;q=0.8,en;q=0.9"}, "en"},
+		{[]string{"", "", "de-DE,pl;q=0.9,en;q=0.8"}, "pl"},
+		{[]string{"", "", "pl-PL"}, "pl"},
+		{[]string{"", "pl", "en"}, "pl"},
+		{[]string{"en", "pl", "pl"}, "en"},
+		{[]string{"", "xx", "en;q=0"}, "en"},
+		{[]string{"", "", "*"}, "en"},
+	}
+
+	for _, tt := range tests {
+		if received := b.Match(tt.preferences...); received != tt.expected {
+			t.Errorf("\n%v\nactual: %v\nexpected: %v", tt.preferences, received, tt.expected)
+		}
+	}
+}
+
+// TestTranslate ensures the message falls back to the default catalog and then
+// the key.
+func TestTranslate(t *testing.T) {
+	b := load(t)
+
+	tests := []struct {
+		locale   string
+		key      string
+		args     []interface{}
+		expected string
+	}{
+		{"pl", "Hello", nil, "Cześć"},
+		{"pl", "Hello %v", []interface{}{"Jan"}, "Cześć Jan"},
+		{"en", "Hello %v", []interface{}{"Jan"}, "Hello Jan"},
+		{"de", "Hello", nil, "Hello"},
+		{"pl", "100%", nil, "100%"},
+	}
+
+	for _, tt := range tests {
+		if received := b.Translate(tt.locale, tt.key, tt.args...); received != tt.expected {
+			t.Errorf("\nactual: %v\nexpected: %v", received, tt.expected)
+		}
+	}
+
+	// A nil bundle returns the key
+	var nilBundle *i18n.Bundle
+	if received := nilBundle.Translate("pl", "Hello %v", "Jan"); received != "Hello Jan" {
+		t.Errorf("\nactual: %v\nexpected: %v", received, "Hello Jan")
+	}
+}
+
+// TestPlural ensures the plural form is chosen by the rules of the language.
+func TestPlural(t *testing.T) {
+	b := load(t)
+
+	tests := []struct {
+		locale   string
+		n        int
+		expected string
+	}{
+		{"en", 1, "1 note"},
+		{"en", 0, "0 notes"},
+		{"en", 2, "2 notes"},
+		{"pl", 1, "1 notatka"},
+		{"pl", 3, "3 notatki"},
+		{"pl", 5, "5 notatek"},
+		{"pl", 12, "12 notatek"},
+		{"pl", 22, "22 notatki"},
+		{"pl-PL", 25, "25 notatek"},
+	}
+
+	for _, tt := range tests {
+		if received := b.Plural(tt.locale, "%d note", tt.n); received != tt.expected {
+			t.Errorf("\nactual: %v\nexpected: %v", received, tt.expected)
+		}
+	}
+}
+
+// TestMap ensures the template funcs use the locale from the variables.
+func TestMap(t *testing.T) {
+	b := load(t)
+
+	temp, err := template.New("test").Funcs(b.Map()).Parse(`{{T "Hello" .}} {{TN "%d note" . .count}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	err = temp.Execute(buf, map[string]interface{}{
+		"locale": "pl",
+		"count":  4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Cześć 4 notatki"
+	if received := buf.String(); received != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", received, expected)
+	}
+}
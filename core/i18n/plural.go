@@ -0,0 +1,103 @@
+package i18n
+
+import (
+	"strings"
+)
+
+// The CLDR plural categories.
+const (
+	Zero  = "zero"
+	One   = "one"
+	Two   = "two"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// pluralRule returns the plural category for a count.
+type pluralRule func(n int) string
+
+// pluralRules contains the rules for the integer counts by language. Languages
+// that are not listed use the English rule.
+var pluralRules = map[string]pluralRule{
+	"cs": czech,
+	"fr": french,
+	"ja": none,
+	"pl": polish,
+	"ru": russian,
+	"sk": czech,
+	"uk": russian,
+	"zh": none,
+}
+
+// pluralCategory returns the plural category of the count for the locale.
+func pluralCategory(locale string, n int) string {
+	lang := normalize(locale)
+	if i := strings.Index(lang, "-"); i > 0 {
+		lang = lang[:i]
+	}
+
+	if n < 0 {
+		n = -n
+	}
+
+	if rule, ok := pluralRules[lang]; ok {
+		return rule(n)
+	}
+
+	return english(n)
+}
+
+// english has a form for one and for every other count.
+func english(n int) string {
+	if n == 1 {
+		return One
+	}
+	return Other
+}
+
+// french uses the singular for zero too.
+func french(n int) string {
+	if n == 0 || n == 1 {
+		return One
+	}
+	return Other
+}
+
+// none has a single form.
+func none(n int) string {
+	return Other
+}
+
+// czech has a form for two to four.
+func czech(n int) string {
+	switch {
+	case n == 1:
+		return One
+	case n >= 2 && n <= 4:
+		return Few
+	}
+	return Other
+}
+
+// polish uses the last digits except for 12 to 14.
+func polish(n int) string {
+	switch {
+	case n == 1:
+		return One
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return Few
+	}
+	return Many
+}
+
+// russian uses the last digits except for 11 to 14.
+func russian(n int) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return One
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return Few
+	}
+	return Many
+}
@@ -0,0 +1,117 @@
+package lockout
+
+import (
+	"time"
+
+	"github.com/pcieslar/goforge/lib/gorm"
+)
+
+// DatabaseBackend keeps the failures in the login_attempt table with any of
+// the gorm dialects like MySQL or Postgres so they are shared by every
+// instance of the application.
+type DatabaseBackend struct {
+	DB *gorm.DB
+}
+
+// NewDatabaseBackend returns a backend for the database connection.
+func NewDatabaseBackend(db *gorm.DB) *DatabaseBackend {
+	return &DatabaseBackend{
+		DB: db,
+	}
+}
+
+// Load returns the failures.
+func (b *DatabaseBackend) Load(key string) (Record, error) {
+	var r Record
+
+	err := b.DB.Where("attempt_key = ?", key).First(&r).Error
+	if err == gorm.ErrRecordNotFound {
+		return r, ErrNotFound
+	}
+
+	return r, err
+}
+
+// Save stores the failures in a single statement.
+func (b *DatabaseBackend) Save(r Record) error {
+	query := "INSERT INTO login_attempt (attempt_key, failures, last_failure, locked_until, expires_at) VALUES (?, ?, ?, ?, ?) "
+	if b.DB.Dialect().GetName() == "mysql" {
+		query += "ON DUPLICATE KEY UPDATE failures = VALUES(failures), last_failure = VALUES(last_failure), locked_until = VALUES(locked_until), expires_at = VALUES(expires_at)"
+	} else {
+		query += "ON CONFLICT (attempt_key) DO UPDATE SET failures = excluded.failures, last_failure = excluded.last_failure, locked_until = excluded.locked_until, expires_at = excluded.expires_at"
+	}
+
+	return b.DB.Exec(query, r.Key, r.Failures, r.LastFailure, r.LockedUntil, r.ExpiresAt).Error
+}
+
+// Add counts a failure for the key and saves the changes fn makes to the
+// record. The failure is added with an upsert that locks the row so requests
+// from every instance wait for each other.
+func (b *DatabaseBackend) Add(key string, fn func(r *Record)) (Record, error) {
+	query := "INSERT INTO login_attempt (attempt_key, failures) VALUES (?, 1) "
+	if b.DB.Dialect().GetName() == "mysql" {
+		query += "ON DUPLICATE KEY UPDATE failures = failures + 1"
+	} else {
+		query += "ON CONFLICT (attempt_key) DO UPDATE SET failures = login_attempt.failures + 1"
+	}
+
+	return b.change(query, key, fn)
+}
+
+// Update saves the changes fn makes to the record of the key.
+func (b *DatabaseBackend) Update(key string, fn func(r *Record)) (Record, error) {
+	return b.change("UPDATE login_attempt SET failures = failures WHERE attempt_key = ?", key, fn)
+}
+
+// change runs the query that locks the row of the key and then saves the
+// changes fn makes to it in the same transaction.
+func (b *DatabaseBackend) change(lock string, key string, fn func(r *Record)) (Record, error) {
+	var r Record
+
+	tx := b.DB.Begin()
+	if tx.Error != nil {
+		return r, tx.Error
+	}
+
+	err := tx.Exec(lock, key).Error
+	if err == nil {
+		err = tx.Where("attempt_key = ?", key).First(&r).Error
+		if err == gorm.ErrRecordNotFound {
+			err = ErrNotFound
+		}
+	}
+
+	if err == nil {
+		fn(&r)
+		err = tx.Model(&Record{}).Where("attempt_key = ?", key).Updates(map[string]interface{}{
+			"failures":     r.Failures,
+			"last_failure": r.LastFailure,
+			"locked_until": r.LockedUntil,
+			"expires_at":   r.ExpiresAt,
+		}).Error
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return r, err
+	}
+
+	return r, tx.Commit().Error
+}
+
+// Delete removes the failures.
+func (b *DatabaseBackend) Delete(key string) error {
+	return b.DB.Where("attempt_key = ?", key).Delete(&Record{}).Error
+}
+
+// DeleteExpired removes the failures that expired before the time.
+func (b *DatabaseBackend) DeleteExpired(now time.Time) error {
+	return b.DB.Where("expires_at < ?", now).Delete(&Record{}).Error
+}
+
+// Locked returns the records that are locked at the time.
+func (b *DatabaseBackend) Locked(now time.Time) ([]Record, error) {
+	var list []Record
+	err := b.DB.Where("locked_until > ?", now).Order("locked_until").Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,378 @@
+// Package lockout slows down and then stops repeated failed logins. The
+// failures are counted for each account and each IP address. After each
+// failure the next attempt must wait twice as long as the one before and
+// after too many failures the account or IP address is locked for a while.
+// The emails to reset a password or verify an address are limited for each
+// address the same way.
+//
+// The IP address is the one of the connection so behind a reverse proxy every
+// client shares one address. List the proxy in Server.TrustedProxies so the
+// address from X-Forwarded-For is used instead.
+package lockout
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/gorm"
+)
+
+// The stores that can be chosen with Info.Store.
+const (
+	StoreDatabase = "database"
+	StoreMemory   = "memory"
+)
+
+// The prefixes of the keys for the kinds of attempts.
+const (
+	accountPrefix = "account:"
+	ipPrefix      = "ip:"
+	resetPrefix   = "reset:"
+	verifyPrefix  = "verify:"
+)
+
+// maxDoublings stops the delay from overflowing when there is no MaxDelay.
+const maxDoublings = 30
+
+var (
+	// ErrNotFound is when there are no failures for the key.
+	ErrNotFound = errors.New("Login attempts not found.")
+
+	// ErrStore is when the store is not supported.
+	ErrStore = errors.New("Lockout store must be database or memory.")
+
+	// ErrNoDatabase is when the database store has no connection.
+	ErrNoDatabase = errors.New("Lockout database connection is missing.")
+)
+
+// Record holds the failures for an account or IP address.
+type Record struct {
+	Key         string    `gorm:"column:attempt_key;primary_key"` // Account or IP address like "ip:127.0.0.1"
+	Failures    int       // Failures since the last lockout
+	LastFailure time.Time // Time of the last failure
+	LockedUntil time.Time // Time the lockout ends
+	ExpiresAt   time.Time // Time the record can be removed
+}
+
+// TableName for the login_attempt table.
+func (Record) TableName() string {
+	return "login_attempt"
+}
+
+// Locked returns true if the key is locked at the time.
+func (r Record) Locked(now time.Time) bool {
+	return r.LockedUntil.After(now)
+}
+
+// Backend loads and stores the failures. Add and Update change a record
+// atomically so the failures from every request are counted.
+type Backend interface {
+	Load(key string) (Record, error)
+	Save(r Record) error
+	Add(key string, fn func(r *Record)) (Record, error)
+	Update(key string, fn func(r *Record)) (Record, error)
+	Delete(key string) error
+	DeleteExpired(now time.Time) error
+	Locked(now time.Time) ([]Record, error)
+}
+
+// Info holds the lockout settings.
+type Info struct {
+	Store           string `json:"Store"`           // Where the failures are kept: database or memory
+	AccountAttempts int    `json:"AccountAttempts"` // Failures for an account before it is locked, 0 to never lock
+	IPAttempts      int    `json:"IPAttempts"`      // Failures from an IP address before it is locked, 0 to never lock
+	ResetAttempts   int    `json:"ResetAttempts"`   // Reset emails to an address before it is locked, 0 to never lock
+	VerifyAttempts  int    `json:"VerifyAttempts"`  // Verification emails to an address before it is locked, 0 to never lock
+	Delay           int    `json:"Delay"`           // Seconds to wait after the first failure, doubled after each one
+	MaxDelay        int    `json:"MaxDelay"`        // Most seconds to wait between attempts, 0 for no limit
+	Lockout         int    `json:"Lockout"`         // Seconds an account or IP address stays locked
+	Window          int    `json:"Window"`          // Seconds after the last failure when the count starts over
+	Cleanup         int    `json:"Cleanup"`         // Seconds between removing the old failures
+	backend         Backend
+	db              *gorm.DB
+}
+
+// AccountKey returns the key for the failures of the account.
+func AccountKey(email string) string {
+	return accountPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPKey returns the key for the failures from the IP address.
+func IPKey(ip string) string {
+	return ipPrefix + ip
+}
+
+// ResetKey returns the key for the password reset emails to the address.
+func ResetKey(email string) string {
+	return resetPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+// VerifyKey returns the key for the verification emails to the address.
+func VerifyKey(email string) string {
+	return verifyPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+// SetDB sets the connection for the database store. Call it before
+// SetupConfig.
+func (i *Info) SetDB(db *gorm.DB) {
+	i.db = db
+}
+
+// SetupConfig creates the store and returns an error if it cannot be setup.
+func (i *Info) SetupConfig() error {
+	switch i.Store {
+	case "", StoreMemory:
+		i.backend = NewMemoryBackend()
+	case StoreDatabase:
+		if i.db == nil {
+			return ErrNoDatabase
+		}
+		i.backend = NewDatabaseBackend(i.db)
+	default:
+		return ErrStore
+	}
+
+	return nil
+}
+
+// Check counts an attempt for all the keys and returns how long to wait
+// before it is allowed. Zero means the attempt is allowed and counts as a
+// failure until Release or Succeed is called, so attempts made at the same
+// time wait for each other. An attempt that must wait is not counted.
+func (i *Info) Check(keys ...string) (time.Duration, error) {
+	if i.backend == nil {
+		return 0, nil
+	}
+
+	var wait time.Duration
+	var counted []string
+	for _, key := range keys {
+		var d time.Duration
+		_, err := i.backend.Add(key, func(r *Record) {
+			now := time.Now()
+
+			// The record already counts this attempt
+			r.Failures--
+			if r.Locked(now) {
+				d = r.LockedUntil.Sub(now)
+				return
+			}
+
+			// The count starts over after a lockout or the window
+			if !r.LockedUntil.IsZero() || i.expired(*r, now) {
+				r.Failures = 0
+				r.LockedUntil = time.Time{}
+			} else if d = i.wait(*r, now); d > 0 {
+				return
+			}
+
+			r.Failures++
+			r.LastFailure = now
+			r.ExpiresAt = i.expires(*r)
+		})
+		if err != nil {
+			i.Release(counted...)
+			return 0, err
+		}
+
+		if d > wait {
+			wait = d
+		} else if d == 0 {
+			counted = append(counted, key)
+		}
+	}
+
+	// Take back the attempt from the keys that allowed it
+	if wait > 0 {
+		if err := i.Release(counted...); err != nil {
+			return wait, err
+		}
+	}
+
+	return wait, nil
+}
+
+// Fail keeps the attempt allowed by Check as a failure and returns the keys
+// that were locked because of it. The failures are still counted while a key
+// is locked.
+func (i *Info) Fail(keys ...string) ([]string, error) {
+	if i.backend == nil {
+		return nil, nil
+	}
+
+	var locked []string
+	for _, key := range keys {
+		lock := false
+		_, err := i.backend.Update(key, func(r *Record) {
+			now := time.Now()
+			if limit := i.limit(key); limit > 0 && r.Failures >= limit && !r.Locked(now) {
+				r.LockedUntil = now.Add(seconds(i.Lockout))
+				lock = true
+			}
+
+			r.LastFailure = now
+			r.ExpiresAt = i.expires(*r)
+		})
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return locked, err
+		}
+
+		if lock {
+			locked = append(locked, key)
+		}
+	}
+
+	return locked, nil
+}
+
+// Release takes back the attempt allowed by Check when it did not fail, like
+// a correct password before the two-factor code. The earlier failures are
+// kept.
+func (i *Info) Release(keys ...string) error {
+	if i.backend == nil {
+		return nil
+	}
+
+	for _, key := range keys {
+		_, err := i.backend.Update(key, func(r *Record) {
+			if r.Failures > 0 {
+				r.Failures--
+			}
+		})
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Succeed removes the failures of the key like after a successful login.
+func (i *Info) Succeed(key string) error {
+	if i.backend == nil {
+		return nil
+	}
+
+	return i.backend.Delete(key)
+}
+
+// Unlock removes the lockout and the failures of the key.
+func (i *Info) Unlock(key string) error {
+	if i.backend == nil {
+		return nil
+	}
+
+	return i.backend.Delete(key)
+}
+
+// Locked returns the accounts and IP addresses that are locked now.
+func (i *Info) Locked() ([]Record, error) {
+	if i.backend == nil {
+		return nil, nil
+	}
+
+	return i.backend.Locked(time.Now())
+}
+
+// Clean removes the old failures at the Cleanup interval. Call the returned
+// func to stop. Nothing is done when there is no interval.
+func (i *Info) Clean() (stop func()) {
+	if i.backend == nil || i.Cleanup <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(seconds(i.Cleanup))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				i.backend.DeleteExpired(now)
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
+
+// wait returns how long the key must wait after its failures at the time.
+func (i *Info) wait(r Record, now time.Time) time.Duration {
+	if next := r.LastFailure.Add(i.delay(r.Failures)); next.After(now) {
+		return next.Sub(now)
+	}
+
+	return 0
+}
+
+// expires returns the time the record can be removed.
+func (i *Info) expires(r Record) time.Time {
+	expires := r.LastFailure.Add(seconds(i.Window))
+	if r.LockedUntil.After(expires) {
+		return r.LockedUntil
+	}
+
+	return expires
+}
+
+// expired returns true if the failures are too old to count.
+func (i *Info) expired(r Record, now time.Time) bool {
+	return !r.Locked(now) && i.Window > 0 && now.Sub(r.LastFailure) >= seconds(i.Window)
+}
+
+// delay returns the time to wait after the number of failures.
+func (i *Info) delay(failures int) time.Duration {
+	if failures <= 0 || i.Delay <= 0 {
+		return 0
+	}
+
+	d := seconds(i.Delay)
+	max := seconds(i.MaxDelay)
+	for n := 1; n < failures && n < maxDoublings; n++ {
+		d *= 2
+		if max > 0 && d >= max {
+			break
+		}
+	}
+
+	if max > 0 && d > max {
+		return max
+	}
+
+	return d
+}
+
+// limit returns the failures allowed for the key.
+func (i *Info) limit(key string) int {
+	switch {
+	case strings.HasPrefix(key, accountPrefix):
+		return i.AccountAttempts
+	case strings.HasPrefix(key, ipPrefix):
+		return i.IPAttempts
+	case strings.HasPrefix(key, resetPrefix):
+		return i.ResetAttempts
+	case strings.HasPrefix(key, verifyPrefix):
+		return i.VerifyAttempts
+	}
+
+	return 0
+}
+
+// seconds returns the duration of the seconds.
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
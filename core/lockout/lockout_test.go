@@ -0,0 +1,342 @@
+package lockout_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/core/lockout"
+	"github.com/pcieslar/goforge/lib/gorm"
+
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/sqlite"
+)
+
+// stores returns the lockout settings for each store and the connection of
+// the database store.
+func stores(t *testing.T) (map[string]*lockout.Info, *gorm.DB, func()) {
+	folder, err := ioutil.TempDir("", "lockout")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open("sqlite3", filepath.Join(folder, "lockout.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&lockout.Record{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	list := map[string]*lockout.Info{
+		lockout.StoreMemory:   {Store: lockout.StoreMemory},
+		lockout.StoreDatabase: {Store: lockout.StoreDatabase},
+	}
+
+	for _, l := range list {
+		l.AccountAttempts = 3
+		l.IPAttempts = 5
+		l.ResetAttempts = 2
+		l.VerifyAttempts = 2
+		l.Delay = 1
+		l.MaxDelay = 3
+		l.Lockout = 600
+		l.Window = 900
+		l.SetDB(db)
+
+		if err := l.SetupConfig(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return list, db, func() {
+		db.Close()
+		os.RemoveAll(folder)
+	}
+}
+
+// attempt makes an allowed attempt that fails.
+func attempt(t *testing.T, l *lockout.Info, keys ...string) []string {
+	if wait, err := l.Check(keys...); err != nil || wait != 0 {
+		t.Fatalf("\nactual: %v, %v\nexpected: %v", wait, err, 0)
+	}
+
+	locked, err := l.Fail(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return locked
+}
+
+// TestBackoff ensures the wait doubles after each failure up to MaxDelay.
+func TestBackoff(t *testing.T) {
+	list, db, cleanup := stores(t)
+	defer cleanup()
+
+	l := list[lockout.StoreDatabase]
+	backend := lockout.NewDatabaseBackend(db)
+	key := lockout.IPKey("127.0.0.1")
+
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		attempt(t, l, key)
+
+		wait, err := l.Check(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait > expected || wait < expected-time.Second/2 {
+			t.Errorf("failure %v: \nactual: %v\nexpected: %v", i+1, wait, expected)
+		}
+
+		// Move the failure back so the next attempt is allowed
+		r, err := backend.Load(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Failures != i+1 {
+			t.Errorf("failure %v: \nactual: %v\nexpected: %v", i+1, r.Failures, i+1)
+		}
+		r.LastFailure = r.LastFailure.Add(-expected)
+		if err := backend.Save(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestConcurrent ensures attempts made at the same time wait for each other
+// so only one is allowed.
+func TestConcurrent(t *testing.T) {
+	list, _, cleanup := stores(t)
+	defer cleanup()
+
+	for name, l := range list {
+		key := lockout.AccountKey("jdoe@domain.com")
+
+		var wg sync.WaitGroup
+		var allowed int32
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wait, err := l.Check(key)
+				if err != nil {
+					t.Error(err)
+				} else if wait == 0 {
+					atomic.AddInt32(&allowed, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if allowed != 1 {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, allowed, 1)
+		}
+	}
+}
+
+// TestLockout ensures the account is locked after too many failures and can
+// be unlocked.
+func TestLockout(t *testing.T) {
+	list, _, cleanup := stores(t)
+	defer cleanup()
+
+	for name, l := range list {
+		l.Delay = 0
+		account := lockout.AccountKey(" JDoe@Domain.com")
+		ip := lockout.IPKey("127.0.0.1")
+
+		var locked []string
+		for i := 0; i < 3; i++ {
+			locked = attempt(t, l, account, ip)
+		}
+
+		// Only the account reached the limit
+		if len(locked) != 1 || locked[0] != "account:jdoe@domain.com" {
+			t.Fatalf("%v: \nactual: %v\nexpected: %v", name, locked, []string{"account:jdoe@domain.com"})
+		}
+
+		wait, err := l.Check(account, ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait < 599*time.Second {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, wait, 600*time.Second)
+		}
+
+		// The count is kept while the account is locked
+		records, err := l.Locked()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 || records[0].Key != account {
+			t.Fatalf("%v: \nactual: %v\nexpected: %v", name, records, account)
+		}
+		if records[0].Failures != 3 {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, records[0].Failures, 3)
+		}
+
+		// The address was not counted for the attempt that had to wait
+		if locked := attempt(t, l, ip); len(locked) != 0 {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, locked, nil)
+		}
+
+		if err := l.Unlock(account); err != nil {
+			t.Fatal(err)
+		}
+		if wait, _ := l.Check(account); wait != 0 {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, wait, 0)
+		}
+		if records, _ := l.Locked(); len(records) != 0 {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, len(records), 0)
+		}
+	}
+}
+
+// TestReset ensures the reset and verification emails to an address are
+// limited apart from each other and from the logins of the account.
+func TestReset(t *testing.T) {
+	list, _, cleanup := stores(t)
+	defer cleanup()
+
+	for name, l := range list {
+		l.Delay = 0
+
+		for key, expected := range map[string]string{
+			lockout.ResetKey(" JDoe@Domain.com"):  "reset:jdoe@domain.com",
+			lockout.VerifyKey(" JDoe@Domain.com"): "verify:jdoe@domain.com",
+		} {
+			attempt(t, l, key)
+			if locked := attempt(t, l, key); len(locked) != 1 || locked[0] != expected {
+				t.Fatalf("%v: \nactual: %v\nexpected: %v", name, locked, []string{expected})
+			}
+
+			if wait, _ := l.Check(key); wait == 0 {
+				t.Errorf("%v: address should be locked", name)
+			}
+		}
+
+		if wait, _ := l.Check(lockout.AccountKey("jdoe@domain.com")); wait != 0 {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, wait, 0)
+		}
+	}
+}
+
+// TestSucceed ensures a successful login removes the failures.
+func TestSucceed(t *testing.T) {
+	list, _, cleanup := stores(t)
+	defer cleanup()
+
+	for name, l := range list {
+		l.Delay = 0
+		key := lockout.AccountKey("jdoe@domain.com")
+
+		attempt(t, l, key)
+		attempt(t, l, key)
+
+		if err := l.Succeed(key); err != nil {
+			t.Fatal(err)
+		}
+
+		// The count starts over so two more failures do not lock
+		attempt(t, l, key)
+		if locked := attempt(t, l, key); len(locked) != 0 {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, locked, nil)
+		}
+	}
+}
+
+// TestRelease ensures an attempt that did not fail is not counted and the
+// earlier failures are kept.
+func TestRelease(t *testing.T) {
+	list, _, cleanup := stores(t)
+	defer cleanup()
+
+	for name, l := range list {
+		l.Delay = 0
+		key := lockout.AccountKey("jdoe@domain.com")
+
+		attempt(t, l, key)
+
+		for i := 0; i < 10; i++ {
+			if wait, err := l.Check(key); err != nil || wait != 0 {
+				t.Fatalf("%v: \nactual: %v, %v\nexpected: %v", name, wait, err, 0)
+			}
+			if err := l.Release(key); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if locked := attempt(t, l, key); len(locked) != 0 {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, locked, nil)
+		}
+		if locked := attempt(t, l, key); len(locked) != 1 {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, locked, []string{key})
+		}
+	}
+}
+
+// TestWindow ensures failures older than the window are not counted and are
+// removed.
+func TestWindow(t *testing.T) {
+	folder, err := ioutil.TempDir("", "lockout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	db, err := gorm.Open("sqlite3", filepath.Join(folder, "lockout.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.AutoMigrate(&lockout.Record{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	l := &lockout.Info{Store: lockout.StoreDatabase, AccountAttempts: 2, Delay: 60, Lockout: 60, Window: 60}
+	l.SetDB(db)
+	if err := l.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failure from before the window
+	backend := lockout.NewDatabaseBackend(db)
+	old := time.Now().Add(-2 * time.Minute)
+	backend.Save(lockout.Record{Key: "account:a", Failures: 1, LastFailure: old, ExpiresAt: old.Add(time.Minute)})
+
+	if wait, _ := l.Check("account:a"); wait != 0 {
+		t.Fatalf("\nactual: %v\nexpected: %v", wait, 0)
+	}
+
+	// The old failure does not count towards the lockout
+	if locked, _ := l.Fail("account:a"); len(locked) != 0 {
+		t.Fatalf("\nactual: %v\nexpected: %v", locked, nil)
+	}
+
+	backend.Save(lockout.Record{Key: "account:b", Failures: 1, LastFailure: old, ExpiresAt: old.Add(time.Minute)})
+	backend.DeleteExpired(time.Now())
+
+	if _, err := backend.Load("account:b"); err != lockout.ErrNotFound {
+		t.Fatalf("\nactual: %v\nexpected: %v", err, lockout.ErrNotFound)
+	}
+	if _, err := backend.Load("account:a"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStore ensures the store must be supported.
+func TestStore(t *testing.T) {
+	l := &lockout.Info{Store: "redis"}
+	if err := l.SetupConfig(); err != lockout.ErrStore {
+		t.Fatalf("\nactual: %v\nexpected: %v", err, lockout.ErrStore)
+	}
+
+	l = &lockout.Info{Store: lockout.StoreDatabase}
+	if err := l.SetupConfig(); err != lockout.ErrNoDatabase {
+		t.Fatalf("\nactual: %v\nexpected: %v", err, lockout.ErrNoDatabase)
+	}
+}
@@ -0,0 +1,120 @@
+package lockout
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps the failures in memory so they are lost when the
+// application stops. It only works with a single instance of the application.
+type MemoryBackend struct {
+	records map[string]Record
+	mutex   sync.RWMutex
+}
+
+// NewMemoryBackend returns an empty backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		records: make(map[string]Record),
+	}
+}
+
+// Load returns the failures.
+func (b *MemoryBackend) Load(key string) (Record, error) {
+	b.mutex.RLock()
+	rec, ok := b.records[key]
+	b.mutex.RUnlock()
+
+	if !ok {
+		return rec, ErrNotFound
+	}
+
+	return rec, nil
+}
+
+// Save stores the failures.
+func (b *MemoryBackend) Save(r Record) error {
+	b.mutex.Lock()
+	b.records[r.Key] = r
+	b.mutex.Unlock()
+
+	return nil
+}
+
+// Add counts a failure for the key and saves the changes fn makes to the
+// record.
+func (b *MemoryBackend) Add(key string, fn func(r *Record)) (Record, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	rec := b.records[key]
+	rec.Key = key
+	rec.Failures++
+	fn(&rec)
+	b.records[key] = rec
+
+	return rec, nil
+}
+
+// Update saves the changes fn makes to the record of the key.
+func (b *MemoryBackend) Update(key string, fn func(r *Record)) (Record, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	rec, ok := b.records[key]
+	if !ok {
+		return rec, ErrNotFound
+	}
+
+	fn(&rec)
+	b.records[key] = rec
+
+	return rec, nil
+}
+
+// Delete removes the failures.
+func (b *MemoryBackend) Delete(key string) error {
+	b.mutex.Lock()
+	delete(b.records, key)
+	b.mutex.Unlock()
+
+	return nil
+}
+
+// DeleteExpired removes the failures that expired before the time.
+func (b *MemoryBackend) DeleteExpired(now time.Time) error {
+	b.mutex.Lock()
+	for key, rec := range b.records {
+		if rec.ExpiresAt.Before(now) {
+			delete(b.records, key)
+		}
+	}
+	b.mutex.Unlock()
+
+	return nil
+}
+
+// Locked returns the records that are locked at the time.
+func (b *MemoryBackend) Locked(now time.Time) ([]Record, error) {
+	var list []Record
+
+	b.mutex.RLock()
+	for _, rec := range b.records {
+		if rec.Locked(now) {
+			list = append(list, rec)
+		}
+	}
+	b.mutex.RUnlock()
+
+	sortByLockedUntil(list)
+
+	return list, nil
+}
+
+// sortByLockedUntil sorts the records by the end of the lockout.
+func sortByLockedUntil(list []Record) {
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].LockedUntil.Before(list[b].LockedUntil)
+	})
+}
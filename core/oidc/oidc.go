@@ -0,0 +1,528 @@
+// Package oidc logs in users with external OpenID Connect providers. It uses
+// the authorization code flow with PKCE, reads the endpoints from the
+// discovery document of the provider, and validates the RS256 signed ID
+// token with the keys the provider publishes.
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// wellKnown is the path of the discovery document under the issuer.
+	wellKnown = "/.well-known/openid-configuration"
+
+	// defaultTimeout is the seconds to wait for the provider when Timeout is
+	// not set.
+	defaultTimeout = 10
+
+	// leeway is the clock difference allowed with the provider.
+	leeway = time.Minute
+
+	// randomBytes is the number of random bytes in the state, nonce, and
+	// code verifier.
+	randomBytes = 32
+
+	// maxBodyBytes is the largest response that is read from the provider.
+	maxBodyBytes = 1 << 20
+)
+
+var (
+	// ErrProvider is when the provider is not in the config.
+	ErrProvider = errors.New("Provider is not configured.")
+	// ErrDiscovery is when the discovery document cannot be read or does not
+	// match the issuer.
+	ErrDiscovery = errors.New("Discovery document is not valid.")
+	// ErrExchange is when the provider does not return an ID token for the
+	// authorization code.
+	ErrExchange = errors.New("Authorization code could not be exchanged.")
+	// ErrToken is when the ID token is not valid.
+	ErrToken = errors.New("ID token is not valid.")
+)
+
+// Provider is an OpenID Connect provider users can log in with.
+type Provider struct {
+	Key          string   `json:"Key"`          // Name of the provider in the URLs and the user_identity table
+	Name         string   `json:"Name"`         // Name of the provider shown to users
+	Issuer       string   `json:"Issuer"`       // Issuer URL the discovery document is read from
+	ClientID     string   `json:"ClientID"`     // Client ID registered with the provider
+	ClientSecret string   `json:"ClientSecret"` // Client secret, leave empty for a public client
+	Scopes       []string `json:"Scopes"`       // Scopes to request in addition to openid
+	LinkByEmail  bool     `json:"LinkByEmail"`  // Link to the user with the same email address if the provider verified it
+}
+
+// Info holds the details for the providers.
+type Info struct {
+	Timeout   int        `json:"Timeout"` // Seconds to wait for a response from a provider
+	Providers []Provider `json:"Providers"`
+	cache     *cache
+}
+
+// cache keeps the discovery documents and the keys so they are not requested
+// for every login.
+type cache struct {
+	mutex     sync.Mutex
+	documents map[string]document
+	keys      map[string]map[string]*rsa.PublicKey
+}
+
+// document is the part of the discovery document that is used.
+type document struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Request holds the values of a login that must be kept until the provider
+// redirects back, usually in the session.
+type Request struct {
+	Provider string // Key of the provider
+	State    string // Ties the callback to the browser that started the login
+	Nonce    string // Ties the ID token to the login
+	Verifier string // PKCE code verifier
+}
+
+// Claims are the claims of the ID token.
+type Claims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        audience `json:"aud"`
+	AuthorizedParty string   `json:"azp"`
+	Expiry          int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce"`
+	Email           string   `json:"email"`
+	EmailVerified   boolean  `json:"email_verified"`
+	Name            string   `json:"name"`
+	GivenName       string   `json:"given_name"`
+	FamilyName      string   `json:"family_name"`
+}
+
+// audience is the aud claim which is a string or an array of strings.
+type audience []string
+
+// UnmarshalJSON reads a string or an array of strings.
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = audience(list)
+	return nil
+}
+
+// contains returns true if the client ID is in the audience.
+func (a audience) contains(clientID string) bool {
+	for _, v := range a {
+		if v == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// boolean is a claim that some providers send as the string "true".
+type boolean bool
+
+// UnmarshalJSON reads a boolean or a string.
+func (v *boolean) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = boolean(s == "true")
+		return nil
+	}
+
+	var x bool
+	if err := json.Unmarshal(b, &x); err != nil {
+		return err
+	}
+	*v = boolean(x)
+	return nil
+}
+
+// SetupConfig creates the cache for the discovery documents and keys.
+func (i *Info) SetupConfig() {
+	i.cache = &cache{
+		documents: make(map[string]document),
+		keys:      make(map[string]map[string]*rsa.PublicKey),
+	}
+}
+
+// Provider returns the provider with the key.
+func (i Info) Provider(key string) (Provider, error) {
+	for _, p := range i.Providers {
+		if p.Key == key {
+			return p, nil
+		}
+	}
+
+	return Provider{}, ErrProvider
+}
+
+// random returns random bytes encoded for a URL.
+func random() (string, error) {
+	b := make([]byte, randomBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewRequest returns the values for a new login with the provider.
+func NewRequest(provider string) (Request, error) {
+	var err error
+	r := Request{Provider: provider}
+
+	if r.State, err = random(); err != nil {
+		return r, err
+	}
+	if r.Nonce, err = random(); err != nil {
+		return r, err
+	}
+	if r.Verifier, err = random(); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// Challenge returns the S256 PKCE code challenge for the verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthURL returns the URL of the provider to redirect the user to. The
+// provider redirects back to the redirect URI with the code and the state.
+func (i Info) AuthURL(r Request, redirectURI string) (string, error) {
+	p, err := i.Provider(r.Provider)
+	if err != nil {
+		return "", err
+	}
+
+	doc, err := i.discover(p)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(doc.AuthorizationEndpoint)
+	if err != nil {
+		return "", ErrDiscovery
+	}
+
+	scopes := []string{"openid"}
+	for _, s := range p.Scopes {
+		if s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+
+	v := u.Query()
+	v.Set("response_type", "code")
+	v.Set("client_id", p.ClientID)
+	v.Set("redirect_uri", redirectURI)
+	v.Set("scope", strings.Join(scopes, " "))
+	v.Set("state", r.State)
+	v.Set("nonce", r.Nonce)
+	v.Set("code_challenge", Challenge(r.Verifier))
+	v.Set("code_challenge_method", "S256")
+	u.RawQuery = v.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange trades the authorization code from the callback for the ID token
+// and returns its claims once it is validated.
+func (i Info) Exchange(r Request, redirectURI, code string) (Claims, error) {
+	p, err := i.Provider(r.Provider)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	doc, err := i.discover(p)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", r.Verifier)
+
+	req, err := http.NewRequest("POST", doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if len(p.ClientSecret) > 0 {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := i.client().Do(req)
+	if err != nil {
+		return Claims{}, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Claims{}, ErrExchange
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBodyBytes)).Decode(&body); err != nil || len(body.IDToken) == 0 {
+		return Claims{}, ErrExchange
+	}
+
+	return i.Verify(r.Provider, body.IDToken, r.Nonce)
+}
+
+// Verify checks the signature and the claims of the ID token from the
+// provider and returns the claims.
+func (i Info) Verify(provider, token, nonce string) (Claims, error) {
+	p, err := i.Provider(provider)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	doc, err := i.discover(p)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrToken
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, ErrToken
+	}
+	if header.Algorithm != "RS256" {
+		return Claims{}, ErrToken
+	}
+
+	key, err := i.key(doc, header.KeyID)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrToken
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature); err != nil {
+		return Claims{}, ErrToken
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, ErrToken
+	}
+
+	now := time.Now()
+	if claims.Issuer != doc.Issuer || len(claims.Subject) == 0 {
+		return Claims{}, ErrToken
+	}
+
+	// The token must be for this client and not one of the other audiences
+	if !claims.Audience.contains(p.ClientID) ||
+		(len(claims.Audience) > 1 && claims.AuthorizedParty != p.ClientID) {
+		return Claims{}, ErrToken
+	}
+
+	// Allow for a small difference between the clocks
+	if !now.Before(time.Unix(claims.Expiry, 0).Add(leeway)) ||
+		time.Unix(claims.IssuedAt, 0).After(now.Add(leeway)) {
+		return Claims{}, ErrToken
+	}
+
+	// The nonce ties the token to the login that was started
+	if len(nonce) == 0 || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return Claims{}, ErrToken
+	}
+
+	return claims, nil
+}
+
+// decodeSegment decodes a part of the token into the value.
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// client returns the HTTP client for the requests to the providers.
+func (i Info) client() *http.Client {
+	timeout := i.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &http.Client{Timeout: time.Duration(timeout) * time.Second}
+}
+
+// get decodes the JSON response of the URL into the value.
+func (i Info) get(u string, v interface{}) error {
+	resp, err := i.client().Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ErrDiscovery
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxBodyBytes)).Decode(v)
+}
+
+// discover returns the discovery document of the provider.
+func (i Info) discover(p Provider) (document, error) {
+	if i.cache != nil {
+		i.cache.mutex.Lock()
+		doc, ok := i.cache.documents[p.Issuer]
+		i.cache.mutex.Unlock()
+		if ok {
+			return doc, nil
+		}
+	}
+
+	var doc document
+	if err := i.get(strings.TrimRight(p.Issuer, "/")+wellKnown, &doc); err != nil {
+		return doc, ErrDiscovery
+	}
+
+	// The issuer must match exactly so another provider cannot be used
+	if doc.Issuer != p.Issuer || len(doc.AuthorizationEndpoint) == 0 ||
+		len(doc.TokenEndpoint) == 0 || len(doc.JWKSURI) == 0 {
+		return doc, ErrDiscovery
+	}
+
+	if i.cache != nil {
+		i.cache.mutex.Lock()
+		i.cache.documents[p.Issuer] = doc
+		i.cache.mutex.Unlock()
+	}
+
+	return doc, nil
+}
+
+// key returns the public key with the ID. The keys are requested again when
+// the ID is not known in case the provider rotated them.
+func (i Info) key(doc document, id string) (*rsa.PublicKey, error) {
+	if i.cache != nil {
+		i.cache.mutex.Lock()
+		keys := i.cache.keys[doc.JWKSURI]
+		i.cache.mutex.Unlock()
+		if k := find(keys, id); k != nil {
+			return k, nil
+		}
+	}
+
+	keys, err := i.fetchKeys(doc.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.cache != nil {
+		i.cache.mutex.Lock()
+		i.cache.keys[doc.JWKSURI] = keys
+		i.cache.mutex.Unlock()
+	}
+
+	if k := find(keys, id); k != nil {
+		return k, nil
+	}
+
+	return nil, ErrToken
+}
+
+// find returns the key with the ID or the only key when the token does not
+// name one.
+func find(keys map[string]*rsa.PublicKey, id string) *rsa.PublicKey {
+	if len(id) == 0 && len(keys) == 1 {
+		for _, k := range keys {
+			return k
+		}
+	}
+
+	return keys[id]
+}
+
+// fetchKeys returns the RSA signing keys from the JSON Web Key Set.
+func (i Info) fetchKeys(u string) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Type string `json:"kty"`
+			ID   string `json:"kid"`
+			Use  string `json:"use"`
+			N    string `json:"n"`
+			E    string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := i.get(u, &set); err != nil {
+		return nil, ErrDiscovery
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Type != "RSA" || (len(k.Use) > 0 && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+
+		keys[k.ID] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: exponent,
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,221 @@
+package oidc_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/core/oidc"
+	"github.com/pcieslar/goforge/core/oidc/oidctest"
+)
+
+// redirectURI is where the provider sends the user back to.
+const redirectURI = "http://localhost/login/external/test/callback"
+
+// setup starts a provider and returns the config for it.
+func setup(t *testing.T, secret string) (*oidctest.Server, oidc.Info) {
+	s, err := oidctest.NewServer("blueprint", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := oidc.Info{
+		Timeout: 5,
+		Providers: []oidc.Provider{{
+			Key:          "test",
+			Name:         "Test",
+			Issuer:       s.URL,
+			ClientID:     "blueprint",
+			ClientSecret: secret,
+			Scopes:       []string{"email", "profile"},
+		}},
+	}
+	o.SetupConfig()
+
+	return s, o
+}
+
+// authorize follows the login at the provider and returns the query of the
+// callback.
+func authorize(t *testing.T, o oidc.Info, r oidc.Request) url.Values {
+	u, err := o.AuthURL(r, redirectURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return callback.Query()
+}
+
+// TestExchange ensures a login with a confidential and a public client
+// returns the claims and the code can only be used once.
+func TestExchange(t *testing.T) {
+	for _, secret := range []string{"s3cr3t:&", ""} {
+		s, o := setup(t, secret)
+
+		r, err := oidc.NewRequest("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q := authorize(t, o, r)
+		if q.Get("state") != r.State {
+			t.Fatalf("\nactual: %v\nexpected: %v", q.Get("state"), r.State)
+		}
+
+		claims, err := o.Exchange(r, redirectURI, q.Get("code"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.Subject != s.Subject || claims.Email != s.Email || !bool(claims.EmailVerified) {
+			t.Errorf("\nactual: %v\nexpected: %v", claims, s.Claims(r.Nonce))
+		}
+
+		if _, err := o.Exchange(r, redirectURI, q.Get("code")); err != oidc.ErrExchange {
+			t.Errorf("\nactual: %v\nexpected: %v", err, oidc.ErrExchange)
+		}
+
+		s.Close()
+	}
+}
+
+// TestPKCE ensures the code cannot be exchanged without the verifier.
+func TestPKCE(t *testing.T) {
+	s, o := setup(t, "")
+	defer s.Close()
+
+	r, err := oidc.NewRequest("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := authorize(t, o, r)
+
+	// A stolen code is useless without the verifier of the browser
+	other := r
+	other.Verifier = "not-the-verifier"
+	if _, err := o.Exchange(other, redirectURI, q.Get("code")); err != oidc.ErrExchange {
+		t.Errorf("\nactual: %v\nexpected: %v", err, oidc.ErrExchange)
+	}
+}
+
+// TestVerify ensures ID tokens with the wrong claims or signature are
+// rejected.
+func TestVerify(t *testing.T) {
+	s, o := setup(t, "")
+	defer s.Close()
+
+	valid := s.Claims("nonce")
+	token, err := s.Sign(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Verify("test", token, "nonce"); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := map[string]interface{}{
+		"iss":   "https://evil.example.com",
+		"aud":   "other-client",
+		"exp":   time.Now().Add(-2 * time.Minute).Unix(),
+		"iat":   time.Now().Add(time.Hour).Unix(),
+		"nonce": "replayed",
+		"sub":   "",
+	}
+	for name, value := range changes {
+		claims := s.Claims("nonce")
+		claims[name] = value
+
+		token, err := s.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := o.Verify("test", token, "nonce"); err != oidc.ErrToken {
+			t.Errorf("%v\nactual: %v\nexpected: %v", name, err, oidc.ErrToken)
+		}
+	}
+
+	// Another audience is only allowed when the token names this client
+	claims := s.Claims("nonce")
+	claims["aud"] = []string{"blueprint", "other-client"}
+	token, _ = s.Sign(claims)
+	if _, err := o.Verify("test", token, "nonce"); err != oidc.ErrToken {
+		t.Errorf("\nactual: %v\nexpected: %v", err, oidc.ErrToken)
+	}
+	claims["azp"] = "blueprint"
+	token, _ = s.Sign(claims)
+	if _, err := o.Verify("test", token, "nonce"); err != nil {
+		t.Error(err)
+	}
+
+	// Payload of another token with the signature of this one
+	token, _ = s.Sign(valid)
+	claims = s.Claims("nonce")
+	claims["sub"] = "someone-else"
+	other, _ := s.Sign(claims)
+	a, b := strings.Split(token, "."), strings.Split(other, ".")
+	forged := a[0] + "." + b[1] + "." + a[2]
+	if _, err := o.Verify("test", forged, "nonce"); err != oidc.ErrToken {
+		t.Errorf("\nactual: %v\nexpected: %v", err, oidc.ErrToken)
+	}
+
+	// Unsigned
+	if _, err := o.Verify("test", "eyJhbGciOiJub25lIn0.e30.", "nonce"); err != oidc.ErrToken {
+		t.Errorf("\nactual: %v\nexpected: %v", err, oidc.ErrToken)
+	}
+}
+
+// TestKeyRotation ensures the keys are requested again when the provider
+// signs with a new key.
+func TestKeyRotation(t *testing.T) {
+	s, o := setup(t, "")
+	defer s.Close()
+
+	token, _ := s.Sign(s.Claims("nonce"))
+	if _, err := o.Verify("test", token, "nonce"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	token, _ = s.Sign(s.Claims("nonce"))
+	if _, err := o.Verify("test", token, "nonce"); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestDiscovery ensures a provider that does not match the issuer and an
+// unknown provider are rejected.
+func TestDiscovery(t *testing.T) {
+	s, o := setup(t, "")
+	defer s.Close()
+
+	o.Providers[0].Issuer = s.URL + "/"
+	r, _ := oidc.NewRequest("test")
+	if _, err := o.AuthURL(r, redirectURI); err != oidc.ErrDiscovery {
+		t.Errorf("\nactual: %v\nexpected: %v", err, oidc.ErrDiscovery)
+	}
+
+	r, _ = oidc.NewRequest("missing")
+	if _, err := o.AuthURL(r, redirectURI); err != oidc.ErrProvider {
+		t.Errorf("\nactual: %v\nexpected: %v", err, oidc.ErrProvider)
+	}
+}
@@ -0,0 +1,287 @@
+// Package oidctest provides an OpenID Connect provider for tests. It logs in
+// the same user every time without asking and checks the client, the
+// redirect URI, and the PKCE code verifier like a real provider.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyBits is the size of the signing keys.
+const keyBits = 2048
+
+// grant is an authorization code waiting to be exchanged.
+type grant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// Server is an OpenID Connect provider listening on the loopback interface.
+// Change the fields before the login starts.
+type Server struct {
+	URL           string        // Issuer of the tokens
+	ClientID      string        // Only client that is accepted
+	ClientSecret  string        // Secret of the client, empty for a public client
+	Subject       string        // Subject of the user who logs in
+	Email         string        // Email address of the user who logs in
+	EmailVerified bool          // Whether the email address is verified
+	Name          string        // Name of the user who logs in
+	Lifetime      time.Duration // How long the ID tokens are valid
+
+	server *httptest.Server
+	mutex  sync.Mutex
+	key    *rsa.PrivateKey
+	keyID  int
+	codes  map[string]grant
+}
+
+// NewServer starts a provider for the client.
+func NewServer(clientID, clientSecret string) (*Server, error) {
+	s := &Server{
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		Subject:       "248289761001",
+		Email:         "jdoe@domain.com",
+		EmailVerified: true,
+		Name:          "John Doe",
+		Lifetime:      5 * time.Minute,
+		codes:         make(map[string]grant),
+	}
+
+	if err := s.RotateKey(); err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/jwks", s.jwks)
+
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+
+	return s, nil
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// RotateKey replaces the signing key with a new one that has a new key ID.
+func (s *Server) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.key = key
+	s.keyID++
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// Sign returns a token with the claims signed with the current key.
+func (s *Server) Sign(claims map[string]interface{}) (string, error) {
+	s.mutex.Lock()
+	key, kid := s.key, strconv.Itoa(s.keyID)
+	s.mutex.Unlock()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	sum := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Claims returns the claims of an ID token for the user with the nonce.
+func (s *Server) Claims(nonce string) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":            s.URL,
+		"sub":            s.Subject,
+		"aud":            s.ClientID,
+		"exp":            now.Add(s.Lifetime).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          s.Email,
+		"email_verified": s.EmailVerified,
+		"name":           s.Name,
+	}
+}
+
+// discovery writes the discovery document.
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"jwks_uri":                              s.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize redirects back to the client with a code without asking the
+// user.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	redirectURI := q.Get("redirect_uri")
+	if q.Get("client_id") != s.ClientID || len(redirectURI) == 0 {
+		http.Error(w, "Client or redirect URI is not valid.", http.StatusBadRequest)
+		return
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "Redirect URI is not valid.", http.StatusBadRequest)
+		return
+	}
+
+	// Errors after this point are sent to the client like a real provider
+	v := u.Query()
+	v.Set("state", q.Get("state"))
+
+	scopes := strings.Fields(q.Get("scope"))
+	openid := false
+	for _, scope := range scopes {
+		openid = openid || scope == "openid"
+	}
+
+	if q.Get("response_type") != "code" || !openid ||
+		q.Get("code_challenge_method") != "S256" || len(q.Get("code_challenge")) == 0 {
+		v.Set("error", "invalid_request")
+	} else {
+		code := random()
+		s.mutex.Lock()
+		s.codes[code] = grant{
+			redirectURI: redirectURI,
+			challenge:   q.Get("code_challenge"),
+			nonce:       q.Get("nonce"),
+		}
+		s.mutex.Unlock()
+		v.Set("code", code)
+	}
+
+	u.RawQuery = v.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// token exchanges the code for an ID token once.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	// Accept the client secret in the Authorization header or the form
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID != s.ClientID || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	code := r.PostFormValue("code")
+	s.mutex.Lock()
+	g, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mutex.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || g.redirectURI != r.PostFormValue("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token, err := s.Sign(s.Claims(g.nonce))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": random(),
+		"token_type":   "Bearer",
+		"expires_in":   int(s.Lifetime / time.Second),
+		"id_token":     token,
+	})
+}
+
+// jwks writes the public key.
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	key, kid := s.key, strconv.Itoa(s.keyID)
+	s.mutex.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+}
+
+// random returns a random value for the codes and access tokens.
+func random() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// writeJSON writes the value as JSON with the status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
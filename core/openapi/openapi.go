@@ -0,0 +1,239 @@
+// Package openapi builds an OpenAPI 3 document from the routes registered
+// with the router.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// Version is the OpenAPI specification version of the document.
+const Version = "3.0.3"
+
+// bearerScheme is the name of the bearer token security scheme.
+const bearerScheme = "bearerAuth"
+
+// Info holds the details of the document.
+type Info struct {
+	Path             string   `json:"Path"`             // Path the document is served at, empty to disable
+	Title            string   `json:"Title"`            // Title of the API
+	Version          string   `json:"Version"`          // Version of the API
+	Description      string   `json:"Description"`      // Description of the API
+	Prefix           string   `json:"Prefix"`           // Only routes with the path prefix are documented
+	BearerMiddleware []string `json:"BearerMiddleware"` // Middleware that requires a bearer token like bearer.Handler
+}
+
+// Document is the root of an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       DocumentInfo        `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components *Components         `json:"components,omitempty"`
+}
+
+// DocumentInfo describes the API.
+type DocumentInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem contains the operations for a path by lowercase HTTP method.
+type PathItem map[string]*Operation
+
+// Operation describes a route.
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter describes a URL parameter.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes the request body.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response for a status code.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType contains the schema for a content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components contains the schemas referenced by the operations.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests are authenticated.
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+// Generate returns the document for the routes with the path prefix.
+func (i Info) Generate(routes []router.Route) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info: DocumentInfo{
+			Title:       i.Title,
+			Version:     i.Version,
+			Description: i.Description,
+		},
+		Paths: make(map[string]PathItem),
+	}
+
+	g := newGenerator()
+	secured := false
+
+	for _, rt := range routes {
+		if !strings.HasPrefix(rt.Path, i.Prefix) || rt.Path == i.Path {
+			continue
+		}
+
+		path, params := pathTemplate(rt.Path)
+
+		op := &Operation{
+			OperationID: rt.Name,
+			Summary:     rt.Summary,
+			Parameters:  params,
+			Responses:   make(map[string]Response),
+		}
+
+		if rt.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  jsonContent(g.schema(rt.Request)),
+			}
+		}
+
+		for code, t := range rt.Responses {
+			resp := Response{Description: http.StatusText(code)}
+			if t != nil {
+				resp.Content = jsonContent(g.schema(t))
+			}
+			op.Responses[strconv.Itoa(code)] = resp
+		}
+
+		// A document must describe at least one response
+		if len(op.Responses) == 0 {
+			op.Responses["default"] = Response{Description: "Response"}
+		}
+
+		if i.requiresBearer(rt.Middleware) {
+			op.Security = []map[string][]string{{bearerScheme: {}}}
+			secured = true
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(PathItem)
+		}
+		doc.Paths[path][strings.ToLower(rt.Method)] = op
+	}
+
+	if len(g.schemas) > 0 || secured {
+		doc.Components = &Components{}
+		if len(g.schemas) > 0 {
+			doc.Components.Schemas = g.schemas
+		}
+		if secured {
+			doc.Components.SecuritySchemes = map[string]SecurityScheme{
+				bearerScheme: {Type: "http", Scheme: "bearer"},
+			}
+		}
+	}
+
+	return doc
+}
+
+// Handler returns a handler that serves the document for the registered
+// routes as JSON.
+func (i Info) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.Marshal(i.Generate(router.Routes()))
+		if err != nil {
+			http.Error(w, "OpenAPI Encode Error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(b)
+	}
+}
+
+// requiresBearer returns true if the middleware requires a bearer token.
+func (i Info) requiresBearer(middleware []string) bool {
+	for _, m := range middleware {
+		for _, b := range i.BearerMiddleware {
+			if m == b {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// pathTemplate converts the route path to the OpenAPI format like
+// /notes/{id} and returns the parameters.
+func pathTemplate(path string) (string, []Parameter) {
+	var params []Parameter
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		name, expr, ok := router.ParamSegment(s)
+		if !ok {
+			continue
+		}
+
+		segments[i] = "{" + name + "}"
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   paramSchema(expr),
+		})
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// paramSchema returns the schema for a URL parameter constraint.
+func paramSchema(expr string) *Schema {
+	switch expr {
+	case "":
+		return &Schema{Type: "string"}
+	case "int":
+		return &Schema{Type: "integer"}
+	}
+
+	return &Schema{Type: "string", Pattern: "^(?:" + expr + ")$"}
+}
+
+// jsonContent returns the content for a JSON body with the schema.
+func jsonContent(s *Schema) map[string]MediaType {
+	return map[string]MediaType{
+		"application/json": {Schema: s},
+	}
+}
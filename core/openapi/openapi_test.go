@@ -0,0 +1,184 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/core/openapi"
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// item is a response body.
+type item struct {
+	ID        uint32     `json:"id"`
+	Name      string     `json:"name"`
+	Tags      []string   `json:"tags,omitempty"`
+	CreatedAt *time.Time `json:"created_at"`
+	secret    string
+}
+
+// itemInput is a request body.
+type itemInput struct {
+	Name string `json:"name"`
+}
+
+// authTest is middleware that requires a bearer token.
+func authTest(h http.Handler) http.Handler {
+	return h
+}
+
+// setup registers the routes for the tests.
+func setup() {
+	// Reset the router
+	router.ResetConfig()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	router.Get("/about", handler)
+	router.Get("/api/items", handler, authTest).Named("items.index").
+		Describe("List the items").
+		Returns(http.StatusOK, []item{})
+	router.Patch("/api/items/:id<int>", handler, authTest).Named("items.update").
+		Accepts(itemInput{}).
+		Returns(http.StatusOK, item{}).
+		Returns(http.StatusNoContent, nil)
+	router.Get("/api/tags/:slug<[a-z-]+>", handler)
+}
+
+// info returns the document settings for the tests.
+func info() openapi.Info {
+	return openapi.Info{
+		Path:             "/api/openapi.json",
+		Title:            "Test",
+		Version:          "1.0.0",
+		Prefix:           "/api/",
+		BearerMiddleware: []string{"openapi_test.authTest"},
+	}
+}
+
+// TestGenerate ensures the operations are built from the route metadata.
+func TestGenerate(t *testing.T) {
+	setup()
+
+	doc := info().Generate(router.Routes())
+
+	if len(doc.Paths) != 3 {
+		t.Fatalf("\nactual: %v\nexpected: %v", len(doc.Paths), 3)
+	}
+
+	if _, ok := doc.Paths["/about"]; ok {
+		t.Error("routes without the prefix should not be documented")
+	}
+
+	index := doc.Paths["/api/items"]["get"]
+	if index == nil {
+		t.Fatal("GET /api/items is missing")
+	}
+	if index.OperationID != "items.index" || index.Summary != "List the items" {
+		t.Errorf("\nactual: %v, %v\nexpected: %v, %v", index.OperationID, index.Summary, "items.index", "List the items")
+	}
+	if s := index.Responses["200"].Content["application/json"].Schema; s.Type != "array" || s.Items.Ref != "#/components/schemas/item" {
+		t.Errorf("\nactual: %+v\nexpected: array of item", s)
+	}
+	if len(index.Security) != 1 {
+		t.Errorf("\nactual: %v\nexpected: %v", len(index.Security), 1)
+	}
+
+	update := doc.Paths["/api/items/{id}"]["patch"]
+	if update == nil {
+		t.Fatal("PATCH /api/items/{id} is missing")
+	}
+	expected := []openapi.Parameter{{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer"}}}
+	if !reflect.DeepEqual(update.Parameters, expected) {
+		t.Errorf("\nactual: %+v\nexpected: %+v", update.Parameters, expected)
+	}
+	if update.RequestBody == nil || update.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/itemInput" {
+		t.Error("PATCH /api/items/{id} should accept itemInput")
+	}
+	if _, ok := update.Responses["204"]; !ok {
+		t.Error("PATCH /api/items/{id} should return 204")
+	}
+
+	tags := doc.Paths["/api/tags/{slug}"]["get"]
+	if tags == nil {
+		t.Fatal("GET /api/tags/{slug} is missing")
+	}
+	if p := tags.Parameters[0].Schema.Pattern; p != "^(?:[a-z-]+)$" {
+		t.Errorf("\nactual: %v\nexpected: %v", p, "^(?:[a-z-]+)$")
+	}
+	if _, ok := tags.Responses["default"]; !ok {
+		t.Error("routes without responses should have a default response")
+	}
+	if tags.Security != nil {
+		t.Error("routes without the bearer middleware should not be secured")
+	}
+
+	s := doc.Components.Schemas["item"]
+	if s == nil {
+		t.Fatal("item schema is missing")
+	}
+	if len(s.Properties) != 4 {
+		t.Errorf("\nactual: %v\nexpected: %v", len(s.Properties), 4)
+	}
+	if c := s.Properties["created_at"]; c.Format != "date-time" || !c.Nullable {
+		t.Errorf("\nactual: %+v\nexpected: nullable date-time", c)
+	}
+	if !reflect.DeepEqual(s.Required, []string{"id", "name"}) {
+		t.Errorf("\nactual: %v\nexpected: %v", s.Required, []string{"id", "name"})
+	}
+}
+
+// problem is a response body with a schema set from outside the type.
+type problem struct {
+	Code int
+}
+
+// TestRegisterSchema ensures a registered schema replaces the struct fields.
+func TestRegisterSchema(t *testing.T) {
+	router.ResetConfig()
+
+	expected := &openapi.Schema{
+		Type:       "object",
+		Properties: map[string]*openapi.Schema{"code": {Type: "integer"}},
+	}
+	openapi.RegisterSchema(problem{}, expected)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Get("/api/problem", handler).Returns(http.StatusBadRequest, problem{})
+
+	doc := info().Generate(router.Routes())
+
+	s := doc.Paths["/api/problem"]["get"].Responses["400"].Content["application/json"].Schema
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("\nactual: %+v\nexpected: %+v", s, expected)
+	}
+	if doc.Components != nil && doc.Components.Schemas["problem"] != nil {
+		t.Error("registered schema should not be stored as a component")
+	}
+}
+
+// TestHandler ensures the document is served as JSON.
+func TestHandler(t *testing.T) {
+	setup()
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/api/openapi.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info().Handler().ServeHTTP(w, r)
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc["openapi"] != openapi.Version {
+		t.Fatalf("\nactual: %v\nexpected: %v", doc["openapi"], openapi.Version)
+	}
+}
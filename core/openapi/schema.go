@@ -0,0 +1,201 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schema describes a JSON value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Schemer is implemented by types that encode to JSON in a way the struct
+// fields do not describe, like types with a MarshalJSON method.
+type Schemer interface {
+	OpenAPISchema() *Schema
+}
+
+var (
+	registered      = make(map[reflect.Type]*Schema)
+	registeredMutex sync.RWMutex
+)
+
+// RegisterSchema sets the schema of the type of v for packages that cannot
+// implement Schemer without importing this package.
+func RegisterSchema(v interface{}, s *Schema) {
+	registeredMutex.Lock()
+	registered[reflect.TypeOf(v)] = s
+	registeredMutex.Unlock()
+}
+
+// registeredSchema returns the schema set for the type.
+func registeredSchema(t reflect.Type) (*Schema, bool) {
+	registeredMutex.RLock()
+	s, ok := registered[t]
+	registeredMutex.RUnlock()
+	return s, ok
+}
+
+var (
+	schemerType   = reflect.TypeOf((*Schemer)(nil)).Elem()
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+// generator builds the schemas and stores the named ones as components.
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// newGenerator returns an empty generator.
+func newGenerator() *generator {
+	return &generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schema returns the schema for the type. Named structs are stored as
+// components and a reference is returned.
+func (g *generator) schema(t reflect.Type) *Schema {
+	if s, ok := registeredSchema(t); ok {
+		c := *s
+		return &c
+	}
+	if t.Kind() != reflect.Ptr && t.Implements(schemerType) {
+		return reflect.Zero(t).Interface().(Schemer).OpenAPISchema()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schema(t.Elem())
+		if len(s.Ref) == 0 {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if len(t.Name()) == 0 {
+			return g.object(t)
+		}
+		return g.ref(t)
+	}
+
+	// Interfaces and other kinds can hold any value
+	return &Schema{}
+}
+
+// ref stores the schema of the named struct as a component and returns a
+// reference to it.
+func (g *generator) ref(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := g.schemas[name]; taken {
+			name = path.Base(t.PkgPath()) + "." + t.Name()
+		}
+		g.names[t] = name
+
+		// Store a placeholder first so recursive types end
+		g.schemas[name] = &Schema{}
+		*g.schemas[name] = *g.object(t)
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object returns the schema of the struct fields as they are encoded by the
+// encoding/json package.
+func (g *generator) object(t reflect.Type) *Schema {
+	// The fields cannot be used when the type encodes itself
+	if t.Implements(marshalerType) {
+		return &Schema{}
+	}
+
+	s := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	g.fields(t, s)
+
+	return s
+}
+
+// fields adds the struct fields to the schema.
+func (g *generator) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if j := strings.Index(tag, ","); j >= 0 {
+			name, opts = tag[:j], tag[j+1:]
+		}
+
+		// Embedded structs without a name are flattened
+		if f.Anonymous && len(name) == 0 {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, s)
+				continue
+			}
+		}
+
+		if len(f.PkgPath) > 0 {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = f.Name
+		}
+
+		s.Properties[name] = g.schema(f.Type)
+
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
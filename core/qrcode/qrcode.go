@@ -0,0 +1,590 @@
+// Package qrcode encodes text as a QR code of ISO/IEC 18004 so it can be
+// shown as an image, like the links read by authenticator apps. The text is
+// stored as bytes with the medium error correction level.
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+)
+
+const (
+	// minVersion and maxVersion are the smallest and largest symbols.
+	minVersion = 1
+	maxVersion = 40
+
+	// quietZone is the light border in modules that readers need.
+	quietZone = 4
+
+	// formatMedium is the error correction level in the format bits.
+	formatMedium = 0
+)
+
+// ErrTooLong is when the text does not fit in the largest QR code.
+var ErrTooLong = errors.New("Text is too long for a QR code.")
+
+// The error correction codewords in each block and the number of blocks for
+// each version with the medium level. The first entry is not used.
+var (
+	eccPerBlock = [maxVersion + 1]int{0,
+		10, 16, 26, 18, 24, 16, 18, 22, 22, 26,
+		30, 22, 22, 24, 24, 28, 28, 26, 26, 26,
+		26, 28, 28, 28, 28, 28, 28, 28, 28, 28,
+		28, 28, 28, 28, 28, 28, 28, 28, 28, 28,
+	}
+	eccBlocks = [maxVersion + 1]int{0,
+		1, 1, 1, 2, 2, 4, 4, 4, 5, 5,
+		5, 8, 9, 9, 10, 10, 11, 13, 14, 16,
+		17, 17, 18, 20, 21, 23, 25, 26, 28, 29,
+		31, 33, 35, 37, 38, 40, 43, 45, 47, 49,
+	}
+)
+
+// Code is a QR code as a square of dark and light modules.
+type Code struct {
+	Size     int // Modules on each side without the quiet zone
+	modules  [][]bool
+	function [][]bool // Modules that are not data, like the finder patterns
+}
+
+// Encode returns the smallest QR code that holds the text.
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+
+	version := minVersion
+	for ; version <= maxVersion; version++ {
+		if 4+countBits(version)+len(data)*8 <= dataCodewords(version)*8 {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrTooLong
+	}
+
+	c := newCode(version)
+	c.drawCodewords(codewords(version, data))
+
+	// Use the mask that is easiest to read
+	best, lowest := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if p := c.penalty(); lowest < 0 || p < lowest {
+			best, lowest = mask, p
+		}
+		c.applyMask(mask)
+	}
+
+	c.applyMask(best)
+	c.drawFormat(best)
+
+	return c, nil
+}
+
+// Dark returns true if the module at the column and row is dark. The
+// modules outside the code are light.
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y][x]
+}
+
+// SVG returns the code as an SVG image with a quiet zone. Each module is one
+// unit so the image can be scaled to any size.
+func (c *Code) SVG() []byte {
+	n := c.Size + quietZone*2
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, n, n)
+	b.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+
+	return b.Bytes()
+}
+
+// DataURI returns the SVG image as a data URI for the src of an img tag.
+func (c *Code) DataURI() template.URL {
+	return template.URL("data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(c.SVG()))
+}
+
+// countBits returns the length of the character count for byte mode.
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// rawModules returns the modules that hold codewords, which is everything
+// but the function patterns and the format and version information.
+func rawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+// dataCodewords returns the codewords left for data after error correction.
+func dataCodewords(version int) int {
+	return rawModules(version)/8 - eccPerBlock[version]*eccBlocks[version]
+}
+
+// codewords returns the data with its mode, count, and padding, split into
+// blocks with their error correction and interleaved.
+func codewords(version int, data []byte) []byte {
+	var bits bitBuffer
+	bits.append(0x4, 4) // Byte mode
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	// Add the terminator and fill the last byte and the capacity
+	capacity := dataCodewords(version) * 8
+	if n := capacity - len(bits); n < 4 {
+		bits.append(0, n)
+	} else {
+		bits.append(0, 4)
+	}
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	full := bits.bytes()
+
+	// Split into blocks where the last ones have one more data codeword
+	blocks := eccBlocks[version]
+	ecc := eccPerBlock[version]
+	raw := rawModules(version) / 8
+	short := blocks - raw%blocks
+	shortLen := raw/blocks - ecc
+	divisor := generator(ecc)
+
+	dataBlocks := make([][]byte, blocks)
+	eccParts := make([][]byte, blocks)
+	for i, k := 0, 0; i < blocks; i++ {
+		n := shortLen
+		if i >= short {
+			n++
+		}
+		dataBlocks[i] = full[k : k+n]
+		eccParts[i] = remainder(dataBlocks[i], divisor)
+		k += n
+	}
+
+	// Interleave the data and then the error correction of the blocks
+	result := make([]byte, 0, raw)
+	for i := 0; i <= shortLen; i++ {
+		for _, b := range dataBlocks {
+			if i < len(b) {
+				result = append(result, b[i])
+			}
+		}
+	}
+	for i := 0; i < ecc; i++ {
+		for _, b := range eccParts {
+			result = append(result, b[i])
+		}
+	}
+
+	return result
+}
+
+// newCode returns an empty code of the version with the function patterns.
+func newCode(version int) *Code {
+	size := version*4 + 17
+	c := &Code{
+		Size:     size,
+		modules:  make([][]bool, size),
+		function: make([][]bool, size),
+	}
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	// Timing patterns
+	for i := 0; i < size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with their separators
+	c.drawFinder(3, 3)
+	c.drawFinder(size-4, 3)
+	c.drawFinder(3, size-4)
+
+	// Alignment patterns except where the finder patterns are
+	positions := alignment(version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	// Reserve the format information until the mask is chosen
+	c.drawFormat(0)
+	c.drawVersion(version)
+
+	return c
+}
+
+// set changes a function module.
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFinder draws the finder pattern and its separator around the center.
+func (c *Code) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+				continue
+			}
+			d := ring(dx, dy)
+			c.set(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+// drawAlignment draws the alignment pattern around the center.
+func (c *Code) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.set(cx+dx, cy+dy, ring(dx, dy) != 1)
+		}
+	}
+}
+
+// drawFormat draws both copies of the error correction level and the mask.
+func (c *Code) drawFormat(mask int) {
+	data := formatMedium<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	// Around the top left finder pattern
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(bits, i))
+	}
+	c.set(8, 7, bit(bits, 6))
+	c.set(8, 8, bit(bits, 7))
+	c.set(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(bits, i))
+	}
+
+	// Next to the other finder patterns
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(bits, i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// drawVersion draws both copies of the version from version 7.
+func (c *Code) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		a, b := c.Size-11+i%3, i/3
+		c.set(a, b, bit(bits, i))
+		c.set(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords places the bits in pairs of columns from the bottom right,
+// going up and down in turn and skipping the function modules.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		// Skip the vertical timing pattern
+		if right == 6 {
+			right = 5
+		}
+
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				c.modules[y][x] = data[i/8]>>(7-uint(i%8))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules where the mask pattern is true. Applying
+// the same mask again removes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.function[y][x] {
+				continue
+			}
+
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to read with the rules of the
+// standard. A lower score is better.
+func (c *Code) penalty() int {
+	score := 0
+
+	// Runs of five or more modules of the same color and patterns that look
+	// like a finder pattern, in the rows and then the columns
+	for _, row := range [2]bool{true, false} {
+		for i := 0; i < c.Size; i++ {
+			line := make([]bool, c.Size)
+			for j := range line {
+				if row {
+					line[j] = c.modules[i][j]
+				} else {
+					line[j] = c.modules[j][i]
+				}
+			}
+			score += runPenalty(line) + finderPenalty(line)
+		}
+	}
+
+	// Blocks of 2x2 modules of the same color
+	for y := 0; y < c.Size-1; y++ {
+		for x := 0; x < c.Size-1; x++ {
+			m := c.modules[y][x]
+			if m == c.modules[y][x+1] && m == c.modules[y+1][x] && m == c.modules[y+1][x+1] {
+				score += 3
+			}
+		}
+	}
+
+	// Too many dark or light modules
+	dark := 0
+	for _, row := range c.modules {
+		for _, m := range row {
+			if m {
+				dark++
+			}
+		}
+	}
+	total := c.Size * c.Size
+	score += abs(dark*100/total-50) / 5 * 10
+
+	return score
+}
+
+// runPenalty scores the runs of five or more modules of the same color.
+func runPenalty(line []bool) int {
+	score := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			score += 3 + run - 5
+		}
+		run = 1
+	}
+	return score
+}
+
+// finder is the dark and light pattern of a finder pattern across its center.
+var finder = []bool{true, false, true, true, true, false, true}
+
+// finderPenalty scores the finder patterns with four light modules on either
+// side. The modules outside the code count as light.
+func finderPenalty(line []bool) int {
+	at := func(i int) bool {
+		return i >= 0 && i < len(line) && line[i]
+	}
+	light := func(from int) bool {
+		for i := from; i < from+4; i++ {
+			if at(i) {
+				return false
+			}
+		}
+		return true
+	}
+
+	score := 0
+	for i := 0; i+len(finder) <= len(line); i++ {
+		match := true
+		for j, m := range finder {
+			if line[i+j] != m {
+				match = false
+				break
+			}
+		}
+		if match && (light(i-4) || light(i+len(finder))) {
+			score += 40
+		}
+	}
+	return score
+}
+
+// alignment returns the rows and columns of the alignment pattern centers.
+func alignment(version int) []int {
+	if version == 1 {
+		return nil
+	}
+
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	result := make([]int, n)
+	result[0] = 6
+	for i, pos := n-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// generator returns the Reed-Solomon divisor for the number of error
+// correction codewords, without its leading coefficient.
+func generator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	// Multiply by (x - r^i) for each i where r is 2
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = multiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = multiply(root, 2)
+	}
+	return result
+}
+
+// remainder returns the error correction codewords of the data.
+func remainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= multiply(d, factor)
+		}
+	}
+	return result
+}
+
+// multiply returns the product in GF(2^8) with the polynomial of the
+// standard.
+func multiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// bitBuffer holds bits in order, one in each entry.
+type bitBuffer []bool
+
+// append adds the lowest n bits of the value, highest first.
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, value>>uint(i)&1 == 1)
+	}
+}
+
+// bytes packs the bits into bytes.
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			result[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	return result
+}
+
+// bit returns true if the bit at the index of the value is set.
+func bit(value, i int) bool {
+	return value>>uint(i)&1 == 1
+}
+
+// ring returns how many modules the offset is from the center of a square
+// pattern.
+func ring(dx, dy int) int {
+	if abs(dx) > abs(dy) {
+		return abs(dx)
+	}
+	return abs(dy)
+}
+
+// abs returns the absolute value.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
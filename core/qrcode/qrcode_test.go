@@ -0,0 +1,108 @@
+package qrcode_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/pcieslar/goforge/core/qrcode"
+)
+
+// TestEncode ensures the modules match a code that readers decode as the
+// text.
+func TestEncode(t *testing.T) {
+	expected := []string{
+		"#######.#...#.#######",
+		"#.....#.#...#.#.....#",
+		"#.###.#.......#.###.#",
+		"#.###.#.#.#.#.#.###.#",
+		"#.###.#..###..#.###.#",
+		"#.....#...###.#.....#",
+		"#######.#.#.#.#######",
+		"........#####........",
+		"#.##.###.#.##.#..#.##",
+		".##....#.#######.##..",
+		".....#####.#.#.#...##",
+		"#.#.##.##..#...#.#.#.",
+		"#...#.##.##.##....#.#",
+		"........#.##..##..#.#",
+		"#######.#.#######....",
+		"#.....#.###..#.#.####",
+		"#.###.#..#..#.#..#...",
+		"#.###.#.###...#..###.",
+		"#.###.#.##..#..#..#..",
+		"#.....#..###.####...#",
+		"#######.##.#.#.#.....",
+	}
+
+	c, err := qrcode.Encode("HELLO WORLD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Size != len(expected) {
+		t.Fatalf("\nactual: %v\nexpected: %v", c.Size, len(expected))
+	}
+
+	for y, row := range expected {
+		var b strings.Builder
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		if b.String() != row {
+			t.Errorf("row %v: \nactual: %v\nexpected: %v", y, b.String(), row)
+		}
+	}
+}
+
+// TestVersion ensures the smallest code that holds the text is used.
+func TestVersion(t *testing.T) {
+	for length, expected := range map[int]int{
+		14:   21,  // Version 1
+		15:   25,  // Version 2
+		122:  45,  // Version 7 with the version information
+		213:  57,  // Version 10 with the longer count
+		2331: 177, // Version 40
+	} {
+		c, err := qrcode.Encode(strings.Repeat("a", length))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Size != expected {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", length, c.Size, expected)
+		}
+	}
+
+	if _, err := qrcode.Encode(strings.Repeat("a", 2332)); err != qrcode.ErrTooLong {
+		t.Errorf("\nactual: %v\nexpected: %v", err, qrcode.ErrTooLong)
+	}
+}
+
+// TestDataURI ensures the image can be used as the src of an img tag.
+func TestDataURI(t *testing.T) {
+	c, err := qrcode.Encode("otpauth://totp/Blueprint:jdoe@domain.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := "data:image/svg+xml;base64,"
+	uri := string(c.DataURI())
+	if !strings.HasPrefix(uri, prefix) {
+		t.Fatalf("\nactual: %v\nexpected: %v...", uri, prefix)
+	}
+
+	svg, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(svg) != string(c.SVG()) {
+		t.Errorf("\nactual: %s\nexpected: %s", svg, c.SVG())
+	}
+	if !strings.HasPrefix(string(svg), "<svg ") || !strings.HasSuffix(string(svg), "</svg>") {
+		t.Errorf("\nactual: %s\nexpected: an SVG image", svg)
+	}
+}
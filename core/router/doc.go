@@ -0,0 +1,39 @@
+package router
+
+import (
+	"reflect"
+)
+
+// Describe sets the summary of the route for the API document.
+func (rt *Route) Describe(summary string) *Route {
+	listMutex.Lock()
+	rt.Summary = summary
+	listMutex.Unlock()
+
+	return rt
+}
+
+// Accepts sets the type of the JSON request body for the API document. Pass
+// a zero value of the type:
+//
+//	router.Post("/api/v1/notes", api.NoteStore).Accepts(api.NoteInput{})
+func (rt *Route) Accepts(body interface{}) *Route {
+	listMutex.Lock()
+	rt.Request = reflect.TypeOf(body)
+	listMutex.Unlock()
+
+	return rt
+}
+
+// Returns adds a status code and the type of the JSON response body for the
+// API document. Pass nil when the response has no body.
+func (rt *Route) Returns(code int, body interface{}) *Route {
+	listMutex.Lock()
+	if rt.Responses == nil {
+		rt.Responses = make(map[int]reflect.Type)
+	}
+	rt.Responses[code] = reflect.TypeOf(body)
+	listMutex.Unlock()
+
+	return rt
+}
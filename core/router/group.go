@@ -0,0 +1,97 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/justinas/alice"
+)
+
+// RouteGroup registers routes that share a path prefix and middleware.
+type RouteGroup struct {
+	prefix string
+	chain  []alice.Constructor
+}
+
+// Group returns a group for routes that share the path prefix and
+// middleware. The group middleware runs before the route middleware.
+func Group(prefix string, c ...alice.Constructor) *RouteGroup {
+	return &RouteGroup{
+		prefix: joinPath("", prefix),
+		chain:  c,
+	}
+}
+
+// Group returns a nested group. The prefix and middleware are added to the
+// ones from the parent group.
+func (g *RouteGroup) Group(prefix string, c ...alice.Constructor) *RouteGroup {
+	return &RouteGroup{
+		prefix: joinPath(g.prefix, prefix),
+		chain:  g.middleware(c),
+	}
+}
+
+// middleware returns the group middleware followed by the route middleware.
+func (g *RouteGroup) middleware(c []alice.Constructor) []alice.Constructor {
+	list := make([]alice.Constructor, 0, len(g.chain)+len(c))
+	list = append(list, g.chain...)
+	return append(list, c...)
+}
+
+// handle registers the route with the group prefix and middleware.
+func (g *RouteGroup) handle(method, path string, fn http.HandlerFunc, c []alice.Constructor) *Route {
+	return handle(method, joinPath(g.prefix, path), fn, g.middleware(c))
+}
+
+// Delete is a shortcut for router.Handle("DELETE", path, handle).
+func (g *RouteGroup) Delete(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return g.handle("DELETE", path, fn, c)
+}
+
+// Get is a shortcut for router.Handle("GET", path, handle).
+func (g *RouteGroup) Get(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return g.handle("GET", path, fn, c)
+}
+
+// Head is a shortcut for router.Handle("HEAD", path, handle).
+func (g *RouteGroup) Head(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return g.handle("HEAD", path, fn, c)
+}
+
+// Options is a shortcut for router.Handle("OPTIONS", path, handle).
+func (g *RouteGroup) Options(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return g.handle("OPTIONS", path, fn, c)
+}
+
+// Patch is a shortcut for router.Handle("PATCH", path, handle).
+func (g *RouteGroup) Patch(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return g.handle("PATCH", path, fn, c)
+}
+
+// Post is a shortcut for router.Handle("POST", path, handle).
+func (g *RouteGroup) Post(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return g.handle("POST", path, fn, c)
+}
+
+// Put is a shortcut for router.Handle("PUT", path, handle).
+func (g *RouteGroup) Put(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return g.handle("PUT", path, fn, c)
+}
+
+// joinPath adds the path to the prefix. An empty path or a single slash
+// refers to the prefix itself.
+func joinPath(prefix, path string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	switch {
+	case path == "" || path == "/":
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	case !strings.HasPrefix(path, "/"):
+		path = "/" + path
+	}
+
+	return prefix + path
+}
@@ -0,0 +1,89 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// TestGroup ensures the group prefix and middleware are applied.
+func TestGroup(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+
+	called := false
+
+	// Mock the HTTP handler
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		r.ParseForm()
+	})
+
+	g := router.Group("/api", middlewareTest1)
+	v1 := g.Group("/v1/", middlewareTest2)
+	v1.Get("/notes", handler)
+
+	// Simulate a request
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/api/v1/notes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mock the request
+	router.Instance().ServeHTTP(w, r)
+
+	if !called {
+		t.Fatal("handler should be called for the expanded path")
+	}
+
+	actual := r.Form.Get("foo1")
+	expected := "mt1"
+
+	if actual != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", actual, expected)
+	}
+
+	actual = r.Form.Get("foo2")
+	expected = "mt2"
+
+	if actual != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", actual, expected)
+	}
+}
+
+// TestGroupRouteList ensures the expanded paths and middleware are listed.
+func TestGroupRouteList(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+
+	// Mock the HTTP handler
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	g := router.Group("/notepad", middlewareTest1)
+	g.Get("", handler)
+	g.Post("/create", handler, middlewareTest2)
+	g.Group("/admin").Head("/", handler)
+	router.Options("/", handler)
+
+	testList := []string{
+		"GET	/notepad	router_test.middlewareTest1",
+		"POST	/notepad/create	router_test.middlewareTest1, router_test.middlewareTest2",
+		"HEAD	/notepad/admin	router_test.middlewareTest1",
+		"OPTIONS	/",
+	}
+
+	list := router.RouteList()
+
+	if len(list) != len(testList) {
+		t.Fatalf("\nactual: %v\nexpected: %v", len(list), len(testList))
+	}
+
+	for i := 0; i < len(list); i++ {
+		if list[i] != testList[i] {
+			t.Fatalf("\nactual: %v\nexpected: %v", list[i], testList[i])
+		}
+	}
+}
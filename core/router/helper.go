@@ -3,13 +3,16 @@ package router
 import (
 	"fmt"
 	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
 	"sync"
 
 	"github.com/justinas/alice"
 )
 
 var (
-	routeList []string
+	routeList []*Route
 	listMutex sync.RWMutex
 )
 
@@ -23,57 +26,147 @@ func ChainHandler(h http.Handler, c ...alice.Constructor) http.Handler {
 	return alice.New(c...).Then(h)
 }
 
-// Record stores the method and path.
-func record(method, path string) {
+// Record stores the route.
+func record(rt *Route) {
 	listMutex.Lock()
-	routeList = append(routeList, fmt.Sprintf("%v\t%v", method, path))
+	routeList = append(routeList, rt)
 	listMutex.Unlock()
 }
 
-// RouteList returns a list of the HTTP methods and paths.
+// RouteList returns a list of the HTTP methods and paths followed by the
+// middleware that applies to each route.
 func RouteList() []string {
 	listMutex.RLock()
-	list := routeList
-	listMutex.RUnlock()
+	defer listMutex.RUnlock()
+
+	list := make([]string, len(routeList))
+	for i, rt := range routeList {
+		list[i] = fmt.Sprintf("%v\t%v", rt.Method, rt.Path)
+		if len(rt.Middleware) > 0 {
+			list[i] += "\t" + strings.Join(rt.Middleware, ", ")
+		}
+	}
+
 	return list
 }
 
-// Delete is a shortcut for router.Handle("DELETE", path, handle).
-func Delete(path string, fn http.HandlerFunc, c ...alice.Constructor) {
+// Routes returns a copy of the registered routes in the order they were
+// added.
+func Routes() []Route {
+	listMutex.RLock()
+	defer listMutex.RUnlock()
+
+	list := make([]Route, len(routeList))
+	for i, rt := range routeList {
+		list[i] = *rt
+		list[i].Middleware = append([]string(nil), rt.Middleware...)
+
+		if rt.Responses != nil {
+			list[i].Responses = make(map[int]reflect.Type, len(rt.Responses))
+			for code, t := range rt.Responses {
+				list[i].Responses[code] = t
+			}
+		}
+	}
+
+	return list
+}
+
+// handle registers the handler wrapped in the middleware with the router.
+func handle(method, path string, fn http.HandlerFunc, c []alice.Constructor) *Route {
+	pattern, checks := parsePattern(path)
+
+	rt := &Route{
+		Method:      method,
+		Path:        path,
+		Handler:     funcName(fn),
+		Middleware:  funcNames(c),
+		constraints: checks,
+	}
+
+	h := alice.New(c...).ThenFunc(fn).(http.HandlerFunc)
+
+	// Check the URL parameters before any middleware runs
+	if len(checks) > 0 {
+		h = constrain(h, checks)
+	}
+
 	infoMutex.Lock()
-	record("DELETE", path)
-	r.Delete(path, alice.New(c...).ThenFunc(fn).(http.HandlerFunc))
+	record(rt)
+	switch method {
+	case "DELETE":
+		r.Delete(pattern, h)
+	case "GET":
+		r.Get(pattern, h)
+	case "HEAD":
+		r.Head(pattern, h)
+	case "OPTIONS":
+		r.Options(pattern, h)
+	case "PATCH":
+		r.Patch(pattern, h)
+	case "POST":
+		r.Post(pattern, h)
+	case "PUT":
+		r.Put(pattern, h)
+	}
 	infoMutex.Unlock()
+
+	return rt
+}
+
+// funcNames returns the package qualified names of the middleware.
+func funcNames(c []alice.Constructor) []string {
+	var list []string
+	for _, fn := range c {
+		list = append(list, funcName(fn))
+	}
+	return list
+}
+
+// funcName returns the package qualified name of a func like acl.DisallowAnon.
+func funcName(fn interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+
+	// Remove the import path
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	// Remove the suffix from method values
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// Delete is a shortcut for router.Handle("DELETE", path, handle).
+func Delete(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return handle("DELETE", path, fn, c)
 }
 
 // Get is a shortcut for router.Handle("GET", path, handle).
-func Get(path string, fn http.HandlerFunc, c ...alice.Constructor) {
-	infoMutex.Lock()
-	record("GET", path)
-	r.Get(path, alice.New(c...).ThenFunc(fn).(http.HandlerFunc))
-	infoMutex.Unlock()
+func Get(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return handle("GET", path, fn, c)
+}
+
+// Head is a shortcut for router.Handle("HEAD", path, handle).
+func Head(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return handle("HEAD", path, fn, c)
+}
+
+// Options is a shortcut for router.Handle("OPTIONS", path, handle).
+func Options(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return handle("OPTIONS", path, fn, c)
 }
 
 // Patch is a shortcut for router.Handle("PATCH", path, handle).
-func Patch(path string, fn http.HandlerFunc, c ...alice.Constructor) {
-	infoMutex.Lock()
-	record("PATCH", path)
-	r.Patch(path, alice.New(c...).ThenFunc(fn).(http.HandlerFunc))
-	infoMutex.Unlock()
+func Patch(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return handle("PATCH", path, fn, c)
 }
 
 // Post is a shortcut for router.Handle("POST", path, handle).
-func Post(path string, fn http.HandlerFunc, c ...alice.Constructor) {
-	infoMutex.Lock()
-	record("POST", path)
-	r.Post(path, alice.New(c...).ThenFunc(fn).(http.HandlerFunc))
-	infoMutex.Unlock()
+func Post(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return handle("POST", path, fn, c)
 }
 
 // Put is a shortcut for router.Handle("PUT", path, handle).
-func Put(path string, fn http.HandlerFunc, c ...alice.Constructor) {
-	infoMutex.Lock()
-	record("PUT", path)
-	r.Put(path, alice.New(c...).ThenFunc(fn).(http.HandlerFunc))
-	infoMutex.Unlock()
+func Put(path string, fn http.HandlerFunc, c ...alice.Constructor) *Route {
+	return handle("PUT", path, fn, c)
 }
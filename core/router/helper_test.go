@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"testing"
 
 	"github.com/pcieslar/goforge/core/router"
@@ -176,3 +177,47 @@ func TestRouteListFail(t *testing.T) {
 		t.Fatalf("\nactual: %v\nexpected: %v", len(list), len(testList))
 	}
 }
+
+// handlerTest is a named handler for the route details.
+func handlerTest(w http.ResponseWriter, r *http.Request) {}
+
+// TestRoutes ensures the route details are returned.
+func TestRoutes(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+
+	router.Get("/get", handlerTest).Named("get")
+	router.Post("/post", handlerTest, middlewareTest1, middlewareTest2)
+
+	list := router.Routes()
+
+	if len(list) != 2 {
+		t.Fatalf("\nactual: %v\nexpected: %v", len(list), 2)
+	}
+
+	expected := router.Route{
+		Method:  "GET",
+		Path:    "/get",
+		Name:    "get",
+		Handler: "router_test.handlerTest",
+	}
+	if !reflect.DeepEqual(list[0], expected) {
+		t.Fatalf("\nactual: %v\nexpected: %v", list[0], expected)
+	}
+
+	expected = router.Route{
+		Method:     "POST",
+		Path:       "/post",
+		Handler:    "router_test.handlerTest",
+		Middleware: []string{"router_test.middlewareTest1", "router_test.middlewareTest2"},
+	}
+	if !reflect.DeepEqual(list[1], expected) {
+		t.Fatalf("\nactual: %v\nexpected: %v", list[1], expected)
+	}
+
+	// Changes to the copy should not affect the router
+	list[1].Middleware[0] = "changed"
+	if actual := router.Routes()[1].Middleware[0]; actual != "router_test.middlewareTest1" {
+		t.Fatalf("\nactual: %v\nexpected: %v", actual, "router_test.middlewareTest1")
+	}
+}
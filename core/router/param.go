@@ -0,0 +1,97 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// constraint returns true if the URL parameter value is allowed.
+type constraint func(value string) bool
+
+// constraintTypes are the named types that can be used instead of a regular
+// expression.
+var constraintTypes = map[string]constraint{
+	"int": func(value string) bool {
+		if len(value) == 0 || strings.TrimLeft(value, "0123456789") != "" {
+			return false
+		}
+		_, err := strconv.Atoi(value)
+		return err == nil
+	},
+}
+
+// ParamSegment splits a path segment like :id<int> into the parameter name
+// and constraint. Returns false if the segment is not a parameter.
+func ParamSegment(s string) (name string, expr string, ok bool) {
+	if len(s) < 2 || (s[0] != ':' && s[0] != '*') {
+		return "", "", false
+	}
+
+	name = s[1:]
+	if i := strings.Index(name, "<"); i > 0 && strings.HasSuffix(name, ">") {
+		name, expr = name[:i], name[i+1:len(name)-1]
+	}
+
+	return name, expr, true
+}
+
+// parsePattern removes the constraints from the path so it can be passed to
+// the router and returns the constraint for each parameter. It panics if a
+// constraint is not a named type or a valid regular expression.
+func parsePattern(path string) (string, map[string]constraint) {
+	var checks map[string]constraint
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		name, expr, ok := ParamSegment(s)
+		if !ok || len(expr) == 0 {
+			continue
+		}
+
+		check, ok := constraintTypes[expr]
+		if !ok {
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if err != nil {
+				panic("router: URL parameter constraint is not valid in " + path + ": " + err.Error())
+			}
+			check = re.MatchString
+		}
+
+		if checks == nil {
+			checks = make(map[string]constraint)
+		}
+		checks[name] = check
+		segments[i] = s[:1] + name
+	}
+
+	return strings.Join(segments, "/"), checks
+}
+
+// constrain calls the not found handler instead of the handler if a URL
+// parameter does not match its constraint.
+func constrain(h http.Handler, checks map[string]constraint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for name, check := range checks {
+			if !check(Param(r, name)) {
+				infoMutex.RLock()
+				fn := notFound
+				infoMutex.RUnlock()
+
+				fn(w, r)
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	}
+}
+
+// ParamInt returns the URL parameter as an int. It returns 0 if the value is
+// not an int so it should only be used with parameters that have the int
+// constraint like :id<int>.
+func ParamInt(r *http.Request, name string) int {
+	i, _ := strconv.Atoi(Param(r, name))
+	return i
+}
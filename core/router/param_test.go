@@ -0,0 +1,111 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// TestParamConstraint ensures requests only reach the handler when the URL
+// parameters match the constraints.
+func TestParamConstraint(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+	router.NotFound(http.NotFound)
+
+	var id int
+	var slug string
+	var calls int
+
+	// Count the requests that reach the middleware
+	counter := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	router.Get("/notepad/view/:id<int>", func(w http.ResponseWriter, r *http.Request) {
+		id = router.ParamInt(r, "id")
+	})
+	router.Get("/post/:slug<[a-z-]+>", func(w http.ResponseWriter, r *http.Request) {
+		slug = router.Param(r, "slug")
+	}, counter)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/notepad/view/42", http.StatusOK},
+		{"/notepad/view/abc", http.StatusNotFound},
+		{"/notepad/view/-1", http.StatusNotFound},
+		{"/notepad/view/99999999999999999999999", http.StatusNotFound},
+		{"/post/hello-world", http.StatusOK},
+		{"/post/Hello", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.Instance().ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("%v\nactual: %v\nexpected: %v", test.path, w.Code, test.status)
+		}
+	}
+
+	if id != 42 {
+		t.Errorf("\nactual: %v\nexpected: %v", id, 42)
+	}
+
+	if slug != "hello-world" {
+		t.Errorf("\nactual: %v\nexpected: %v", slug, "hello-world")
+	}
+
+	// The middleware should not run when the constraint does not match
+	if calls != 1 {
+		t.Errorf("\nactual: %v\nexpected: %v", calls, 1)
+	}
+}
+
+// TestParamConstraintURL ensures URL rejects values that do not match.
+func TestParamConstraintURL(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Get("/notepad/view/:id<int>", handler).Named("notepad.show")
+
+	actual, err := router.URL("notepad.show", "id", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/notepad/view/5"; actual != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", actual, expected)
+	}
+
+	if _, err := router.URL("notepad.show", "id", "abc"); err == nil {
+		t.Fatal("expected an error for a value that does not match")
+	}
+}
+
+// TestParamConstraintInvalid ensures an invalid constraint panics when the
+// route is added.
+func TestParamConstraintInvalid(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for an invalid constraint")
+		}
+	}()
+
+	router.Get("/post/:slug<[a-z>", func(w http.ResponseWriter, r *http.Request) {})
+}
@@ -15,6 +15,7 @@ import (
 
 var (
 	r         *vestigo.Router
+	notFound  http.HandlerFunc = http.NotFound
 	infoMutex sync.RWMutex
 )
 
@@ -26,9 +27,13 @@ func init() {
 // ResetConfig creates a new instance.
 func ResetConfig() {
 	infoMutex.Lock()
-	routeList = []string{}
+	routeList = []*Route{}
 	r = vestigo.NewRouter()
 	infoMutex.Unlock()
+
+	nameMutex.Lock()
+	names = make(map[string]*Route)
+	nameMutex.Unlock()
 }
 
 // Instance returns the router.
@@ -41,6 +46,7 @@ func Instance() *vestigo.Router {
 // NotFound sets the 404 handler.
 func NotFound(fn http.HandlerFunc) {
 	infoMutex.Lock()
+	notFound = fn
 	vestigo.CustomNotFoundHandlerFunc(fn)
 	infoMutex.Unlock()
 }
@@ -0,0 +1,107 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	names     map[string]*Route
+	nameMutex sync.RWMutex
+)
+
+// Route is a registered route.
+type Route struct {
+	Method     string   // HTTP method
+	Path       string   // Path pattern
+	Name       string   // Name used to build the URL
+	Handler    string   // Package qualified name of the handler
+	Middleware []string // Package qualified names of the route middleware
+
+	Summary   string               // Short description for the API document
+	Request   reflect.Type         `json:"-"` // Type of the JSON request body
+	Responses map[int]reflect.Type `json:"-"` // Type of the JSON response body by status code
+
+	constraints map[string]constraint
+}
+
+// Named sets the name of the route so the path can be built with URL. It
+// panics if the name is already in use.
+func (rt *Route) Named(name string) *Route {
+	nameMutex.Lock()
+	defer nameMutex.Unlock()
+
+	if _, ok := names[name]; ok {
+		panic("router: route name is already registered: " + name)
+	}
+
+	listMutex.Lock()
+	rt.Name = name
+	listMutex.Unlock()
+
+	names[name] = rt
+
+	return rt
+}
+
+// URL returns the path of the named route with the URL parameters replaced.
+// The parameters are passed as name and value pairs:
+//
+//	router.URL("notepad.edit", "id", 5)
+func URL(name string, params ...interface{}) (string, error) {
+	nameMutex.RLock()
+	rt, ok := names[name]
+	nameMutex.RUnlock()
+
+	if !ok {
+		return "", errors.New("router: route name is not registered: " + name)
+	}
+
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("router: URL parameters for %v must be name and value pairs", name)
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[fmt.Sprint(params[i])] = fmt.Sprint(params[i+1])
+	}
+
+	segments := strings.Split(rt.Path, "/")
+	for i, s := range segments {
+		param, _, ok := ParamSegment(s)
+		if !ok {
+			continue
+		}
+
+		v, ok := values[param]
+		if !ok {
+			return "", fmt.Errorf("router: URL parameter %v is missing for %v", param, name)
+		}
+		delete(values, param)
+
+		if check, ok := rt.constraints[param]; ok && !check(v) {
+			return "", fmt.Errorf("router: URL parameter %v does not match the constraint for %v", param, name)
+		}
+
+		if s[0] == '*' {
+			// Wildcards can span segments so only escape each segment
+			parts := strings.Split(v, "/")
+			for j := range parts {
+				parts[j] = url.PathEscape(parts[j])
+			}
+			segments[i] = strings.Join(parts, "/")
+		} else {
+			segments[i] = url.PathEscape(v)
+		}
+	}
+
+	for k := range values {
+		return "", fmt.Errorf("router: URL parameter %v is not in the path for %v", k, name)
+	}
+
+	return strings.Join(segments, "/"), nil
+}
@@ -0,0 +1,86 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// TestURL ensures the path of a named route is built properly.
+func TestURL(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	router.Get("/notepad", handler).Named("notepad.index")
+	router.Get("/notepad/edit/:id", handler).Named("notepad.edit")
+	router.Get("/static/*filepath", handler).Named("static")
+
+	tests := []struct {
+		name     string
+		params   []interface{}
+		expected string
+	}{
+		{"notepad.index", nil, "/notepad"},
+		{"notepad.edit", []interface{}{"id", 5}, "/notepad/edit/5"},
+		{"notepad.edit", []interface{}{"id", "a b"}, "/notepad/edit/a%20b"},
+		{"static", []interface{}{"filepath", "css/all.css"}, "/static/css/all.css"},
+	}
+
+	for _, test := range tests {
+		actual, err := router.URL(test.name, test.params...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != test.expected {
+			t.Fatalf("\nactual: %v\nexpected: %v", actual, test.expected)
+		}
+	}
+}
+
+// TestURLFail ensures an error is returned for invalid names or parameters.
+func TestURLFail(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	router.Get("/notepad/edit/:id", handler).Named("notepad.edit")
+
+	tests := []struct {
+		name   string
+		params []interface{}
+	}{
+		{"missing", nil},
+		{"notepad.edit", nil},
+		{"notepad.edit", []interface{}{"id"}},
+		{"notepad.edit", []interface{}{"id", 5, "foo", "bar"}},
+	}
+
+	for _, test := range tests {
+		if _, err := router.URL(test.name, test.params...); err == nil {
+			t.Fatalf("expected an error for %v %v", test.name, test.params)
+		}
+	}
+}
+
+// TestNamedDuplicate ensures a route name can only be used once.
+func TestNamedDuplicate(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	router.Get("/foo", handler).Named("foo")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a duplicate name")
+		}
+	}()
+
+	router.Get("/bar", handler).Named("foo")
+}
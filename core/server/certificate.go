@@ -0,0 +1,131 @@
+package server
+
+import (
+	"crypto/tls"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// certificate provides the TLS certificate to the HTTPS listener and swaps
+// it when the files are rotated on disk.
+type certificate struct {
+	certFile string
+	keyFile  string
+
+	current atomic.Value // *tls.Certificate
+
+	modTime time.Time
+	mutex   sync.Mutex
+}
+
+// newCertificate loads the certificate and private key pair.
+func newCertificate(certFile, keyFile string) (*certificate, error) {
+	c := &certificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// GetCertificate returns the current certificate. It is used as the
+// GetCertificate func of tls.Config.
+func (c *certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.current.Load().(*tls.Certificate), nil
+}
+
+// Reload reads the files and swaps the certificate. The current certificate
+// is kept if the files cannot be loaded.
+func (c *certificate) Reload() error {
+	err := c.load()
+	if err != nil {
+		log.Println("TLS certificate reload failed:", err)
+	} else {
+		log.Println("TLS certificate reloaded:", c.certFile)
+	}
+
+	return err
+}
+
+// reloadIfChanged reloads the certificate if either file was modified since
+// the last load. Returns true if a reload was attempted.
+func (c *certificate) reloadIfChanged() (bool, error) {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return false, err
+	}
+
+	c.mutex.Lock()
+	changed := !modTime.Equal(c.modTime)
+	c.mutex.Unlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	return true, c.Reload()
+}
+
+// watch checks the files for changes at every interval until stop is closed.
+func (c *certificate) watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := c.reloadIfChanged(); err != nil {
+				log.Println("TLS certificate check failed:", err)
+			}
+		}
+	}
+}
+
+// load reads the files and stores the certificate.
+func (c *certificate) load() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Read the modification time first so a change during the load is
+	// picked up by the next check
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.current.Store(&cert)
+	c.modTime = modTime
+
+	return nil
+}
+
+// latestModTime returns the most recent modification time of the files.
+func (c *certificate) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, name := range []string{c.certFile, c.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return latest, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate generates a self-signed certificate with the serial
+// number and writes the pair to the folder.
+func writeCertificate(t *testing.T, folder string, serial int64, modTime time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(folder, "server.crt")
+	keyFile := filepath.Join(folder, "server.key")
+
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set the time explicitly since the file system may only store seconds
+	for _, name := range []string{certFile, keyFile} {
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return certFile, keyFile
+}
+
+// serial returns the serial number of the certificate.
+func serial(t *testing.T, c *certificate) int64 {
+	cert, err := c.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leaf.SerialNumber.Int64()
+}
+
+// TestCertificateReload ensures the certificate is swapped after the files
+// change.
+func TestCertificateReload(t *testing.T) {
+	folder := t.TempDir()
+	now := time.Now()
+
+	certFile, keyFile := writeCertificate(t, folder, 1, now)
+
+	c, err := newCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := serial(t, c); s != 1 {
+		t.Fatalf("\n got: %v\nwant: %v", s, 1)
+	}
+
+	// The files have not changed
+	reloaded, err := c.reloadIfChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded {
+		t.Error("certificate should not reload when the files are unchanged")
+	}
+
+	// Rotate the certificate
+	writeCertificate(t, folder, 2, now.Add(time.Minute))
+
+	reloaded, err = c.reloadIfChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded {
+		t.Error("certificate should reload when the files change")
+	}
+
+	if s := serial(t, c); s != 2 {
+		t.Fatalf("\n got: %v\nwant: %v", s, 2)
+	}
+}
+
+// TestCertificateReloadFail ensures the current certificate is kept when the
+// new files are invalid.
+func TestCertificateReloadFail(t *testing.T) {
+	folder := t.TempDir()
+	now := time.Now()
+
+	certFile, keyFile := writeCertificate(t, folder, 1, now)
+
+	c, err := newCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(certFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := now.Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.reloadIfChanged(); err == nil {
+		t.Error("expected an error for an invalid certificate")
+	}
+
+	if s := serial(t, c); s != 1 {
+		t.Fatalf("\n got: %v\nwant: %v", s, 1)
+	}
+}
+
+// TestCertificateServe ensures new connections receive the reloaded
+// certificate.
+func TestCertificateServe(t *testing.T) {
+	folder := t.TempDir()
+	now := time.Now()
+
+	certFile, keyFile := writeCertificate(t, folder, 1, now)
+
+	info := Info{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}
+
+	l, err := listen("127.0.0.1:0", http.NotFoundHandler(), info, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serve(l)
+	defer shutdown([]*listener{l}, time.Second)
+
+	// peerSerial connects and returns the serial number of the certificate
+	peerSerial := func() int64 {
+		conn, err := tls.Dial("tcp", l.ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if s := peerSerial(); s != 1 {
+		t.Fatalf("\n got: %v\nwant: %v", s, 1)
+	}
+
+	writeCertificate(t, folder, 2, now.Add(time.Minute))
+	reloadCertificates([]*listener{l})
+
+	if s := peerSerial(); s != 2 {
+		t.Fatalf("\n got: %v\nwant: %v", s, 2)
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address of the client without the port. The
+// X-Forwarded-For and X-Real-IP headers are only read when the connection
+// comes from one of the TrustedProxies because any client can send them.
+// Without TrustedProxies every client behind a reverse proxy has the address
+// of the proxy.
+func (i Info) ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	proxies, err := trustedProxies(i.TrustedProxies)
+	if err != nil || !trusted(proxies, ip) {
+		return ip
+	}
+
+	// Walk back from the nearest proxy to the first address it did not add
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		list := strings.Split(strings.Join(forwarded, ","), ",")
+		for n := len(list) - 1; n >= 0; n-- {
+			addr := strings.TrimSpace(list[n])
+			if net.ParseIP(addr) == nil {
+				break
+			}
+			ip = addr
+			if !trusted(proxies, addr) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if addr := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(addr) != nil {
+		return addr
+	}
+
+	return ip
+}
+
+// trustedProxies parses the addresses and CIDR ranges of the proxies.
+func trustedProxies(list []string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy: %v", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %v", s)
+		}
+		proxies = append(proxies, n)
+	}
+
+	return proxies, nil
+}
+
+// trusted returns true if the address is one of the proxies.
+func trusted(proxies []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
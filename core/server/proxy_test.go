@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClientIP ensures the forwarded headers are only read from a trusted
+// proxy and a client cannot choose its own address.
+func TestClientIP(t *testing.T) {
+	i := Info{TrustedProxies: []string{"10.0.0.1", "192.168.0.0/16", "::1"}}
+
+	for _, tt := range []struct {
+		info      Info
+		remote    string
+		forwarded []string
+		realIP    string
+		expected  string
+	}{
+		// No proxy is trusted so the headers are ignored
+		{Info{}, "203.0.113.5:1234", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.5"},
+		// The client is not a proxy
+		{i, "203.0.113.5:1234", []string{"198.51.100.1"}, "", "203.0.113.5"},
+		// The address added by the proxy is used
+		{i, "10.0.0.1:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{i, "[::1]:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		// An address sent by the client before the proxies is ignored
+		{i, "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1, 192.168.1.1"}, "", "198.51.100.1"},
+		{i, "10.0.0.1:1234", []string{"1.2.3.4", "198.51.100.1"}, "", "198.51.100.1"},
+		// Every address is a proxy
+		{i, "10.0.0.1:1234", []string{"192.168.1.2, 192.168.1.1"}, "", "192.168.1.2"},
+		// A value that is not an address stops the walk
+		{i, "10.0.0.1:1234", []string{"198.51.100.1, unknown"}, "", "10.0.0.1"},
+		// The proxy sends X-Real-IP instead
+		{i, "10.0.0.1:1234", nil, "198.51.100.2", "198.51.100.2"},
+		{i, "10.0.0.1:1234", nil, "", "10.0.0.1"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		for _, v := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if len(tt.realIP) > 0 {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+
+		if received := tt.info.ClientIP(r); received != tt.expected {
+			t.Errorf("%v %v: \nactual: %v\nexpected: %v", tt.remote, tt.forwarded, received, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// envListeners holds the comma separated addresses of the sockets passed to
+// a new process. The first socket is file descriptor 3, the next is 4, etc.
+const envListeners = "SERVER_LISTENERS"
+
+// filer is implemented by listeners that can expose their socket.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// inheritListeners returns the sockets passed down from a parent process
+// keyed by address.
+func inheritListeners() (map[string]net.Listener, error) {
+	list := make(map[string]net.Listener)
+
+	value := os.Getenv(envListeners)
+	if len(value) == 0 {
+		return list, nil
+	}
+
+	// Prevent the value from leaking into processes started later
+	os.Unsetenv(envListeners)
+
+	for i, address := range strings.Split(value, ",") {
+		f := os.NewFile(uintptr(3+i), address)
+		if f == nil {
+			return nil, fmt.Errorf("inherited listener %v is not a valid file", address)
+		}
+
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherited listener %v: %v", address, err)
+		}
+
+		list[address] = ln
+	}
+
+	return list, nil
+}
+
+// restart starts a new copy of the running binary and passes it the
+// listening sockets so no connections are refused while this process drains.
+func restart(list []*listener) error {
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	var files []*os.File
+	var addresses []string
+
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, l := range list {
+		fl, ok := l.ln.(filer)
+		if !ok {
+			return errors.New("listener for " + l.address + " cannot be passed to a new process")
+		}
+
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+
+		files = append(files, f)
+		addresses = append(addresses, l.address)
+	}
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), envListeners+"="+strings.Join(addresses, ","))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+
+	return cmd.Start()
+}
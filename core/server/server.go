@@ -3,43 +3,146 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
+// DefaultShutdownTimeout is used when ShutdownTimeout is not set.
+const DefaultShutdownTimeout = 30 * time.Second
+
 // Info stores the hostname and port number.
 type Info struct {
-	Hostname        string `json:"Hostname"`        // Server name
-	UseHTTP         bool   `json:"UseHTTP"`         // Listen on HTTP
-	UseHTTPS        bool   `json:"UseHTTPS"`        // Listen on HTTPS
-	HTTPPort        int    `json:"HTTPPort"`        // HTTP port
-	HTTPSPort       int    `json:"HTTPSPort"`       // HTTPS port
-	RedirectToHTTPS bool   `json:"RedirectToHTTPS"` // Redirect to HTTPS
-	CertFile        string `json:"CertFile"`        // HTTPS certificate
-	KeyFile         string `json:"KeyFile"`         // HTTPS private key
+	Hostname        string   `json:"Hostname"`        // Server name
+	UseHTTP         bool     `json:"UseHTTP"`         // Listen on HTTP
+	UseHTTPS        bool     `json:"UseHTTPS"`        // Listen on HTTPS
+	HTTPPort        int      `json:"HTTPPort"`        // HTTP port
+	HTTPSPort       int      `json:"HTTPSPort"`       // HTTPS port
+	RedirectToHTTPS bool     `json:"RedirectToHTTPS"` // Redirect to HTTPS
+	CertFile        string   `json:"CertFile"`        // HTTPS certificate
+	KeyFile         string   `json:"KeyFile"`         // HTTPS private key
+	CertReload      int      `json:"CertReload"`      // Seconds between checks for a rotated certificate
+	ShutdownTimeout int      `json:"ShutdownTimeout"` // Seconds to wait for active requests on shutdown
+	GracefulRestart bool     `json:"GracefulRestart"` // Hand the listeners to a new process on SIGHUP
+	TrustedProxies  []string `json:"TrustedProxies"`  // Addresses or CIDR ranges of the reverse proxies whose X-Forwarded-For is read
+	PublicURL       string   `json:"PublicURL"`       // Scheme and host for links sent outside the site like "https://example.com", required for emails
+
+	ReadTimeout       int `json:"ReadTimeout"`       // Seconds to read the entire request
+	ReadHeaderTimeout int `json:"ReadHeaderTimeout"` // Seconds to read the request headers
+	WriteTimeout      int `json:"WriteTimeout"`      // Seconds to write the response
+	IdleTimeout       int `json:"IdleTimeout"`       // Seconds to keep an idle keep-alive connection
+	MaxHeaderBytes    int `json:"MaxHeaderBytes"`    // Maximum size of the request headers
+
+	TLSMinVersion   string   `json:"TLSMinVersion"`   // Minimum TLS version: 1.0, 1.1, 1.2, or 1.3
+	TLSCipherSuites []string `json:"TLSCipherSuites"` // Cipher suite names for TLS 1.2 and below
+
+	DisableHTTP2              bool `json:"DisableHTTP2"`              // Only serve HTTP/1.x over HTTPS
+	UnencryptedHTTP2          bool `json:"UnencryptedHTTP2"`          // Serve HTTP/2 without TLS (h2c) over HTTP
+	HTTP2MaxConcurrentStreams int  `json:"HTTP2MaxConcurrentStreams"` // Streams a client may have open at a time
 }
 
-// Run starts the HTTP and/or HTTPS listener.
+// listener pairs a server with the socket it accepts connections on.
+type listener struct {
+	server  *http.Server
+	ln      net.Listener
+	secure  bool
+	address string
+	cert    *certificate
+}
+
+// Run starts the HTTP and/or HTTPS listener. It blocks until the process
+// receives SIGINT or SIGTERM and the active requests are finished.
+//
+// The HTTPS certificate is reloaded when the files change on disk. It is also
+// reloaded on SIGHUP unless GracefulRestart is enabled.
 func Run(httpHandlers http.Handler, httpsHandlers http.Handler, info Info) {
 	// Determine if HTTP should redirect to HTTPS
 	if info.RedirectToHTTPS {
 		httpHandlers = http.HandlerFunc(redirectToHTTPS)
 	}
 
-	switch {
-	case info.UseHTTP && info.UseHTTPS:
-		go func() {
-			startHTTPS(httpsHandlers, info)
-		}()
-		startHTTP(httpHandlers, info)
-	case info.UseHTTP:
-		startHTTP(httpHandlers, info)
-	case info.UseHTTPS:
-		startHTTPS(httpsHandlers, info)
-	default:
+	// Retrieve any sockets passed down from a parent process
+	inherited, err := inheritListeners()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var list []*listener
+
+	if info.UseHTTPS {
+		l, err := listen(httpsAddress(info), httpsHandlers, info, true, inherited)
+		if err != nil {
+			log.Fatal(err)
+		}
+		list = append(list, l)
+	}
+
+	if info.UseHTTP {
+		l, err := listen(httpAddress(info), httpHandlers, info, false, inherited)
+		if err != nil {
+			log.Fatal(err)
+		}
+		list = append(list, l)
+	}
+
+	if len(list) == 0 {
 		log.Println("Config file does not specify a listener to start")
+		return
+	}
+
+	// Trap the signals before serving so none are missed
+	signals := []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	if info.GracefulRestart || info.UseHTTPS {
+		signals = append(signals, syscall.SIGHUP)
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, signals...)
+	defer signal.Stop(sig)
+
+	// Stop watching the certificate files on shutdown
+	stop := make(chan struct{})
+	defer close(stop)
+
+	errs := make(chan error, len(list))
+	for _, l := range list {
+		if l.cert != nil && info.CertReload > 0 {
+			go l.cert.watch(seconds(info.CertReload), stop)
+		}
+
+		go func(l *listener) {
+			errs <- serve(l)
+		}(l)
+	}
+
+	for {
+		select {
+		case err := <-errs:
+			log.Fatal(err)
+		case s := <-sig:
+			if s == syscall.SIGHUP && !info.GracefulRestart {
+				reloadCertificates(list)
+				continue
+			}
+
+			if s == syscall.SIGHUP {
+				if err := restart(list); err != nil {
+					log.Println("Restart failed:", err)
+					continue
+				}
+			}
+
+			fmt.Println(time.Now().Format("2006-01-02 03:04:05 PM"), "Shutting down on", s)
+			shutdown(list, shutdownTimeout(info))
+			return
+		}
 	}
 }
 
@@ -48,20 +151,185 @@ func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "https://"+r.Host, http.StatusMovedPermanently)
 }
 
-// startHTTP starts the HTTP listener.
-func startHTTP(handlers http.Handler, info Info) {
-	fmt.Println(time.Now().Format("2006-01-02 03:04:05 PM"), "Running HTTP "+httpAddress(info))
+// listen returns a listener for the address, reusing an inherited socket when
+// one is available.
+func listen(address string, handlers http.Handler, info Info, secure bool, inherited map[string]net.Listener) (*listener, error) {
+	srv, err := newServer(address, handlers, info, secure)
+	if err != nil {
+		return nil, err
+	}
+
+	var cert *certificate
+	if secure {
+		if cert, err = newCertificate(info.CertFile, info.KeyFile); err != nil {
+			return nil, err
+		}
+		srv.TLSConfig.GetCertificate = cert.GetCertificate
+	}
+
+	ln, ok := inherited[address]
+	if !ok {
+		if ln, err = net.Listen("tcp", address); err != nil {
+			return nil, err
+		}
+	}
+
+	return &listener{
+		server:  srv,
+		ln:      ln,
+		secure:  secure,
+		address: address,
+		cert:    cert,
+	}, nil
+}
+
+// newServer returns a server with the limits from the config applied.
+func newServer(address string, handlers http.Handler, info Info, secure bool) (*http.Server, error) {
+	// Fail at startup instead of ignoring a proxy on every request
+	if _, err := trustedProxies(info.TrustedProxies); err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{
+		Addr:              address,
+		Handler:           handlers,
+		ReadTimeout:       seconds(info.ReadTimeout),
+		ReadHeaderTimeout: seconds(info.ReadHeaderTimeout),
+		WriteTimeout:      seconds(info.WriteTimeout),
+		IdleTimeout:       seconds(info.IdleTimeout),
+		MaxHeaderBytes:    info.MaxHeaderBytes,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: info.HTTP2MaxConcurrentStreams,
+		},
+	}
+
+	// Set the protocols for the listener
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	if secure {
+		p.SetHTTP2(!info.DisableHTTP2)
+	} else {
+		p.SetUnencryptedHTTP2(info.UnencryptedHTTP2)
+	}
+	srv.Protocols = p
+
+	if secure {
+		config, err := tlsConfig(info)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = config
+	}
+
+	return srv, nil
+}
+
+// tlsConfig returns the TLS settings from the config.
+func tlsConfig(info Info) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if len(info.TLSMinVersion) > 0 {
+		version, ok := tlsVersions[info.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("TLS version is not supported: %v", info.TLSMinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	for _, name := range info.TLSCipherSuites {
+		id, ok := cipherSuite(name)
+		if !ok {
+			return nil, fmt.Errorf("TLS cipher suite is not supported: %v", name)
+		}
+		config.CipherSuites = append(config.CipherSuites, id)
+	}
+
+	return config, nil
+}
+
+// tlsVersions maps the config values to the TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// cipherSuite returns the ID of the cipher suite with the name.
+func cipherSuite(name string) (uint16, bool) {
+	for _, list := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, c := range list {
+			if c.Name == name {
+				return c.ID, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// seconds converts a config value to a duration.
+func seconds(i int) time.Duration {
+	return time.Duration(i) * time.Second
+}
+
+// serve starts accepting connections and only returns an error if the server
+// stopped for a reason other than a shutdown.
+func serve(l *listener) error {
+	var err error
+
+	if l.secure {
+		fmt.Println(time.Now().Format("2006-01-02 03:04:05 PM"), "Running HTTPS "+l.address)
+		// The certificate is provided by the TLS config
+		err = l.server.ServeTLS(l.ln, "", "")
+	} else {
+		fmt.Println(time.Now().Format("2006-01-02 03:04:05 PM"), "Running HTTP "+l.address)
+		err = l.server.Serve(l.ln)
+	}
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
 
-	// Start the HTTP listener
-	log.Fatal(http.ListenAndServe(httpAddress(info), handlers))
+	return err
 }
 
-// startHTTPs starts the HTTPS listener.
-func startHTTPS(handlers http.Handler, info Info) {
-	fmt.Println(time.Now().Format("2006-01-02 03:04:05 PM"), "Running HTTPS "+httpsAddress(info))
+// reloadCertificates reloads the certificate of each HTTPS listener.
+func reloadCertificates(list []*listener) {
+	for _, l := range list {
+		if l.cert != nil {
+			l.cert.Reload()
+		}
+	}
+}
+
+// shutdown stops the listeners from accepting new connections and waits for
+// the active requests to finish or for the timeout to pass.
+func shutdown(list []*listener, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, l := range list {
+		wg.Add(1)
+		go func(l *listener) {
+			defer wg.Done()
+			if err := l.server.Shutdown(ctx); err != nil {
+				log.Println("Shutdown of", l.address, "did not complete:", err)
+				l.server.Close()
+			}
+		}(l)
+	}
+	wg.Wait()
+}
+
+// shutdownTimeout returns how long to wait for active requests.
+func shutdownTimeout(info Info) time.Duration {
+	if info.ShutdownTimeout <= 0 {
+		return DefaultShutdownTimeout
+	}
 
-	// Start the HTTPS listener
-	log.Fatal(http.ListenAndServeTLS(httpsAddress(info), info.CertFile, info.KeyFile, handlers))
+	return seconds(info.ShutdownTimeout)
 }
 
 // httpAddress returns the HTTP address.
@@ -1,10 +1,14 @@
 package server
 
 import (
+	"crypto/tls"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 // TestHttpAddress ensures the correct address is returned.
@@ -60,3 +64,165 @@ func TestRedirectToHttps(t *testing.T) {
 		t.Errorf("\n got: %v\nwant: %v", received, expected)
 	}
 }
+
+// TestShutdownTimeout ensures the default timeout is used when not set.
+func TestShutdownTimeout(t *testing.T) {
+	received := shutdownTimeout(Info{})
+	expected := DefaultShutdownTimeout
+
+	if expected != received {
+		t.Errorf("\n got: %v\nwant: %v", received, expected)
+	}
+
+	received = shutdownTimeout(Info{ShutdownTimeout: 5})
+	expected = 5 * time.Second
+
+	if expected != received {
+		t.Errorf("\n got: %v\nwant: %v", received, expected)
+	}
+}
+
+// TestShutdownWaits ensures an active request finishes before shutdown
+// returns.
+func TestShutdownWaits(t *testing.T) {
+	started := make(chan bool)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(w, "done")
+	})
+
+	l, err := listen("127.0.0.1:0", handler, Info{}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve(l)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+
+	go func() {
+		resp, err := http.Get("http://" + l.ln.Addr().String())
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		results <- result{string(b), err}
+	}()
+
+	<-started
+	shutdown([]*listener{l}, time.Second)
+
+	res := <-results
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+
+	expected := "done"
+	received := res.body
+
+	if expected != received {
+		t.Errorf("\n got: %v\nwant: %v", received, expected)
+	}
+
+	if err := <-errs; err != nil {
+		t.Errorf("serve should not return an error after shutdown: %v", err)
+	}
+
+	// The listener should no longer accept connections
+	if _, err := net.Dial("tcp", l.ln.Addr().String()); err == nil {
+		t.Error("listener should be closed after shutdown")
+	}
+}
+
+// TestInheritListenersEmpty ensures no listeners are returned without a
+// parent process.
+func TestInheritListenersEmpty(t *testing.T) {
+	list, err := inheritListeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 0 {
+		t.Errorf("\n got: %v\nwant: %v", len(list), 0)
+	}
+}
+
+// TestNewServer ensures the limits are applied to the server.
+func TestNewServer(t *testing.T) {
+	i := Info{
+		ReadTimeout:       5,
+		ReadHeaderTimeout: 2,
+		WriteTimeout:      10,
+		IdleTimeout:       60,
+		MaxHeaderBytes:    1 << 16,
+		TLSMinVersion:     "1.2",
+		TLSCipherSuites:   []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		DisableHTTP2:      true,
+	}
+
+	s, err := newServer(":443", http.NotFoundHandler(), i, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ReadTimeout != 5*time.Second || s.ReadHeaderTimeout != 2*time.Second ||
+		s.WriteTimeout != 10*time.Second || s.IdleTimeout != 60*time.Second {
+		t.Errorf("timeouts not applied: %v %v %v %v", s.ReadTimeout, s.ReadHeaderTimeout, s.WriteTimeout, s.IdleTimeout)
+	}
+
+	if s.MaxHeaderBytes != i.MaxHeaderBytes {
+		t.Errorf("\n got: %v\nwant: %v", s.MaxHeaderBytes, i.MaxHeaderBytes)
+	}
+
+	if s.TLSConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("\n got: %v\nwant: %v", s.TLSConfig.MinVersion, tls.VersionTLS12)
+	}
+
+	if len(s.TLSConfig.CipherSuites) != 1 || s.TLSConfig.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("cipher suites not applied: %v", s.TLSConfig.CipherSuites)
+	}
+
+	if s.Protocols.HTTP2() {
+		t.Error("HTTP/2 should be disabled")
+	}
+
+	// HTTP listeners should not have a TLS config
+	s, err = newServer(":80", http.NotFoundHandler(), Info{UnencryptedHTTP2: true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.TLSConfig != nil {
+		t.Error("HTTP server should not have a TLS config")
+	}
+
+	if !s.Protocols.UnencryptedHTTP2() {
+		t.Error("unencrypted HTTP/2 should be enabled")
+	}
+}
+
+// TestNewServerFail ensures invalid TLS and proxy settings are rejected.
+func TestNewServerFail(t *testing.T) {
+	if _, err := newServer(":443", nil, Info{TLSMinVersion: "2.0"}, true); err == nil {
+		t.Error("expected an error for an invalid TLS version")
+	}
+
+	if _, err := newServer(":443", nil, Info{TLSCipherSuites: []string{"FOO"}}, true); err == nil {
+		t.Error("expected an error for an invalid cipher suite")
+	}
+
+	if _, err := newServer(":80", nil, Info{TrustedProxies: []string{"10.0.0"}}, false); err == nil {
+		t.Error("expected an error for an invalid trusted proxy")
+	}
+}
@@ -0,0 +1,63 @@
+package session
+
+import (
+	"time"
+
+	"github.com/pcieslar/goforge/lib/gorm"
+)
+
+// DatabaseBackend keeps the sessions in the session table with any of the
+// gorm dialects like MySQL or Postgres.
+type DatabaseBackend struct {
+	DB *gorm.DB
+}
+
+// NewDatabaseBackend returns a backend for the database connection.
+func NewDatabaseBackend(db *gorm.DB) *DatabaseBackend {
+	return &DatabaseBackend{
+		DB: db,
+	}
+}
+
+// Load returns the session.
+func (b *DatabaseBackend) Load(id string) (Record, error) {
+	var r Record
+
+	err := b.DB.Where("id = ?", id).First(&r).Error
+	if err == gorm.ErrRecordNotFound {
+		return r, ErrNotFound
+	}
+
+	return r, err
+}
+
+// Save stores the session in a single statement so requests that save a new
+// session at the same time do not both try to create it. The creation time
+// is only set for a new session.
+func (b *DatabaseBackend) Save(r Record) error {
+	query := "INSERT INTO session (id, user_id, data, user_agent, ip, created_at, updated_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?) "
+	if b.DB.Dialect().GetName() == "mysql" {
+		query += "ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), data = VALUES(data), user_agent = VALUES(user_agent), ip = VALUES(ip), updated_at = VALUES(updated_at), expires_at = VALUES(expires_at)"
+	} else {
+		query += "ON CONFLICT (id) DO UPDATE SET user_id = excluded.user_id, data = excluded.data, user_agent = excluded.user_agent, ip = excluded.ip, updated_at = excluded.updated_at, expires_at = excluded.expires_at"
+	}
+
+	return b.DB.Exec(query, r.ID, r.UserID, r.Data, r.UserAgent, r.IP, r.CreatedAt, r.UpdatedAt, r.ExpiresAt).Error
+}
+
+// Delete removes the session.
+func (b *DatabaseBackend) Delete(id string) error {
+	return b.DB.Where("id = ?", id).Delete(&Record{}).Error
+}
+
+// DeleteExpired removes the sessions that expired before the time.
+func (b *DatabaseBackend) DeleteExpired(now time.Time) error {
+	return b.DB.Where("expires_at < ?", now).Delete(&Record{}).Error
+}
+
+// ByUserID returns the sessions of the user.
+func (b *DatabaseBackend) ByUserID(userID string) ([]Record, error) {
+	var list []Record
+	err := b.DB.Where("user_id = ?", userID).Order("updated_at desc").Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,154 @@
+package session
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// filePrefix is the start of the name of each session file.
+const filePrefix = "session_"
+
+// FileBackend keeps each session in a JSON file in the folder.
+type FileBackend struct {
+	Folder string
+	mutex  sync.RWMutex
+}
+
+// NewFileBackend returns a backend for the folder and creates the folder if
+// it does not exist.
+func NewFileBackend(folder string) (*FileBackend, error) {
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return nil, err
+	}
+
+	return &FileBackend{
+		Folder: folder,
+	}, nil
+}
+
+// Load returns the session.
+func (b *FileBackend) Load(id string) (Record, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.read(b.path(id))
+}
+
+// Save stores the session.
+func (b *FileBackend) Save(r Record) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	path := b.path(r.ID)
+	if old, err := b.read(path); err == nil {
+		r.CreatedAt = old.CreatedAt
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a session is never half written
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Delete removes the session.
+func (b *FileBackend) Delete(id string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := os.Remove(b.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the sessions that expired before the time.
+func (b *FileBackend) DeleteExpired(now time.Time) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.each(func(path string, r Record) error {
+		if r.ExpiresAt.Before(now) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// ByUserID returns the sessions of the user.
+func (b *FileBackend) ByUserID(userID string) ([]Record, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	var list []Record
+	err := b.each(func(path string, r Record) error {
+		if r.UserID == userID {
+			list = append(list, r)
+		}
+		return nil
+	})
+
+	return list, err
+}
+
+// path returns the file of the session. The ID only contains base32
+// characters so it is safe in a file name.
+func (b *FileBackend) path(id string) string {
+	return filepath.Join(b.Folder, filePrefix+id)
+}
+
+// read returns the session in the file.
+func (b *FileBackend) read(path string) (Record, error) {
+	var r Record
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, ErrNotFound
+	} else if err != nil {
+		return r, err
+	}
+
+	err = json.Unmarshal(data, &r)
+	return r, err
+}
+
+// each calls the func for every session file.
+func (b *FileBackend) each(fn func(path string, r Record) error) error {
+	files, err := ioutil.ReadDir(b.Folder)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasPrefix(fi.Name(), filePrefix) || strings.HasSuffix(fi.Name(), ".tmp") {
+			continue
+		}
+
+		path := filepath.Join(b.Folder, fi.Name())
+		r, err := b.read(path)
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := fn(path, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
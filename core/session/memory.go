@@ -0,0 +1,82 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps the sessions in memory so they are lost when the
+// application stops. It only works with a single instance of the application.
+type MemoryBackend struct {
+	records map[string]Record
+	mutex   sync.RWMutex
+}
+
+// NewMemoryBackend returns an empty backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		records: make(map[string]Record),
+	}
+}
+
+// Load returns the session.
+func (b *MemoryBackend) Load(id string) (Record, error) {
+	b.mutex.RLock()
+	rec, ok := b.records[id]
+	b.mutex.RUnlock()
+
+	if !ok {
+		return rec, ErrNotFound
+	}
+
+	return rec, nil
+}
+
+// Save stores the session.
+func (b *MemoryBackend) Save(r Record) error {
+	b.mutex.Lock()
+	if old, ok := b.records[r.ID]; ok {
+		r.CreatedAt = old.CreatedAt
+	}
+	b.records[r.ID] = r
+	b.mutex.Unlock()
+
+	return nil
+}
+
+// Delete removes the session.
+func (b *MemoryBackend) Delete(id string) error {
+	b.mutex.Lock()
+	delete(b.records, id)
+	b.mutex.Unlock()
+
+	return nil
+}
+
+// DeleteExpired removes the sessions that expired before the time.
+func (b *MemoryBackend) DeleteExpired(now time.Time) error {
+	b.mutex.Lock()
+	for id, rec := range b.records {
+		if rec.ExpiresAt.Before(now) {
+			delete(b.records, id)
+		}
+	}
+	b.mutex.Unlock()
+
+	return nil
+}
+
+// ByUserID returns the sessions of the user.
+func (b *MemoryBackend) ByUserID(userID string) ([]Record, error) {
+	var list []Record
+
+	b.mutex.RLock()
+	for _, rec := range b.records {
+		if rec.UserID == userID {
+			list = append(list, rec)
+		}
+	}
+	b.mutex.RUnlock()
+
+	return list, nil
+}
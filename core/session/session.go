@@ -1,55 +1,160 @@
-// Package session provides a wrapper for gorilla/sessions package.
+// Package session provides a wrapper for gorilla/sessions package. The values
+// are kept in the cookie or on the server in a database, files, or memory.
 package session
 
 import (
 	"encoding/base64"
 	"errors"
 	"net/http"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/gorm"
 
 	"github.com/gorilla/sessions"
 )
 
+// The stores that can be chosen with Info.Store.
+const (
+	StoreCookie   = "cookie"
+	StoreDatabase = "database"
+	StoreFile     = "file"
+	StoreMemory   = "memory"
+)
+
+var (
+	// ErrCookieStore is when the sessions are not kept on the server so they
+	// cannot be listed or destroyed.
+	ErrCookieStore = errors.New("Sessions in the cookie store cannot be listed or destroyed.")
+
+	// ErrStore is when the store is not supported.
+	ErrStore = errors.New("Session store must be cookie, database, file, or memory.")
+
+	// ErrNoDatabase is when the database store has no connection.
+	ErrNoDatabase = errors.New("Session database connection is missing.")
+)
+
 // Info holds the session level information.
 type Info struct {
 	Options    sessions.Options `json:"Options"`    // Pulled from: http://www.gorillatoolkit.org/pkg/sessions#Options
 	Name       string           `json:"Name"`       // Name for: http://www.gorillatoolkit.org/pkg/sessions#CookieStore.Get
 	AuthKey    string           `json:"AuthKey"`    // Key for: http://www.gorillatoolkit.org/pkg/sessions#NewCookieStore
 	EncryptKey string           `json:"EncryptKey"` // Key for: http://www.gorillatoolkit.org/pkg/sessions#NewCookieStore
+	Keys       []KeyPair        `json:"Keys"`       // Key pairs newest first, tried before AuthKey and EncryptKey
 	CSRFKey    string           `json:"CSRFKey"`    // Key for: http://www.gorillatoolkit.org/pkg/csrf#Protect
-	store      *sessions.CookieStore
+	Store      string           `json:"Store"`      // Where the values are kept: cookie, database, file, or memory
+	Folder     string           `json:"Folder"`     // Folder for the file store
+	Cleanup    int              `json:"Cleanup"`    // Seconds between removing the expired sessions from the server
+	store      sessions.Store
+	server     *ServerStore
+	db         *gorm.DB
+}
+
+// KeyPair holds an authentication key and an optional encryption key, both
+// base64 encoded.
+type KeyPair struct {
+	AuthKey    string `json:"AuthKey"`    // Key to sign the cookie
+	EncryptKey string `json:"EncryptKey"` // Key to encrypt the cookie, optional
 }
 
 // SetupConfig applies the config and returns an error if it cannot be setup.
 func (i *Info) SetupConfig() error {
-	// Check for AuthKey
-	if len(i.AuthKey) == 0 {
-		return errors.New("Session AuthKey is missing and is required as a good practice.")
-	}
-
-	// Decode authentication key
-	auth, err := base64.StdEncoding.DecodeString(i.AuthKey)
-	if err != nil || len(auth) == 0 {
+	keyPairs, err := i.keyPairs()
+	if err != nil {
 		return err
 	}
 
-	// If the auth key is not set, should error
-	if len(i.EncryptKey) > 0 {
-		// Decode the encrypt key
-		encrypt, err := base64.StdEncoding.DecodeString(i.EncryptKey)
-		if err != nil {
+	// Keep the values on the server unless the cookie store is chosen
+	var backend Backend
+	switch i.Store {
+	case "", StoreCookie:
+		store := sessions.NewCookieStore(keyPairs...)
+		store.Options = &i.Options
+		i.store = store
+		return nil
+	case StoreDatabase:
+		if i.db == nil {
+			return ErrNoDatabase
+		}
+		backend = NewDatabaseBackend(i.db)
+	case StoreFile:
+		if backend, err = NewFileBackend(i.Folder); err != nil {
 			return err
 		}
-		i.store = sessions.NewCookieStore(auth, encrypt)
-	} else {
-		i.store = sessions.NewCookieStore(auth)
+	case StoreMemory:
+		backend = NewMemoryBackend()
+	default:
+		return ErrStore
 	}
 
-	// Store the options in the cookie store.
-	i.store.Options = &i.Options
+	i.server = NewServerStore(backend, keyPairs...)
+
+	// Store the options in the server store.
+	i.server.Options = &i.Options
+	i.store = i.server
 
 	return nil
 }
 
+// keyPairs returns the decoded keys in the order expected by
+// securecookie.CodecsFromPairs. The first pair signs and encrypts new cookies
+// and every pair is tried when reading one so the keys can be rotated by
+// adding a pair to the start of Keys and removing the old one once the
+// sessions that use it have expired.
+func (i *Info) keyPairs() ([][]byte, error) {
+	list := i.Keys
+	if len(i.AuthKey) > 0 || len(i.EncryptKey) > 0 {
+		list = append(list[:len(list):len(list)], KeyPair{i.AuthKey, i.EncryptKey})
+	}
+
+	// Check for AuthKey
+	if len(list) == 0 {
+		return nil, errors.New("Session AuthKey is missing and is required as a good practice.")
+	}
+
+	keyPairs := make([][]byte, 0, len(list)*2)
+
+	for _, k := range list {
+		if len(k.AuthKey) == 0 {
+			return nil, errors.New("Session AuthKey is missing and is required as a good practice.")
+		}
+
+		// Decode authentication key
+		auth, err := base64.StdEncoding.DecodeString(k.AuthKey)
+		if err != nil {
+			return nil, err
+		}
+
+		// Decode the encrypt key, a nil key leaves the cookie unencrypted
+		var encrypt []byte
+		if len(k.EncryptKey) > 0 {
+			if encrypt, err = base64.StdEncoding.DecodeString(k.EncryptKey); err != nil {
+				return nil, err
+			}
+		}
+
+		keyPairs = append(keyPairs, auth, encrypt)
+	}
+
+	return keyPairs, nil
+}
+
+// SetDB sets the connection for the database store. Call it before
+// SetupConfig.
+func (i *Info) SetDB(db *gorm.DB) {
+	i.db = db
+}
+
+// Clean removes the expired sessions from the server at the Cleanup interval.
+// Call the returned func to stop. Nothing is done for the cookie store or
+// when there is no interval.
+func (i *Info) Clean() (stop func()) {
+	if i.server == nil || i.Cleanup <= 0 {
+		return func() {}
+	}
+
+	return i.server.Clean(time.Duration(i.Cleanup) * time.Second)
+}
+
 // *****************************************************************************
 // Session Handling
 // *****************************************************************************
@@ -59,6 +164,87 @@ func (i *Info) Instance(r *http.Request) (*sessions.Session, error) {
 	return i.store.Get(r, i.Name)
 }
 
+// Sessions returns the sessions of the user that have not expired.
+func (i *Info) Sessions(userID string) ([]Record, error) {
+	if i.server == nil {
+		return nil, ErrCookieStore
+	}
+
+	list, err := i.server.Backend.ByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the expired sessions that have not been cleaned up
+	now := time.Now()
+	active := list[:0]
+	for _, r := range list {
+		if r.ExpiresAt.After(now) {
+			active = append(active, r)
+		}
+	}
+
+	return active, nil
+}
+
+// Destroy removes the session so the browser with it is logged out.
+func (i *Info) Destroy(id string) error {
+	if i.server == nil {
+		return ErrCookieStore
+	}
+
+	return i.server.Backend.Delete(id)
+}
+
+// DestroyUser removes every session of the user except the ones passed, like
+// the ID of the current session, to log out all other devices.
+func (i *Info) DestroyUser(userID string, except ...string) error {
+	list, err := i.Sessions(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range list {
+		if contains(except, r.ID) {
+			continue
+		}
+		if err := i.server.Backend.Delete(r.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// contains returns true if the list contains the value.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Regenerate gives the session a new ID and removes the old one from the
+// server. Call it when the privileges change, like on login and logout, so an
+// ID planted or stolen before the change cannot be used after it. The values
+// are kept and the new cookie is sent when the session is saved. Nothing is
+// removed for the cookie store because it keeps no ID.
+func (i *Info) Regenerate(sess *sessions.Session) error {
+	if i.server != nil && len(sess.ID) > 0 {
+		if err := i.server.Backend.Delete(sess.ID); err != nil {
+			return err
+		}
+	}
+
+	sess.ID = ""
+	sess.IsNew = true
+
+	return nil
+}
+
 // Empty deletes all the current session values.
 func Empty(sess *sessions.Session) {
 	// Clear out all stored values in the cookie
@@ -233,3 +233,109 @@ func TestBadEncryptionKey(t *testing.T) {
 		t.Fatal("EncryptKey error was expected.")
 	}
 }
+
+// TestKeyRotation ensures a cookie signed with an old key pair is still read
+// after a new pair is added and is signed with the new pair when saved.
+func TestKeyRotation(t *testing.T) {
+	options := sessions.Options{
+		Path:     "/",
+		MaxAge:   28800,
+		HttpOnly: true,
+	}
+
+	old := session.Info{
+		AuthKey:    "PzCh6FNAB7/jhmlUQ0+25sjJ+WgcJeKR2bAOtnh9UnfVN+WJSBvY/YC80Rs+rbMtwfmSP4FUSxKPtpYKzKFqFA==",
+		EncryptKey: "3oTKCcKjDHMUlV+qur2Ve664SPpSuviyGQ/UqnroUD8=",
+		Name:       "sess",
+		Options:    options,
+	}
+	if err := old.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Save a session with the old keys
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := old.Instance(r)
+	sess.Values["test"] = "foo123"
+	if err := sess.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+	cookie := w.Result().Cookies()[0]
+
+	// Add a new pair before the old one
+	rotated := old
+	rotated.Keys = []session.KeyPair{{
+		AuthKey:    "xULAGF5FcWvqHsXaovNFJYfgCt6pedRPROqNvsZjU18=",
+		EncryptKey: "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=",
+	}}
+	if err := rotated.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddCookie(cookie)
+	sess, err = rotated.Instance(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Values["test"] != "foo123" {
+		t.Fatalf("\nactual: %v\nexpected: %v", sess.Values["test"], "foo123")
+	}
+
+	// Saving signs with the new pair so the old one can be removed
+	w = httptest.NewRecorder()
+	if err := sess.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated.AuthKey = ""
+	rotated.EncryptKey = ""
+	if err := rotated.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddCookie(w.Result().Cookies()[0])
+	sess, err = rotated.Instance(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Values["test"] != "foo123" {
+		t.Fatalf("\nactual: %v\nexpected: %v", sess.Values["test"], "foo123")
+	}
+
+	// The old cookie is no longer accepted
+	r, err = http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddCookie(cookie)
+	if _, err := rotated.Instance(r); err == nil {
+		t.Fatal("Decode error was expected.")
+	}
+}
+
+// TestKeyPairBad ensures session fails with a bad key in the list.
+func TestKeyPairBad(t *testing.T) {
+	s := session.Info{
+		Keys: []session.KeyPair{
+			{AuthKey: "PzCh6FNAB7/jhmlUQ0+25sjJ+WgcJeKR2bAOtnh9UnfVN+WJSBvY/YC80Rs+rbMtwfmSP4FUSxKPtpYKzKFqFA=="},
+			{AuthKey: "bad auth key"},
+		},
+		Name: "sess",
+	}
+
+	if err := s.SetupConfig(); err == nil {
+		t.Fatal("AuthKey error was expected.")
+	}
+}
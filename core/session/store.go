@@ -0,0 +1,244 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// defaultExpiry is how long a session with a MaxAge of 0 is kept on the
+// server. The cookie is removed when the browser closes.
+const defaultExpiry = 24 * time.Hour
+
+// idBytes is the number of random bytes in a session ID.
+const idBytes = 32
+
+// maxUserAgent is the longest user agent that is stored.
+const maxUserAgent = 255
+
+var (
+	// ErrNotFound is when the session does not exist or has expired.
+	ErrNotFound = errors.New("Session could not be found.")
+)
+
+// Record is a session stored on the server.
+type Record struct {
+	ID        string    `gorm:"primary_key"`
+	UserID    string    // Value of id in the session, empty when not logged in
+	Data      string    // Values encoded with the session keys
+	UserAgent string    // Browser that last saved the session
+	IP        string    // Address that last saved the session
+	CreatedAt time.Time // Time the session was first saved
+	UpdatedAt time.Time // Time the session was last saved
+	ExpiresAt time.Time // Time the session can be removed
+}
+
+// TableName for the session table.
+func (Record) TableName() string {
+	return "session"
+}
+
+// Backend keeps the sessions on the server.
+type Backend interface {
+	// Load returns the session or ErrNotFound.
+	Load(id string) (Record, error)
+	// Save creates or replaces the session but keeps the CreatedAt time of
+	// an existing session.
+	Save(r Record) error
+	// Delete removes the session.
+	Delete(id string) error
+	// DeleteExpired removes the sessions that expired before the time.
+	DeleteExpired(now time.Time) error
+	// ByUserID returns the sessions of the user.
+	ByUserID(userID string) ([]Record, error)
+}
+
+// ServerStore is a sessions.Store that keeps the values on the server. The
+// cookie only contains the signed session ID.
+type ServerStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+	Backend Backend
+}
+
+// NewServerStore returns a store for the backend. The key pairs are used like
+// sessions.NewCookieStore.
+func NewServerStore(backend Backend, keyPairs ...[]byte) *ServerStore {
+	codecs := securecookie.CodecsFromPairs(keyPairs...)
+
+	// The values are not sent in the cookie so they can be larger
+	for _, c := range codecs {
+		if sc, ok := c.(*securecookie.SecureCookie); ok {
+			sc.MaxLength(0)
+		}
+	}
+
+	return &ServerStore{
+		Codecs: codecs,
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+		Backend: backend,
+	}
+}
+
+// Get returns the session for the request. It is only loaded once for each
+// request.
+func (s *ServerStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns the session from the cookie or a new session when there is no
+// valid cookie.
+func (s *ServerStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	sess := sessions.NewSession(s, name)
+	opts := *s.Options
+	sess.Options = &opts
+	sess.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return sess, nil
+	}
+
+	if err := securecookie.DecodeMulti(name, c.Value, &sess.ID, s.Codecs...); err != nil {
+		return sess, err
+	}
+
+	rec, err := s.Backend.Load(sess.ID)
+	if err == ErrNotFound || (err == nil && rec.ExpiresAt.Before(time.Now())) {
+		// Start over with a new ID so an old ID cannot be reused
+		sess.ID = ""
+		return sess, nil
+	} else if err != nil {
+		return sess, err
+	}
+
+	if err := securecookie.DecodeMulti(name, rec.Data, &sess.Values, s.Codecs...); err != nil {
+		return sess, err
+	}
+
+	sess.IsNew = false
+	return sess, nil
+}
+
+// Save stores the session and sets the cookie. A MaxAge below 0 removes the
+// session.
+func (s *ServerStore) Save(r *http.Request, w http.ResponseWriter, sess *sessions.Session) error {
+	if sess.Options.MaxAge < 0 {
+		if len(sess.ID) > 0 {
+			if err := s.Backend.Delete(sess.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(sess.Name(), "", sess.Options))
+		return nil
+	}
+
+	if len(sess.ID) == 0 {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		sess.ID = id
+	}
+
+	data, err := securecookie.EncodeMulti(sess.Name(), sess.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+
+	expiry := defaultExpiry
+	if sess.Options.MaxAge > 0 {
+		expiry = time.Duration(sess.Options.MaxAge) * time.Second
+	}
+
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgent {
+		ua = ua[:maxUserAgent]
+	}
+
+	now := time.Now()
+	rec := Record{
+		ID:        sess.ID,
+		Data:      data,
+		UserAgent: ua,
+		IP:        remoteIP(r),
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(expiry),
+	}
+	if id, ok := sess.Values["id"]; ok && id != nil {
+		rec.UserID = fmt.Sprintf("%v", id)
+	}
+
+	if err := s.Backend.Save(rec); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(sess.Name(), sess.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, sessions.NewCookie(sess.Name(), encoded, sess.Options))
+	return nil
+}
+
+// Clean removes the expired sessions at the interval. Call the returned func
+// to stop.
+func (s *ServerStore) Clean(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				s.Backend.DeleteExpired(now)
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
+
+// newID returns a random session ID.
+func newID() (string, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(b), "="), nil
+}
+
+// remoteIP returns the address of the client without the port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,313 @@
+package session_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/core/session"
+	"github.com/pcieslar/goforge/lib/gorm"
+
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/sqlite"
+
+	"github.com/gorilla/sessions"
+)
+
+// serverStores returns a session config for each server store.
+func serverStores(t *testing.T) (map[string]*session.Info, func()) {
+	folder, err := ioutil.TempDir("", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open("sqlite3", filepath.Join(folder, "session.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&session.Record{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	list := map[string]*session.Info{
+		session.StoreMemory:   {Store: session.StoreMemory},
+		session.StoreFile:     {Store: session.StoreFile, Folder: filepath.Join(folder, "files")},
+		session.StoreDatabase: {Store: session.StoreDatabase},
+	}
+
+	for _, s := range list {
+		s.AuthKey = "PzCh6FNAB7/jhmlUQ0+25sjJ+WgcJeKR2bAOtnh9UnfVN+WJSBvY/YC80Rs+rbMtwfmSP4FUSxKPtpYKzKFqFA=="
+		s.EncryptKey = "3oTKCcKjDHMUlV+qur2Ve664SPpSuviyGQ/UqnroUD8="
+		s.Name = "sess"
+		s.Options = sessions.Options{
+			Path:     "/",
+			MaxAge:   28800,
+			HttpOnly: true,
+		}
+		s.SetDB(db)
+
+		if err := s.SetupConfig(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return list, func() {
+		db.Close()
+		os.RemoveAll(folder)
+	}
+}
+
+// login saves a session for the user and returns the cookie.
+func login(t *testing.T, s *session.Info, userID uint32, agent string) *http.Cookie {
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("User-Agent", agent)
+
+	sess, err := s.Instance(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.Values["id"] = userID
+	sess.Values["email"] = strings.Repeat("x", 5000)
+	if err := sess.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("\nactual: %v\nexpected: %v", len(cookies), 1)
+	}
+
+	return cookies[0]
+}
+
+// load returns the session for the cookie.
+func load(t *testing.T, s *session.Info, c *http.Cookie) *sessions.Session {
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddCookie(c)
+
+	sess, err := s.Instance(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sess
+}
+
+// TestServerStore ensures the values are kept on the server and can be
+// listed and destroyed for a user.
+func TestServerStore(t *testing.T) {
+	list, cleanup := serverStores(t)
+	defer cleanup()
+
+	for name, s := range list {
+		phone := login(t, s, 1, "phone")
+		laptop := login(t, s, 1, "laptop")
+		other := login(t, s, 2, "other")
+
+		// The cookie only holds the ID
+		if len(phone.Value) > 300 {
+			t.Errorf("%v: cookie is %v bytes", name, len(phone.Value))
+		}
+
+		sess := load(t, s, phone)
+		if sess.IsNew || sess.Values["id"] != uint32(1) {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, sess.Values["id"], 1)
+		}
+
+		records, err := s.Sessions("1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("%v: \nactual: %v\nexpected: %v", name, len(records), 2)
+		}
+
+		// Log out every device except the laptop
+		if err := s.DestroyUser("1", load(t, s, laptop).ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if sess := load(t, s, phone); !sess.IsNew || sess.Values["id"] != nil {
+			t.Errorf("%v: phone session should be destroyed", name)
+		}
+		if sess := load(t, s, laptop); sess.IsNew {
+			t.Errorf("%v: laptop session should be kept", name)
+		}
+		if sess := load(t, s, other); sess.IsNew {
+			t.Errorf("%v: session of the other user should be kept", name)
+		}
+	}
+}
+
+// TestServerStoreDelete ensures a MaxAge below 0 removes the session.
+func TestServerStoreDelete(t *testing.T) {
+	list, cleanup := serverStores(t)
+	defer cleanup()
+
+	for name, s := range list {
+		c := login(t, s, 1, "phone")
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.AddCookie(c)
+		sess, _ := s.Instance(r)
+		sess.Options.MaxAge = -1
+		if err := sess.Save(r, w); err != nil {
+			t.Fatal(err)
+		}
+
+		if records, _ := s.Sessions("1"); len(records) != 0 {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, len(records), 0)
+		}
+	}
+}
+
+// TestClean ensures the expired sessions are removed.
+func TestClean(t *testing.T) {
+	backend := session.NewMemoryBackend()
+	store := session.NewServerStore(backend, []byte("key"))
+
+	now := time.Now()
+	backend.Save(session.Record{ID: "old", UserID: "1", ExpiresAt: now.Add(-time.Minute)})
+	backend.Save(session.Record{ID: "new", UserID: "1", ExpiresAt: now.Add(time.Minute)})
+
+	stop := store.Clean(10 * time.Millisecond)
+	defer stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := backend.Load("old"); err == session.ErrNotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired session was not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := backend.Load("new"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDatabaseSave ensures a new session saved by requests at the same time
+// is created once and keeps the time it was created.
+func TestDatabaseSave(t *testing.T) {
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "session.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.AutoMigrate(&session.Record{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	b := session.NewDatabaseBackend(db)
+
+	created := time.Now().Add(-time.Hour)
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			now := time.Now()
+			errs <- b.Save(session.Record{ID: "new", Data: strconv.Itoa(i), CreatedAt: created, UpdatedAt: now, ExpiresAt: now.Add(time.Hour)})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A later save keeps the creation time
+	now := time.Now()
+	if err := b.Save(session.Record{ID: "new", UserID: "1", Data: "last", CreatedAt: now, UpdatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := b.Load("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Data != "last" || r.UserID != "1" {
+		t.Errorf("\nactual: %v, %v\nexpected: %v, %v", r.Data, r.UserID, "last", "1")
+	}
+	if !r.CreatedAt.Equal(created) {
+		t.Errorf("\nactual: %v\nexpected: %v", r.CreatedAt, created)
+	}
+}
+
+// TestCookieStoreList ensures sessions in the cookie cannot be listed.
+func TestCookieStoreList(t *testing.T) {
+	s := session.Info{
+		AuthKey: "PzCh6FNAB7/jhmlUQ0+25sjJ+WgcJeKR2bAOtnh9UnfVN+WJSBvY/YC80Rs+rbMtwfmSP4FUSxKPtpYKzKFqFA==",
+		Name:    "sess",
+	}
+	s.SetupConfig()
+
+	if _, err := s.Sessions("1"); err != session.ErrCookieStore {
+		t.Fatalf("\nactual: %v\nexpected: %v", err, session.ErrCookieStore)
+	}
+
+	s.Store = "redis"
+	if err := s.SetupConfig(); err != session.ErrStore {
+		t.Fatalf("\nactual: %v\nexpected: %v", err, session.ErrStore)
+	}
+}
+
+// TestRegenerate ensures the session gets a new ID and the old ID no longer
+// loads the values.
+func TestRegenerate(t *testing.T) {
+	list, cleanup := serverStores(t)
+	defer cleanup()
+
+	for name, s := range list {
+		c := login(t, s, 1, "phone")
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.AddCookie(c)
+		sess, _ := s.Instance(r)
+		oldID := sess.ID
+
+		if err := s.Regenerate(sess); err != nil {
+			t.Fatal(err)
+		}
+		sess.Values["id"] = uint32(2)
+		if err := sess.Save(r, w); err != nil {
+			t.Fatal(err)
+		}
+
+		if sess.ID == oldID || len(sess.ID) == 0 {
+			t.Errorf("%v: session ID was not changed", name)
+		}
+
+		// The old cookie starts an empty session
+		if old := load(t, s, c); old.Values["id"] != nil {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, old.Values["id"], nil)
+		}
+
+		// The new cookie keeps the values
+		if cur := load(t, s, w.Result().Cookies()[0]); cur.Values["id"] != uint32(2) {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, cur.Values["id"], uint32(2))
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Package signature creates tamper-proof tokens for links sent outside the
+// site like the email verification link. The value is signed with HMAC and
+// expires after MaxAge so nothing needs to be stored on the server.
+package signature
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/gorilla/securecookie"
+)
+
+var (
+	// ErrNoKey is when the key is missing.
+	ErrNoKey = errors.New("Signature Key is missing.")
+
+	// ErrInvalid is when the token was changed, signed with another key or
+	// for another purpose, or has expired.
+	ErrInvalid = errors.New("Signature is invalid or has expired.")
+)
+
+// Info holds the details for signing the tokens.
+type Info struct {
+	Key    string `json:"Key"`    // Base64 encoded key to sign the tokens
+	MaxAge int    `json:"MaxAge"` // Seconds a token can be used, 0 for no expiry
+}
+
+// codec returns the codec for the key.
+func (i Info) codec() (*securecookie.SecureCookie, error) {
+	if len(i.Key) == 0 {
+		return nil, ErrNoKey
+	}
+
+	key, err := base64.StdEncoding.DecodeString(i.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	s := securecookie.New(key, nil)
+	s.MaxAge(i.MaxAge)
+	s.SetSerializer(securecookie.JSONEncoder{})
+
+	return s, nil
+}
+
+// Sign returns a URL safe token with the value. The name is the purpose of
+// the token so a token signed for one purpose cannot be used for another.
+func (i Info) Sign(name string, value interface{}) (string, error) {
+	s, err := i.codec()
+	if err != nil {
+		return "", err
+	}
+
+	return s.Encode(name, value)
+}
+
+// Verify decodes the token signed for the name into dst. Returns ErrInvalid
+// when the token cannot be trusted.
+func (i Info) Verify(name, token string, dst interface{}) error {
+	s, err := i.codec()
+	if err != nil {
+		return err
+	}
+
+	if err := s.Decode(name, token, dst); err != nil {
+		return ErrInvalid
+	}
+
+	return nil
+}
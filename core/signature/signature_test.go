@@ -0,0 +1,74 @@
+package signature_test
+
+import (
+	"testing"
+
+	"github.com/pcieslar/goforge/core/signature"
+)
+
+// value is signed in the tests.
+type value struct {
+	ID    uint32
+	Email string
+}
+
+// TestSign ensures a signed value is verified.
+func TestSign(t *testing.T) {
+	s := signature.Info{
+		Key:    "c2lnbmF0dXJlIHRlc3Qga2V5IGZvciB0aGUgZm9yZ2U=",
+		MaxAge: 60,
+	}
+
+	token, err := s.Sign("verify", value{1, "jdoe@domain.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received value
+	if err := s.Verify("verify", token, &received); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := value{1, "jdoe@domain.com"}
+	if received != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", received, expected)
+	}
+}
+
+// TestVerifyFail ensures a changed token, a token for another purpose, and a
+// token signed with another key are rejected.
+func TestVerifyFail(t *testing.T) {
+	s := signature.Info{
+		Key:    "c2lnbmF0dXJlIHRlc3Qga2V5IGZvciB0aGUgZm9yZ2U=",
+		MaxAge: 60,
+	}
+
+	token, err := s.Sign("verify", value{1, "jdoe@domain.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received value
+
+	if err := s.Verify("verify", token[:len(token)-2]+"AA", &received); err != signature.ErrInvalid {
+		t.Errorf("\nactual: %v\nexpected: %v", err, signature.ErrInvalid)
+	}
+
+	if err := s.Verify("reset", token, &received); err != signature.ErrInvalid {
+		t.Errorf("\nactual: %v\nexpected: %v", err, signature.ErrInvalid)
+	}
+
+	other := signature.Info{Key: "b3RoZXIga2V5IGZvciB0aGUgc2lnbmF0dXJlIHRlc3Q="}
+	if err := other.Verify("verify", token, &received); err != signature.ErrInvalid {
+		t.Errorf("\nactual: %v\nexpected: %v", err, signature.ErrInvalid)
+	}
+}
+
+// TestNoKey ensures a key is required.
+func TestNoKey(t *testing.T) {
+	s := signature.Info{}
+
+	if _, err := s.Sign("verify", value{}); err != signature.ErrNoKey {
+		t.Fatalf("\nactual: %v\nexpected: %v", err, signature.ErrNoKey)
+	}
+}
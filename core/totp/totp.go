@@ -0,0 +1,189 @@
+// Package totp generates and verifies the time-based one-time passwords of
+// RFC 6238 that are shown by authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// secretBytes is the number of random bytes in a secret. RFC 4226
+	// recommends 160 bits.
+	secretBytes = 20
+
+	// defaultDigits is the length of a code when Digits is not set.
+	defaultDigits = 6
+
+	// defaultPeriod is the seconds a code is valid when Period is not set.
+	defaultPeriod = 30
+
+	// maxDigits keeps the code within the 31 bits of the truncated HMAC.
+	maxDigits = 9
+)
+
+var (
+	// ErrSecret is when the secret is not valid base32.
+	ErrSecret = errors.New("Secret is not valid.")
+	// ErrInvalid is when the code does not match.
+	ErrInvalid = errors.New("Code is not valid.")
+)
+
+// encoding is the base32 alphabet used by authenticator apps without the
+// padding.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Info holds the details for the codes.
+type Info struct {
+	Issuer string `json:"Issuer"` // Name of the application shown in the authenticator app
+	Digits int    `json:"Digits"` // Length of a code, 6 if not set
+	Period int    `json:"Period"` // Seconds a code is valid, 30 if not set
+	Skew   int    `json:"Skew"`   // Periods before and after the current one that are accepted for clock drift
+}
+
+// digits returns the length of a code.
+func (i Info) digits() int {
+	if i.Digits <= 0 {
+		return defaultDigits
+	} else if i.Digits > maxDigits {
+		return maxDigits
+	}
+	return i.Digits
+}
+
+// period returns the seconds a code is valid.
+func (i Info) period() int64 {
+	if i.Period <= 0 {
+		return defaultPeriod
+	}
+	return int64(i.Period)
+}
+
+// Secret returns a new random secret encoded in base32.
+func Secret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(b), nil
+}
+
+// decode returns the key from the base32 secret. Spaces and lower case
+// letters are allowed so the secret can be typed in.
+func decode(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	secret = strings.TrimRight(secret, "=")
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrSecret
+	}
+
+	return key, nil
+}
+
+// Counter returns the number of periods since the Unix epoch at the time.
+func (i Info) Counter(now time.Time) int64 {
+	return now.Unix() / i.period()
+}
+
+// Code returns the code for the secret at the time.
+func (i Info) Code(secret string, now time.Time) (string, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hotp(key, uint64(i.Counter(now)), i.digits()), nil
+}
+
+// Validate returns the counter of the period that matches the code at the
+// time. Store the counter after a successful login and reject codes that are
+// not newer so a code cannot be used twice.
+func (i Info) Validate(secret, code string, now time.Time) (int64, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return 0, err
+	}
+
+	code = strings.Replace(code, " ", "", -1)
+	if len(code) != i.digits() {
+		return 0, ErrInvalid
+	}
+
+	current := i.Counter(now)
+	for skew := int64(-i.Skew); skew <= int64(i.Skew); skew++ {
+		counter := current + skew
+		if counter < 0 {
+			continue
+		}
+
+		expected := hotp(key, uint64(counter), i.digits())
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, nil
+		}
+	}
+
+	return 0, ErrInvalid
+}
+
+// URI returns the otpauth provisioning URI for the account that
+// authenticator apps read from a QR code. It is a template.URL so a link to
+// it is not replaced by html/template, which only allows a few schemes.
+func (i Info) URI(account, secret string) template.URL {
+	label := account
+	if len(i.Issuer) > 0 {
+		label = i.Issuer + ":" + account
+	}
+
+	v := url.Values{}
+	v.Set("secret", secret)
+	if len(i.Issuer) > 0 {
+		v.Set("issuer", i.Issuer)
+	}
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", strconv.Itoa(i.digits()))
+	v.Set("period", strconv.FormatInt(i.period(), 10))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: v.Encode(),
+	}
+
+	return template.URL(u.String())
+}
+
+// hotp returns the HMAC-based one-time password of RFC 4226 for the counter.
+func hotp(key []byte, counter uint64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for n := 0; n < digits; n++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
@@ -0,0 +1,112 @@
+package totp_test
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/core/totp"
+)
+
+// secret is the key "12345678901234567890" from RFC 6238 in base32.
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCode ensures the codes match the SHA-1 test vectors of RFC 6238.
+func TestCode(t *testing.T) {
+	o := totp.Info{Digits: 8}
+
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for unix, expected := range vectors {
+		received, err := o.Code(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if received != expected {
+			t.Errorf("\nactual: %v\nexpected: %v", received, expected)
+		}
+	}
+}
+
+// TestValidate ensures a code is accepted within the skew and rejected
+// outside of it.
+func TestValidate(t *testing.T) {
+	o := totp.Info{Digits: 6, Period: 30, Skew: 1}
+	now := time.Unix(1111111111, 0)
+
+	code, err := o.Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter, err := o.Validate(secret, code, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter != o.Counter(now) {
+		t.Errorf("\nactual: %v\nexpected: %v", counter, o.Counter(now))
+	}
+
+	// A code from the previous period is allowed for clock drift
+	if _, err := o.Validate(secret, code, now.Add(30*time.Second)); err != nil {
+		t.Error(err)
+	}
+
+	// A code from two periods ago is not
+	if _, err := o.Validate(secret, code, now.Add(60*time.Second)); err != totp.ErrInvalid {
+		t.Errorf("\nactual: %v\nexpected: %v", err, totp.ErrInvalid)
+	}
+
+	// Wrong length
+	if _, err := o.Validate(secret, code[:5], now); err != totp.ErrInvalid {
+		t.Errorf("\nactual: %v\nexpected: %v", err, totp.ErrInvalid)
+	}
+}
+
+// TestSecret ensures a new secret can be used to create codes.
+func TestSecret(t *testing.T) {
+	o := totp.Info{}
+
+	s, err := totp.Secret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 32 {
+		t.Errorf("\nactual: %v\nexpected: %v", len(s), 32)
+	}
+
+	now := time.Now()
+	code, err := o.Code(strings.ToLower(s), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 6 {
+		t.Errorf("\nactual: %v\nexpected: %v", len(code), 6)
+	}
+	if _, err := o.Validate(s, code, now); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := o.Code("not base32!", now); err != totp.ErrSecret {
+		t.Errorf("\nactual: %v\nexpected: %v", err, totp.ErrSecret)
+	}
+}
+
+// TestURI ensures the provisioning URI has the label and parameters.
+func TestURI(t *testing.T) {
+	o := totp.Info{Issuer: "Blueprint"}
+
+	received := o.URI("jdoe@domain.com", secret)
+	expected := template.URL("otpauth://totp/Blueprint:jdoe@domain.com?algorithm=SHA1&digits=6&issuer=Blueprint&period=30&secret=" + secret)
+	if received != expected {
+		t.Errorf("\nactual: %v\nexpected: %v", received, expected)
+	}
+}
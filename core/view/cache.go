@@ -0,0 +1,69 @@
+package view
+
+import (
+	"html/template"
+	"sync"
+)
+
+// cache holds the parsed template collections. It is shared by the copies of
+// the view that are made for each request.
+type cache struct {
+	collections map[string]*template.Template
+	files       map[string][]string // Paths of the files parsed for each key
+	mutex       sync.RWMutex
+}
+
+// newCache returns an empty cache.
+func newCache() *cache {
+	return &cache{
+		collections: make(map[string]*template.Template),
+		files:       make(map[string][]string),
+	}
+}
+
+// get returns the template collection for the key.
+func (c *cache) get(key string) (*template.Template, bool) {
+	c.mutex.RLock()
+	t, ok := c.collections[key]
+	c.mutex.RUnlock()
+
+	return t, ok
+}
+
+// set stores the template collection and the paths of its files.
+func (c *cache) set(key string, t *template.Template, paths []string) {
+	c.mutex.Lock()
+	c.collections[key] = t
+	c.files[key] = paths
+	c.mutex.Unlock()
+}
+
+// paths returns the paths of the files parsed for the key.
+func (c *cache) paths(key string) []string {
+	c.mutex.RLock()
+	paths := c.files[key]
+	c.mutex.RUnlock()
+
+	return paths
+}
+
+// invalidate removes every template collection that was parsed from the file
+// and returns the number removed.
+func (c *cache) invalidate(path string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	count := 0
+	for key, paths := range c.files {
+		for _, p := range paths {
+			if p == path {
+				delete(c.collections, key)
+				delete(c.files, key)
+				count++
+				break
+			}
+		}
+	}
+
+	return count
+}
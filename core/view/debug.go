@@ -0,0 +1,89 @@
+package view
+
+import (
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// errorContext is the number of lines shown before and after the line with
+// the error.
+const errorContext = 5
+
+// errorLocation matches the template name and line number of an error.
+var errorLocation = regexp.MustCompile(`template: ([^:]+):(\d+):`)
+
+// errorPage shows the template error with the source around the line.
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #333; }
+h1 { color: #a94442; }
+.message { background: #f2dede; border: 1px solid #ebccd1; padding: 1em; white-space: pre-wrap; }
+pre { background: #f5f5f5; border: 1px solid #ccc; padding: 1em 0; overflow: auto; }
+pre span { display: block; padding: 0 1em; }
+pre span.current { background: #fcf8e3; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="message">{{.Message}}</div>
+{{if .File}}<h2>{{.File}}{{if .Line}}:{{.Line}}{{end}}</h2>
+<pre>{{range .Lines}}<span{{if .Current}} class="current"{{end}}>{{printf "%4d" .Number}}  {{.Text}}</span>{{end}}</pre>{{end}}
+</body>
+</html>`))
+
+// sourceLine is a line of the template with the error.
+type sourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+// writeError writes a page with the error and the template source around the
+// line with the error. The paths are the files that were parsed.
+func (v *Info) writeError(w http.ResponseWriter, title string, err error, paths []string) {
+	data := struct {
+		Title   string
+		Message string
+		File    string
+		Line    int
+		Lines   []sourceLine
+	}{
+		Title:   title,
+		Message: err.Error(),
+	}
+
+	if m := errorLocation.FindStringSubmatch(err.Error()); m != nil {
+		data.Line, _ = strconv.Atoi(m[2])
+
+		// The templates are named by the base name of the file
+		for _, p := range paths {
+			if filepath.Base(p) == m[1] {
+				data.File = p
+				break
+			}
+		}
+
+		b, err := v.readFile(data.File)
+		if len(data.File) > 0 && err == nil {
+			lines := strings.Split(string(b), "\n")
+			for i := data.Line - errorContext; i <= data.Line+errorContext; i++ {
+				if i < 1 || i > len(lines) {
+					continue
+				}
+				data.Lines = append(data.Lines, sourceLine{i, lines[i-1], i == data.Line})
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	errorPage.Execute(w, data)
+}
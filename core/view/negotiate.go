@@ -0,0 +1,162 @@
+package view
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Response formats.
+const (
+	FormatHTML = "html"
+	FormatJSON = "json"
+	FormatXML  = "xml"
+)
+
+// mediaTypes maps the Accept header media types to the response formats.
+var mediaTypes = map[string]string{
+	"text/html":             FormatHTML,
+	"application/xhtml+xml": FormatHTML,
+	"application/json":      FormatJSON,
+	"application/xml":       FormatXML,
+	"text/xml":              FormatXML,
+}
+
+// Negotiate returns the response format the request asks for. The format
+// query parameter takes precedence over the Accept header. HTML is returned
+// when neither asks for a supported format.
+func Negotiate(r *http.Request) string {
+	switch f := r.URL.Query().Get("format"); f {
+	case FormatHTML, FormatJSON, FormatXML:
+		return f
+	}
+
+	format, quality := FormatHTML, 0.0
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		f, ok := mediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+
+		// The first media type wins a tie
+		if q > quality {
+			format, quality = f, q
+		}
+	}
+
+	return format
+}
+
+// Status sets the status code written before the response body.
+func (v *Info) Status(code int) *Info {
+	v.status = code
+	return v
+}
+
+// Payload sets the data rendered for JSON and XML instead of Vars.
+func (v *Info) Payload(data interface{}) *Info {
+	v.payload = data
+	return v
+}
+
+// Respond renders the view as HTML, JSON, or XML based on the request. JSON
+// and XML contain the payload or, if there is none, the Vars set by the
+// controller. The modifiers only run for HTML.
+func (v *Info) Respond(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Add("Vary", "Accept")
+
+	var data interface{} = v.payload
+
+	switch Negotiate(r) {
+	case FormatJSON:
+		if data == nil {
+			data = v.Vars
+		}
+		return JSON(w, v.status, data)
+	case FormatXML:
+		if data == nil {
+			data = xmlMap(v.Vars)
+		}
+		return XML(w, v.status, data)
+	}
+
+	return v.Render(w, r)
+}
+
+// JSON writes the data as JSON with the status code. A status code of 0
+// writes 200.
+func JSON(w http.ResponseWriter, status int, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "JSON Encode Error: "+err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return write(w, status, "application/json; charset=utf-8", b)
+}
+
+// XML writes the data as XML with the status code. A status code of 0 writes
+// 200.
+func XML(w http.ResponseWriter, status int, data interface{}) error {
+	b, err := xml.Marshal(data)
+	if err != nil {
+		http.Error(w, "XML Encode Error: "+err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return write(w, status, "application/xml; charset=utf-8", append([]byte(xml.Header), b...))
+}
+
+// write sets the content type and status code and then writes the body.
+func write(w http.ResponseWriter, status int, contentType string, b []byte) error {
+	w.Header().Set("Content-Type", contentType)
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+
+	_, err := w.Write(b)
+	return err
+}
+
+// xmlMap encodes the Vars as a vars element with a child element for each
+// key in alphabetical order.
+type xmlMap map[string]interface{}
+
+// MarshalXML encodes the map.
+func (m xmlMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	start = xml.StartElement{Name: xml.Name{Local: "vars"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := e.EncodeElement(m[k], xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
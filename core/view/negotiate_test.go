@@ -0,0 +1,111 @@
+package view_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pcieslar/goforge/core/view"
+)
+
+// TestNegotiate ensures the format is chosen from the request.
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		url      string
+		accept   string
+		expected string
+	}{
+		{"/", "", view.FormatHTML},
+		{"/", "*/*", view.FormatHTML},
+		{"/", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", view.FormatHTML},
+		{"/", "application/json", view.FormatJSON},
+		{"/", "text/xml", view.FormatXML},
+		{"/", "text/html;q=0.5, application/json", view.FormatJSON},
+		{"/", "application/json;q=0", view.FormatHTML},
+		{"/?format=xml", "application/json", view.FormatXML},
+		{"/?format=yaml", "application/json", view.FormatJSON},
+	}
+
+	for _, test := range tests {
+		r, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Accept", test.accept)
+
+		if actual := view.Negotiate(r); actual != test.expected {
+			t.Errorf("%v %v\nactual: %v\nexpected: %v", test.url, test.accept, actual, test.expected)
+		}
+	}
+}
+
+// TestRespond ensures the view is rendered in the negotiated format.
+func TestRespond(t *testing.T) {
+	viewInfo := &view.Info{
+		BaseURI:   "/",
+		Extension: "tmpl",
+		Folder:    "testdata/view",
+		Caching:   false,
+	}
+
+	// Set up the view
+	viewInfo.SetTemplates("basetest", []string{})
+
+	tests := []struct {
+		format      string
+		contentType string
+		expected    string
+	}{
+		{"html", "text/html; charset=utf-8", `<!DOCTYPE html><div class="container">Bar</div></html>`},
+		{"json", "application/json; charset=utf-8", `{"count":2,"name":"Bar"}`},
+		{"xml", "application/xml; charset=utf-8", `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<vars><count>2</count><name>Bar</name></vars>`},
+	}
+
+	for _, test := range tests {
+		// Simulate a request
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/?format="+test.format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Render the view
+		v := viewInfo.New("foo/index").Status(http.StatusAccepted)
+		v.Vars["name"] = "Bar"
+		v.Vars["count"] = 2
+		v.Respond(w, r)
+
+		if w.Code != http.StatusAccepted {
+			t.Errorf("%v\nactual: %v\nexpected: %v", test.format, w.Code, http.StatusAccepted)
+		}
+
+		if actual := w.Header().Get("Content-Type"); actual != test.contentType {
+			t.Errorf("%v\nactual: %v\nexpected: %v", test.format, actual, test.contentType)
+		}
+
+		if actual := w.Body.String(); actual != test.expected {
+			t.Errorf("%v\nactual: %v\nexpected: %v", test.format, actual, test.expected)
+		}
+	}
+}
+
+// TestRespondPayload ensures the payload is rendered instead of the Vars.
+func TestRespondPayload(t *testing.T) {
+	viewInfo := &view.Info{}
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Accept", "application/json")
+
+	v := viewInfo.New("foo/index")
+	v.Vars["name"] = "Bar"
+	v.Payload([]string{"a", "b"})
+	v.Respond(w, r)
+
+	if actual, expected := w.Body.String(), `["a","b"]`; actual != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", actual, expected)
+	}
+}
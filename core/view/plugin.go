@@ -24,16 +24,31 @@ func (c *Info) modify() []ModifyFunc {
 	return list
 }
 
+// errorFunc safely reads the error handler.
+func (c *Info) errorFunc() ErrorFunc {
+	c.errorMutex.RLock()
+	fn := c.errorHandler
+	c.errorMutex.RUnlock()
+
+	return fn
+}
+
 // SetTemplates will set the root and child templates.
 func (c *Info) SetTemplates(rootTemp string, childTemps []string) {
-	c.mutex.Lock()
-	c.templateCollection = make(map[string]*template.Template)
-	c.mutex.Unlock()
+	c.cache = newCache()
 
 	c.rootTemplate = rootTemp
 	c.childTemplates = childTemps
 }
 
+// SetPartials will set the partials that are added to the pages in each
+// folder.
+func (c *Info) SetPartials(partials map[string][]string) {
+	c.cache = newCache()
+
+	c.partials = partials
+}
+
 // ModifyFunc can modify the view before rendering.
 type ModifyFunc func(http.ResponseWriter, *http.Request, *Info)
 
@@ -45,6 +60,18 @@ func (c *Info) SetModifiers(fn ...ModifyFunc) {
 	c.modifyMutex.Unlock()
 }
 
+// ErrorFunc writes the response when a template cannot be rendered.
+type ErrorFunc func(http.ResponseWriter, *http.Request, error)
+
+// SetErrorHandler will set the func that is called when a template cannot be
+// rendered. Nothing has been written to the response when it is called so it
+// can write any status code. A plain error is written when it is not set.
+func (c *Info) SetErrorHandler(fn ErrorFunc) {
+	c.errorMutex.Lock()
+	c.errorHandler = fn
+	c.errorMutex.Unlock()
+}
+
 // SetFuncMaps will combine all template.FuncMaps into one map and then set the
 // them for each template.
 // If a func already exists, it is rewritten without a warning.
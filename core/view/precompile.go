@@ -0,0 +1,38 @@
+package view
+
+import (
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// ParseAll parses every template in the folder so syntax errors and missing
+// functions are found on start up instead of when a page is requested. The
+// FuncMaps must be set first.
+func (v *Info) ParseAll() error {
+	fsys, root, prefix := v.FS, v.Folder, ""
+	if fsys == nil {
+		fsys, root, prefix = os.DirFS(v.Folder), ".", v.Folder
+	}
+
+	pc := v.extend()
+
+	return fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || path.Ext(name) != "."+v.Extension {
+			return nil
+		}
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = template.New(path.Join(prefix, name)).Funcs(pc).Parse(string(b))
+		return err
+	})
+}
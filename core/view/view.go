@@ -1,42 +1,86 @@
 // Package view provides thread-safe caching of HTML templates.
+//
+// A layout can extend another layout by starting with a comment that names
+// the parent:
+//
+//	{{/* extends "base" */}}
+//
+// The templates are parsed from the outermost layout to the page so a
+// {{define}} in a later file replaces a {{block}} or {{define}} of the same
+// name in an earlier one.
 package view
 
 import (
+	"context"
+	"errors"
 	"html/template"
+	"io"
+	"io/fs"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 )
 
+// maxLayoutDepth is the most layouts that can extend each other.
+const maxLayoutDepth = 10
+
+// contextKey is the type of the request context keys.
+type contextKey int
+
+// handlingError is set on the request passed to the error handler.
+const handlingError contextKey = iota
+
+var (
+	// ErrLayoutDepth is when layouts extend each other in a loop.
+	ErrLayoutDepth = errors.New("Layouts are nested too deeply or extend each other in a loop.")
+
+	// extendsDirective matches the comment that names the parent layout.
+	extendsDirective = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*extends\s+"([^"]+)"\s*\*/\s*-?\}\}`)
+)
+
 // Template holds the root and children templates.
 type Template struct {
-	Root     string   `json:"Root"`
-	Children []string `json:"Children"`
+	Root     string              `json:"Root"`
+	Children []string            `json:"Children"`
+	Partials map[string][]string `json:"Partials"` // Partials by page folder like "note"
 }
 
 // Info holds view attributes.
 type Info struct {
-	BaseURI   string
-	Extension string
-	Folder    string
-	Caching   bool
+	BaseURI    string
+	Extension  string
+	Folder     string
+	Caching    bool
+	Precompile bool // Parse every template on start up
+	Reload     bool // Parse the changed templates again and show detailed errors for development
+
+	// FS contains the templates instead of the disk when it is set like
+	// an embed.FS. The Folder is the path in the FS.
+	FS fs.FS `json:"-"`
 
 	Vars      map[string]interface{}
 	base      string
 	templates []string
+	status    int
+	payload   interface{}
 
 	childTemplates []string
 	rootTemplate   string
+	partials       map[string][]string
 
-	extendList  template.FuncMap
-	modifyList  []ModifyFunc
-	extendMutex sync.RWMutex
-	modifyMutex sync.RWMutex
+	extendList   template.FuncMap
+	modifyList   []ModifyFunc
+	errorHandler ErrorFunc
+	extendMutex  sync.RWMutex
+	modifyMutex  sync.RWMutex
+	errorMutex   sync.RWMutex
 
-	templateCollection map[string]*template.Template
-	mutex              sync.RWMutex
+	cache *cache
 }
 
 // *****************************************************************************
@@ -46,14 +90,17 @@ type Info struct {
 // New accepts multiple templates and then returns a new view.
 func (v *Info) New(templateList ...string) *Info {
 	v.Vars = make(map[string]interface{})
-	v.templates = append(v.templates, templateList...)
+	v.templates = templateList
 	v.base = v.rootTemplate
+	v.status = 0
+	v.payload = nil
 
 	return v
 }
 
 // Base sets the new base template instead of reading from
-// Template.Root of the config file.
+// Template.Root of the config file. The base can be a layout that extends
+// another layout.
 func (v *Info) Base(base string) *Info {
 	// Set the new base template
 	v.base = base
@@ -65,70 +112,203 @@ func (v *Info) Base(base string) *Info {
 // Render parses one or more templates and outputs to the screen.
 // Also returns an error if anything is wrong.
 func (v *Info) Render(w http.ResponseWriter, r *http.Request) error {
-	// Add the base template
-	v.templates = append([]string{v.base}, v.templates...)
+	tc, key, perr := v.lookup()
+	if perr != nil {
+		v.fail(w, r, perr.title, perr.err, perr.paths)
+		return perr.err
+	}
 
-	// Add the child templates
-	v.templates = append(v.templates, v.childTemplates...)
+	// Get the modify list
+	sc := v.modify()
 
-	// Set the base template
-	baseTemplate := v.templates[0]
+	// Loop through and call each one
+	for _, fn := range sc {
+		fn(w, r, v)
+	}
+
+	// Render into a buffer so nothing is sent if the template fails
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	err := tc.Funcs(v.extend()).ExecuteTemplate(buf, tc.Name(), v.Vars)
+	if err != nil {
+		v.fail(w, r, "Template File Error", err, v.cache.paths(key))
+		return err
+	}
 
-	// Set the key name for caching
-	key := strings.Join(v.templates, ":")
+	// Write the status code set on the view
+	if len(w.Header().Get("Content-Type")) == 0 {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	if v.status != 0 {
+		w.WriteHeader(v.status)
+	}
+
+	// Display the content to the screen
+	_, err = buf.WriteTo(w)
+
+	return err
+}
+
+// Execute parses the templates like Render and writes the output to w
+// without calling the modify funcs, like for the body of an email. The Vars
+// must contain everything the templates use.
+func (v *Info) Execute(w io.Writer) error {
+	tc, _, perr := v.lookup()
+	if perr != nil {
+		return perr
+	}
+
+	return tc.Funcs(v.extend()).ExecuteTemplate(w, tc.Name(), v.Vars)
+}
+
+// parseError holds the details for the error page when the templates cannot
+// be parsed.
+type parseError struct {
+	title string
+	err   error
+	paths []string
+}
+
+// Error returns the title and the error.
+func (e *parseError) Error() string {
+	return e.title + ": " + e.err.Error()
+}
+
+// lookup returns the template collection for the view from the cache or
+// parses it. The key of the collection in the cache is returned too.
+func (v *Info) lookup() (*template.Template, string, *parseError) {
+	// The layouts, partials, and pages in the order they are parsed
+	names := []string{v.base}
+	names = append(names, v.childTemplates...)
+	names = append(names, v.pagePartials()...)
+	names = append(names, v.templates...)
+
+	// Set the key name for caching. The layouts a base extends are read from
+	// the files so the base name is enough to identify them.
+	key := strings.Join(names, ":")
 
 	// Get the template collection from cache
-	v.mutex.RLock()
-	tc, ok := v.templateCollection[key]
-	v.mutex.RUnlock()
-
-	// Get the extend list
-	pc := v.extend()
-
-	// If the template collection is not cached or caching is disabled
-	if !ok || !v.Caching {
-		// Loop through each template and test the full path
-		for i, name := range v.templates {
-			// Get the absolute path of the root template
-			path, err := filepath.Abs(v.Folder + string(os.PathSeparator) + name + "." + v.Extension)
-			if err != nil {
-				http.Error(w, "Template Path Error: "+err.Error(), http.StatusInternalServerError)
-				return err
-			}
-			// Store the full template path
-			v.templates[i] = path
-		}
+	tc, ok := v.cache.get(key)
+	if ok && v.Caching {
+		return tc, key, nil
+	}
 
-		// Determine if there is an error in the template syntax
-		templates, err := template.New(key).Funcs(pc).ParseFiles(v.templates...)
+	// Add the layouts the base extends
+	layouts, err := v.layouts(v.base)
+	if err != nil {
+		return nil, key, &parseError{"Template Layout Error", err, nil}
+	}
+	names = append(layouts, names[1:]...)
+
+	// Loop through each template and get the full path
+	paths := make([]string, len(names))
+	for i, name := range names {
+		path, err := v.path(name)
 		if err != nil {
-			http.Error(w, "Template Parse Error: "+err.Error(), http.StatusInternalServerError)
-			return err
+			return nil, key, &parseError{"Template Path Error", err, nil}
 		}
+		paths[i] = path
+	}
 
-		// Cache the template collection
-		v.mutex.Lock()
-		v.templateCollection[key] = templates
-		v.mutex.Unlock()
+	// Determine if there is an error in the template syntax
+	tc, err = v.parse(v.extend(), paths)
+	if err != nil {
+		return nil, key, &parseError{"Template Parse Error", err, paths}
+	}
+
+	// Cache the template collection
+	v.cache.set(key, tc, paths)
 
-		// Save the template collection
-		tc = templates
+	return tc, key, nil
+}
+
+// fail writes the error. During development the detailed error page is shown.
+// Otherwise the error handler is called unless the error happened while
+// rendering the page of the error handler.
+func (v *Info) fail(w http.ResponseWriter, r *http.Request, title string, err error, paths []string) {
+	if v.Reload {
+		v.writeError(w, title, err, paths)
+		return
 	}
 
-	// Get the modify list
-	sc := v.modify()
+	if fn := v.errorFunc(); fn != nil && r.Context().Value(handlingError) == nil {
+		fn(w, r.WithContext(context.WithValue(r.Context(), handlingError, true)), err)
+		return
+	}
 
-	// Loop through and call each one
-	for _, fn := range sc {
-		fn(w, r, v)
+	http.Error(w, title+": "+err.Error(), http.StatusInternalServerError)
+}
+
+// path returns the absolute path of the template or the path in the FS.
+func (v *Info) path(name string) (string, error) {
+	if v.FS != nil {
+		return path.Join(v.Folder, name+"."+v.Extension), nil
 	}
 
-	// Display the content to the screen
-	err := tc.Funcs(pc).ExecuteTemplate(w, baseTemplate+"."+v.Extension, v.Vars)
+	return filepath.Abs(v.Folder + string(os.PathSeparator) + name + "." + v.Extension)
+}
 
-	if err != nil {
-		http.Error(w, "Template File Error: "+err.Error(), http.StatusInternalServerError)
+// readFile returns the contents of the template at the path.
+func (v *Info) readFile(name string) ([]byte, error) {
+	if v.FS != nil {
+		return fs.ReadFile(v.FS, name)
 	}
 
-	return err
+	return ioutil.ReadFile(name)
+}
+
+// parse returns the template collection for the paths. The collection is
+// named after the outermost layout so it is the one executed.
+func (v *Info) parse(pc template.FuncMap, paths []string) (*template.Template, error) {
+	t := template.New(filepath.Base(paths[0])).Funcs(pc)
+
+	if v.FS != nil {
+		return t.ParseFS(v.FS, paths...)
+	}
+
+	return t.ParseFiles(paths...)
+}
+
+// layouts returns the layout and every layout it extends, starting with the
+// outermost one.
+func (v *Info) layouts(name string) ([]string, error) {
+	list := []string{name}
+
+	for len(list) <= maxLayoutDepth {
+		path, err := v.path(list[0])
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := v.readFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		m := extendsDirective.FindSubmatch(b)
+		if m == nil {
+			return list, nil
+		}
+
+		list = append([]string{string(m[1])}, list...)
+	}
+
+	return nil, ErrLayoutDepth
+}
+
+// pagePartials returns the partials for the folder of the first page.
+func (v *Info) pagePartials() []string {
+	if len(v.templates) == 0 || len(v.partials) == 0 {
+		return nil
+	}
+
+	folder := v.templates[0]
+	if i := strings.LastIndex(folder, "/"); i >= 0 {
+		folder = folder[:i]
+	} else {
+		folder = ""
+	}
+
+	return v.partials[folder]
 }
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/pcieslar/goforge/core/view"
 )
@@ -123,6 +124,42 @@ func TestModifier(t *testing.T) {
 	}
 }
 
+// TestExecute ensures the view is written without the modifiers.
+func TestExecute(t *testing.T) {
+	viewInfo := &view.Info{
+		BaseURI:   "/",
+		Extension: "tmpl",
+		Folder:    "testdata/view",
+		Caching:   false,
+	}
+
+	// Set up the view
+	viewInfo.SetTemplates("basetest", []string{})
+	viewInfo.SetModifiers(
+		Modify,
+	)
+
+	// Write the view
+	var buf strings.Builder
+	v := viewInfo.New("foo/modifytest")
+	v.Vars["FOO"] = "BAZ"
+	if err := v.Execute(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	received := buf.String()
+	expected := `<!DOCTYPE html><div class="container"><span>BAZ</span></div></html>`
+
+	if received != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", received, expected)
+	}
+
+	// A missing template is an error
+	if err := viewInfo.New("foo/missing").Execute(&buf); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
 // TestModifierMissing ensures the page lods when the modifier is missing.
 func TestModifierMissing(t *testing.T) {
 	viewInfo := &view.Info{
@@ -325,3 +362,239 @@ func TestMissingBaseTemplate(t *testing.T) {
 		t.Fatalf("\nactual: %v\nexpected: %v", received, expected)
 	}
 }
+
+// render returns the body of the page rendered with the layout.
+func render(t *testing.T, layout string, page string, partials map[string][]string) string {
+	viewInfo := &view.Info{
+		BaseURI:   "/",
+		Extension: "tmpl",
+		Folder:    "testdata/view",
+		Caching:   false,
+	}
+
+	// Set up the view
+	viewInfo.SetTemplates("basetest", []string{})
+	viewInfo.SetPartials(partials)
+
+	// Simulate a request
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Render the view
+	viewInfo.New(page).Base(layout).Render(w, r)
+
+	return w.Body.String()
+}
+
+// TestNestedLayout ensures the blocks of layouts that extend each other are
+// replaced by the later definitions.
+func TestNestedLayout(t *testing.T) {
+	tests := []struct {
+		layout   string
+		page     string
+		expected string
+	}{
+		{"layout/admin", "foo/admin", `<!DOCTYPE html><div class="container"><main>Admin</main></div></html>`},
+		{"layout/admin", "foo/index", `<!DOCTYPE html><div class="container">Bar</div></html>`},
+		{"layout/admin", "foo/funcmaptest-missing", `foo/funcmaptest-missing.tmpl: no such file or directory`},
+		{"layout/super", "foo/admin", `<!DOCTYPE html><div class="container"><main>Admin</main></div></html>`},
+		{"layout/super", "foo/inner", `<!DOCTYPE html><div class="container"><main>Super Inner</main></div></html>`},
+	}
+
+	for _, tt := range tests {
+		received := render(t, tt.layout, tt.page, nil)
+		if !strings.Contains(received, tt.expected) {
+			t.Errorf("\nactual: %v\nexpected: %v", received, tt.expected)
+		}
+	}
+}
+
+// TestBlockDefault ensures the block of a layout is used when no page
+// defines it.
+func TestBlockDefault(t *testing.T) {
+	received := render(t, "layout/admin", "foo/inner", nil)
+	expected := `<!DOCTYPE html><div class="container"><main>Default</main></div></html>`
+
+	if received != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", received, expected)
+	}
+}
+
+// TestLayoutLoop ensures there is an error when a layout extends itself.
+func TestLayoutLoop(t *testing.T) {
+	received := render(t, "layout/loop", "foo/index", nil)
+	expected := view.ErrLayoutDepth.Error()
+
+	if !strings.Contains(received, expected) {
+		t.Fatalf("\nactual: %v\nexpected: %v", received, expected)
+	}
+}
+
+// TestPartials ensures only the partials for the folder of the page are
+// added.
+func TestPartials(t *testing.T) {
+	partials := map[string][]string{
+		"foo": {"foo/partial/greeting"},
+		"bar": {"foobar"},
+	}
+
+	received := render(t, "basetest", "foo/partialtest", partials)
+	expected := `<!DOCTYPE html><div class="container">Hello</div></html>`
+
+	if received != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", received, expected)
+	}
+
+	received = render(t, "basetest", "foo/partialtest", nil)
+	expected = `no such template "greeting"`
+
+	if !strings.Contains(received, expected) {
+		t.Fatalf("\nactual: %v\nexpected: %v", received, expected)
+	}
+}
+
+// TestFS ensures the templates are read from the FS when it is set.
+func TestFS(t *testing.T) {
+	viewInfo := &view.Info{
+		BaseURI:   "/",
+		Extension: "tmpl",
+		Folder:    "view",
+		Caching:   true,
+		FS: fstest.MapFS{
+			"view/base.tmpl":         {Data: []byte(`<html>{{template "content" .}}</html>`)},
+			"view/layout/admin.tmpl": {Data: []byte(`{{/* extends "base" */}}{{define "content"}}<main>{{block "main" .}}{{end}}</main>{{end}}`)},
+			"view/foo/index.tmpl":    {Data: []byte(`{{define "main"}}{{template "greeting"}}{{end}}`)},
+			"view/foo/partial.tmpl":  {Data: []byte(`{{define "greeting"}}Hello{{end}}`)},
+		},
+	}
+
+	// Set up the view
+	viewInfo.SetTemplates("base", []string{})
+	viewInfo.SetPartials(map[string][]string{"foo": {"foo/partial"}})
+
+	// Simulate a request
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Render the view
+	viewInfo.New("foo/index").Base("layout/admin").Render(w, r)
+
+	received := w.Body.String()
+	expected := `<html><main>Hello</main></html>`
+
+	if received != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", received, expected)
+	}
+}
+
+// TestParseAll ensures every template is parsed and the errors are returned.
+func TestParseAll(t *testing.T) {
+	viewInfo := &view.Info{
+		BaseURI:   "/",
+		Extension: "tmpl",
+		Folder:    "testdata/view",
+	}
+
+	err := viewInfo.ParseAll()
+	if err == nil || !strings.Contains(err.Error(), `function "HELLO" not defined`) {
+		t.Fatalf("\nactual: %v\nexpected: %v", err, `function "HELLO" not defined`)
+	}
+
+	viewInfo.SetFuncMaps(Map())
+
+	if err := viewInfo.ParseAll(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRenderError ensures nothing from a failed template is written.
+func TestRenderError(t *testing.T) {
+	viewInfo := &view.Info{
+		BaseURI:   "/",
+		Extension: "tmpl",
+		Folder:    "testdata/view",
+	}
+	viewInfo.SetTemplates("basetest", []string{})
+
+	// Simulate a request
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Render the view
+	v := viewInfo.New("foo/failtest")
+	v.Vars["List"] = []string{}
+	if v.Render(w, r) == nil {
+		t.Fatal("expected an error")
+	}
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Code, http.StatusInternalServerError)
+	}
+	if received := w.Body.String(); strings.Contains(received, "Partial") || !strings.HasPrefix(received, "Template File Error:") {
+		t.Errorf("\nactual: %v\nexpected: %v", received, "Template File Error: ...")
+	}
+}
+
+// TestErrorHandler ensures the error handler writes the response and is not
+// called again when its own page fails.
+func TestErrorHandler(t *testing.T) {
+	viewInfo := &view.Info{
+		BaseURI:   "/",
+		Extension: "tmpl",
+		Folder:    "testdata/view",
+	}
+	viewInfo.SetTemplates("basetest", []string{})
+
+	calls := 0
+	viewInfo.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		calls++
+		if calls > 1 {
+			t.Fatal("error handler called again")
+		}
+
+		// Render a page that fails too
+		if r.URL.Query().Get("fail") == "1" {
+			viewInfo.New("foo/failtest").Render(w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("Handled"))
+	})
+
+	// Simulate a request
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viewInfo.New("foo/failtest").Render(w, r)
+
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "Handled" {
+		t.Errorf("\nactual: %v %v\nexpected: %v %v", w.Code, w.Body.String(), http.StatusServiceUnavailable, "Handled")
+	}
+
+	// Simulate a request where the error page fails
+	calls = 0
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest("GET", "/?fail=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viewInfo.New("foo/failtest").Render(w, r)
+
+	if w.Code != http.StatusInternalServerError || !strings.HasPrefix(w.Body.String(), "Template File Error:") {
+		t.Errorf("\nactual: %v %v\nexpected: %v %v", w.Code, w.Body.String(), http.StatusInternalServerError, "Template File Error: ...")
+	}
+}
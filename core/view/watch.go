@@ -0,0 +1,93 @@
+package view
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileState is used to tell when a file has changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch checks the files in the Folder for changes at the interval and
+// removes the template collections that were parsed from a changed file so
+// only those are parsed again on the next request. Call it after
+// SetTemplates. Call the returned func to stop watching.
+func (v *Info) Watch(interval time.Duration) (stop func()) {
+	c := v.cache
+	last := v.snapshot()
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				current := v.snapshot()
+
+				for path, state := range current {
+					if old, ok := last[path]; !ok || old != state {
+						c.invalidate(path)
+					}
+				}
+
+				for path := range last {
+					if _, ok := current[path]; !ok {
+						c.invalidate(path)
+					}
+				}
+
+				last = current
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
+
+// snapshot returns the state of every file in the Folder by the path used to
+// parse it.
+func (v *Info) snapshot() map[string]fileState {
+	files := make(map[string]fileState)
+
+	if v.FS != nil {
+		fs.WalkDir(v.FS, v.Folder, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if fi, err := d.Info(); err == nil {
+				files[path] = fileState{fi.ModTime(), fi.Size()}
+			}
+			return nil
+		})
+
+		return files
+	}
+
+	filepath.Walk(v.Folder, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return nil
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			files[abs] = fileState{fi.ModTime(), fi.Size()}
+		}
+		return nil
+	})
+
+	return files
+}
@@ -0,0 +1,124 @@
+package view_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/core/view"
+)
+
+// writeTemplate writes the template to the folder.
+func writeTemplate(t *testing.T, folder, name, text string) {
+	path := filepath.Join(folder, name+".tmpl")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// renderPage returns the body of the page.
+func renderPage(t *testing.T, viewInfo *view.Info, page string) string {
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viewInfo.New(page).Render(w, r)
+
+	return w.Body.String()
+}
+
+// TestWatch ensures only the template collections with a changed file are
+// parsed again.
+func TestWatch(t *testing.T) {
+	folder, err := ioutil.TempDir("", "view")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	writeTemplate(t, folder, "base", `{{template "content" .}}`)
+	writeTemplate(t, folder, "foo/index", `{{define "content"}}Foo{{end}}`)
+	writeTemplate(t, folder, "bar/index", `{{define "content"}}Bar{{end}}`)
+
+	viewInfo := &view.Info{
+		BaseURI:   "/",
+		Extension: "tmpl",
+		Folder:    folder,
+		Caching:   true,
+		Reload:    true,
+	}
+	viewInfo.SetTemplates("base", []string{})
+
+	stop := viewInfo.Watch(10 * time.Millisecond)
+	defer stop()
+
+	renderPage(t, viewInfo, "foo/index")
+	renderPage(t, viewInfo, "bar/index")
+
+	// Change the file contents without changing the size
+	writeTemplate(t, folder, "foo/index", `{{define "content"}}Baz{{end}}`)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(folder, "foo", "index.tmpl"), later, later)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for renderPage(t, viewInfo, "foo/index") != "Baz" {
+		if time.Now().After(deadline) {
+			t.Fatal("template was not parsed again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stop()
+
+	// The other page is still cached so the change is not seen
+	writeTemplate(t, folder, "bar/index", `{{define "content"}}Bar changed{{end}}`)
+
+	received := renderPage(t, viewInfo, "bar/index")
+	expected := "Bar"
+
+	if received != expected {
+		t.Fatalf("\nactual: %v\nexpected: %v", received, expected)
+	}
+}
+
+// TestErrorPage ensures a parse error shows the file and line during
+// development.
+func TestErrorPage(t *testing.T) {
+	folder, err := ioutil.TempDir("", "view")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	writeTemplate(t, folder, "base", `{{template "content" .}}`)
+	writeTemplate(t, folder, "foo/index", "{{define \"content\"}}\n<p>{{.Name}</p>\n{{end}}")
+
+	viewInfo := &view.Info{
+		BaseURI:   "/",
+		Extension: "tmpl",
+		Folder:    folder,
+		Reload:    true,
+	}
+	viewInfo.SetTemplates("base", []string{})
+
+	received := renderPage(t, viewInfo, "foo/index")
+
+	for _, expected := range []string{
+		filepath.Join(folder, "foo", "index.tmpl") + ":2</h2>",
+		`<span class="current">   2  &lt;p&gt;{{.Name}&lt;/p&gt;</span>`,
+	} {
+		if !strings.Contains(received, expected) {
+			t.Errorf("\nactual: %v\nexpected: %v", received, expected)
+		}
+	}
+}
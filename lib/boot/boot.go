@@ -3,41 +3,81 @@ package boot
 
 import (
 	"log"
+	"net/http"
+	"time"
 
 	"github.com/pcieslar/goforge/controller"
+	"github.com/pcieslar/goforge/controller/status"
 	"github.com/pcieslar/goforge/lib/env"
 	"github.com/pcieslar/goforge/lib/flight"
 	"github.com/pcieslar/goforge/viewfunc/link"
 	"github.com/pcieslar/goforge/viewfunc/noescape"
 	"github.com/pcieslar/goforge/viewfunc/prettytime"
+	"github.com/pcieslar/goforge/viewfunc/url"
 	"github.com/pcieslar/goforge/viewmodify/authlevel"
 	"github.com/pcieslar/goforge/viewmodify/flash"
+	"github.com/pcieslar/goforge/viewmodify/locale"
 	"github.com/pcieslar/goforge/viewmodify/uri"
 
 	"github.com/pcieslar/goforge/core/form"
 	"github.com/pcieslar/goforge/core/pagination"
+	"github.com/pcieslar/goforge/core/router"
 	"github.com/pcieslar/goforge/core/xsrf"
 )
 
+// LoadRoutes loads the routes of the controllers and the routes that depend
+// on the settings.
+func LoadRoutes(config *env.Info) {
+	controller.LoadRoutes()
+
+	// Serve the API document
+	if len(config.OpenAPI.Path) > 0 {
+		router.Get(config.OpenAPI.Path, config.OpenAPI.Handler()).Named("openapi")
+	}
+}
+
 // RegisterServices sets up all the web components.
 func RegisterServices(config *env.Info) {
-	// Set up the session cookie store
+	// Connect to the MySQL database
+	// mysqlDB, _ := config.MySQL.Connect(true)
+
+	// Connect to the Gorm database
+	mysqlDB, _ := config.GORM.Connect(true)
+
+	// Set up the session store
+	config.Session.SetDB(mysqlDB)
 	err := config.Session.SetupConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Connect to the MySQL database
-	// mysqlDB, _ := config.MySQL.Connect(true)
+	// Remove the expired sessions from the server
+	config.Session.Clean()
 
-	// Connect to the Gorm database
-	mysqlDB, _ := config.GORM.Connect(true)
+	// Set up the failed login tracking
+	config.Lockout.SetDB(mysqlDB)
+	if err := config.Lockout.SetupConfig(); err != nil {
+		log.Fatal(err)
+	}
 
-	// Load the controller routes
-	controller.LoadRoutes()
+	// Remove the old failed logins
+	config.Lockout.Clean()
+
+	// Cache the discovery documents of the login providers
+	config.OIDC.SetupConfig()
+
+	// Load the message catalogs
+	catalogs, err := config.I18n.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the routes
+	LoadRoutes(config)
 
 	// Set up the views
 	config.View.SetTemplates(config.Template.Root, config.Template.Children)
+	config.View.SetPartials(config.Template.Partials)
 
 	// Set up the functions for the views
 	config.View.SetFuncMaps(
@@ -45,14 +85,35 @@ func RegisterServices(config *env.Info) {
 		link.Map(config.View.BaseURI),
 		noescape.Map(),
 		prettytime.Map(),
+		url.Map(config.View.BaseURI),
 		form.Map(),
 		pagination.Map(),
+		catalogs.Map(),
 	)
 
+	// Show the error page when a template cannot be rendered
+	config.View.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Println("Template Error:", err)
+		status.Error500(w, r)
+	})
+
+	// Parse every template so errors are found before serving requests
+	if config.View.Precompile {
+		if err := config.View.ParseAll(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Parse the templates again when they change
+	if config.View.Reload {
+		config.View.Watch(time.Second)
+	}
+
 	// Set up the variables and modifiers for the views
 	config.View.SetModifiers(
 		authlevel.Modify,
 		uri.Modify,
+		locale.Modify,
 		xsrf.Token,
 		flash.Modify,
 	)
@@ -60,6 +121,9 @@ func RegisterServices(config *env.Info) {
 	// Store the variables in flight
 	flight.StoreConfig(*config)
 
+	// Store the message catalogs in flight
+	flight.StoreI18n(catalogs)
+
 	// Store the database connection in flight
 	flight.StoreGORM(mysqlDB)
 
@@ -4,6 +4,7 @@ package boot
 import (
 	"net/http"
 
+	"github.com/pcieslar/goforge/lib/flight"
 	"github.com/pcieslar/goforge/middleware/logrequest"
 	"github.com/pcieslar/goforge/middleware/rest"
 	"github.com/pcieslar/goforge/core/router"
@@ -18,5 +19,6 @@ func SetUpMiddleware(h http.Handler) http.Handler {
 		rest.Handler,         // Support changing HTTP method sent via query string
 		logrequest.Handler,   // Log every request
 		context.ClearHandler, // Prevent memory leak with gorilla.sessions
+		flight.Handler,       // Load the session once per request
 	)
 }
@@ -5,12 +5,32 @@ import (
 	"encoding/base64"
 	"log"
 	"net/http"
+	"strings"
 
+	"github.com/pcieslar/goforge/controller/api"
 	"github.com/pcieslar/goforge/controller/status"
 	"github.com/pcieslar/goforge/lib/flight"
 	"github.com/gorilla/csrf"
 )
 
+// csrfExempt contains the path prefixes that are not checked for a CSRF token.
+// The API authenticates with bearer tokens instead of the session cookie.
+var csrfExempt = []string{
+	api.Prefix + "/",
+}
+
+// CSRFProtected returns true if requests to the path are checked for a CSRF
+// token.
+func CSRFProtected(path string) bool {
+	for _, prefix := range csrfExempt {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // setUpCSRF sets up the CSRF protection.
 func setUpCSRF(h http.Handler) http.Handler {
 	x := flight.Xsrf()
@@ -27,5 +47,12 @@ func setUpCSRF(h http.Handler) http.Handler {
 		csrf.FieldName("_token"),
 		csrf.Secure(x.Secure),
 	)(h)
-	return cs
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !CSRFProtected(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cs.ServeHTTP(w, r)
+	})
 }
@@ -8,11 +8,17 @@ import (
 	"github.com/pcieslar/goforge/core/email"
 	"github.com/pcieslar/goforge/core/form"
 	"github.com/pcieslar/goforge/core/generate"
+	"github.com/pcieslar/goforge/core/i18n"
 	"github.com/pcieslar/goforge/core/jsonconfig"
+	"github.com/pcieslar/goforge/core/lockout"
+	"github.com/pcieslar/goforge/core/oidc"
+	"github.com/pcieslar/goforge/core/openapi"
 	"github.com/pcieslar/goforge/core/server"
 	"github.com/pcieslar/goforge/core/session"
+	"github.com/pcieslar/goforge/core/signature"
 	"github.com/pcieslar/goforge/core/storage/driver/gorm"
 	"github.com/pcieslar/goforge/core/storage/driver/mysql"
+	"github.com/pcieslar/goforge/core/totp"
 	"github.com/pcieslar/goforge/core/view"
 )
 
@@ -22,16 +28,24 @@ import (
 
 // Info structures the application settings.
 type Info struct {
-	Asset      asset.Info    `json:"Asset"`
-	Email      email.Info    `json:"Email"`
-	Form       form.Info     `json:"Form"`
-	Generation generate.Info `json:"Generation"`
-	MySQL      mysql.Info    `json:"MySQL"`
-	GORM       gorm.Info     `json:"GORM"`
-	Server     server.Info   `json:"Server"`
-	Session    session.Info  `json:"Session"`
-	Template   view.Template `json:"Template"`
-	View       view.Info     `json:"View"`
+	Admins     []string       `json:"Admins"` // Email addresses of the users allowed on the admin pages
+	Asset      asset.Info     `json:"Asset"`
+	Email      email.Info     `json:"Email"`
+	Embed      bool           `json:"Embed"` // Use the views and assets compiled into the binary
+	Form       form.Info      `json:"Form"`
+	Generation generate.Info  `json:"Generation"`
+	I18n       i18n.Info      `json:"I18n"`
+	Lockout    lockout.Info   `json:"Lockout"`
+	MySQL      mysql.Info     `json:"MySQL"`
+	GORM       gorm.Info      `json:"GORM"`
+	OIDC       oidc.Info      `json:"OIDC"` // External login providers
+	OpenAPI    openapi.Info   `json:"OpenAPI"`
+	Server     server.Info    `json:"Server"`
+	Session    session.Info   `json:"Session"`
+	Template   view.Template  `json:"Template"`
+	TOTP       totp.Info      `json:"TOTP"`   // Codes from authenticator apps for two-factor authentication
+	Verify     signature.Info `json:"Verify"` // Signs the email verification links
+	View       view.Info      `json:"View"`
 	path       string
 }
 
@@ -2,9 +2,12 @@
 package flight
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/pcieslar/goforge/lib/env"
@@ -12,43 +15,96 @@ import (
 
 	"github.com/pcieslar/goforge/core/flash"
 	"github.com/pcieslar/goforge/core/form"
+	"github.com/pcieslar/goforge/core/i18n"
 	"github.com/pcieslar/goforge/core/router"
 	"github.com/pcieslar/goforge/core/view"
+	"github.com/pcieslar/goforge/core/xsrf"
 
 	"github.com/gorilla/sessions"
 	"github.com/jmoiron/sqlx"
 )
 
+// App holds the application settings and connections shared by every
+// request. The package level Store functions populate the default App.
+type App struct {
+	Config *env.Info
+	DB     *sqlx.DB
+	GORM   *gorm.DB
+	I18n   *i18n.Bundle
+	Xsrf   xsrf.Info
+}
+
+// contextKey is the type of the key for the request settings.
+type contextKey int
+
+// infoKey is the request context key for the request settings.
+const infoKey contextKey = 0
+
 var (
-	configInfo env.Info
-	dbInfo     *sqlx.DB
-	gormInfo   *gorm.DB
+	// ErrPublicURL is returned when a link for outside the site is needed
+	// but Server.PublicURL is not set.
+	ErrPublicURL = errors.New("Server.PublicURL is not set.")
 
-	mutex sync.RWMutex
+	defaultApp = New(&env.Info{})
+	mutex      sync.RWMutex
 )
 
+// New returns an App that is isolated from the package level settings. The
+// App should not be modified once it is serving requests.
+func New(config *env.Info) *App {
+	return &App{
+		Config: config,
+	}
+}
+
+// update safely replaces the default App with a modified copy so requests
+// in progress keep a consistent view.
+func update(fn func(a *App)) {
+	mutex.Lock()
+	a := *defaultApp
+	fn(&a)
+	defaultApp = &a
+	mutex.Unlock()
+}
+
+// current returns the default App.
+func current() *App {
+	mutex.RLock()
+	a := defaultApp
+	mutex.RUnlock()
+	return a
+}
+
 // StoreConfig stores the application settings so controller functions can
 //access them safely.
 func StoreConfig(ci env.Info) {
-	mutex.Lock()
-	configInfo = ci
-	mutex.Unlock()
+	update(func(a *App) {
+		a.Config = &ci
+	})
 }
 
 // StoreDB stores the database connection settings so controller functions can
 // access them safely.
 func StoreDB(db *sqlx.DB) {
-	mutex.Lock()
-	dbInfo = db
-	mutex.Unlock()
+	update(func(a *App) {
+		a.DB = db
+	})
 }
 
-// StoreDB stores the database connection settings so controller functions can
-// access them safely.
+// StoreGORM stores the GORM database connection so controller functions can
+// access it safely.
 func StoreGORM(db *gorm.DB) {
-	mutex.Lock()
-	gormInfo = db
-	mutex.Unlock()
+	update(func(a *App) {
+		a.GORM = db
+	})
+}
+
+// StoreI18n stores the message catalogs so controller functions can access
+// them safely.
+func StoreI18n(b *i18n.Bundle) {
+	update(func(a *App) {
+		a.I18n = b
+	})
 }
 
 // Info structures the application settings.
@@ -60,14 +116,52 @@ type Info struct {
 	R      *http.Request
 	View   view.Info
 	DB     *sqlx.DB
+	GORM   *gorm.DB
+	I18n   *i18n.Bundle
+	Locale string
+}
+
+// Handler loads the settings for the request from the default App once and
+// stores them in the request context.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current().serve(h, w, r)
+	})
+}
+
+// Handler loads the settings for the request from the App once and stores
+// them in the request context.
+func (a *App) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.serve(h, w, r)
+	})
+}
+
+// serve stores the request settings and then calls the handler.
+func (a *App) serve(h http.Handler, w http.ResponseWriter, r *http.Request) {
+	i := a.context(w, r)
+	h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), infoKey, &i)))
 }
 
-// Context returns the application settings.
+// Context returns the application settings. The settings stored by Handler
+// are used when available, otherwise they are loaded from the default App.
 func Context(w http.ResponseWriter, r *http.Request) Info {
+	if stored, ok := r.Context().Value(infoKey).(*Info); ok {
+		i := *stored
+		i.W = w
+		i.R = r
+		return i
+	}
+
+	return current().context(w, r)
+}
+
+// context loads the session and returns the application settings.
+func (a *App) context(w http.ResponseWriter, r *http.Request) Info {
 	var id string
 
 	// Get the session
-	sess, err := configInfo.Session.Instance(r)
+	sess, err := a.Config.Session.Instance(r)
 
 	// If the session is valid
 	if err == nil {
@@ -75,27 +169,58 @@ func Context(w http.ResponseWriter, r *http.Request) Info {
 		id = fmt.Sprintf("%v", sess.Values["id"])
 	}
 
-	mutex.RLock()
-	i := Info{
-		Config: configInfo,
+	return Info{
+		Config: *a.Config,
 		Sess:   sess,
 		UserID: id,
 		W:      w,
 		R:      r,
-		View:   configInfo.View,
-		DB:     dbInfo,
+		View:   a.Config.View,
+		DB:     a.DB,
+		GORM:   a.GORM,
+		I18n:   a.I18n,
+		Locale: a.locale(r, sess),
 	}
-	mutex.RUnlock()
+}
 
-	return i
+// locale returns the locale from the preference in the session, the cookie,
+// and then the Accept-Language header.
+func (a *App) locale(r *http.Request, sess *sessions.Session) string {
+	var preference, cookie string
+
+	if sess != nil {
+		preference, _ = sess.Values["locale"].(string)
+	}
+
+	if name := a.Config.I18n.Cookie; len(name) > 0 {
+		if c, err := r.Cookie(name); err == nil {
+			cookie = c.Value
+		}
+	}
+
+	return a.I18n.Match(preference, cookie, r.Header.Get("Accept-Language"))
+}
+
+// WithGORM returns a copy of the request where Context hands out the
+// database connection, like a transaction, instead of the one from the App.
+func WithGORM(r *http.Request, db *gorm.DB) *http.Request {
+	i := Context(nil, r)
+	i.GORM = db
+	return r.WithContext(context.WithValue(r.Context(), infoKey, &i))
+}
+
+// WithUserID returns a copy of the request where Context hands out the user
+// ID, like one authenticated by a token, instead of the one from the session.
+func WithUserID(r *http.Request, id string) *http.Request {
+	i := Context(nil, r)
+	i.UserID = id
+	return r.WithContext(context.WithValue(r.Context(), infoKey, &i))
 }
 
 // Reset will delete all package globals
 func Reset() {
 	mutex.Lock()
-	configInfo = env.Info{}
-	dbInfo = &sqlx.DB{}
-	gormInfo = &gorm.DB{}
+	defaultApp = New(&env.Info{})
 	mutex.Unlock()
 }
 
@@ -104,16 +229,58 @@ func (c *Info) Param(name string) string {
 	return router.Param(c.R, name)
 }
 
+// ParamInt gets the URL parameter as an int. The route must declare the int
+// constraint like :id<int> so the value is always valid.
+func (c *Info) ParamInt(name string) int {
+	return router.ParamInt(c.R, name)
+}
+
+// T returns the message translated to the locale of the request. The args
+// are formatted into the message like fmt.Sprintf.
+func (c *Info) T(message string, args ...interface{}) string {
+	return c.I18n.Translate(c.Locale, message, args...)
+}
+
+// TN returns the message translated to the locale of the request in the
+// plural form for the count.
+func (c *Info) TN(message string, n int, args ...interface{}) string {
+	return c.I18n.Plural(c.Locale, message, n, args...)
+}
+
+// IP returns the address of the client without the port. The forwarded
+// headers are only read from the Server.TrustedProxies.
+func (c *Info) IP() string {
+	return c.Config.Server.ClientIP(c.R)
+}
+
 // Redirect sends a temporary redirect.
 func (c *Info) Redirect(urlStr string) {
 	http.Redirect(c.W, c.R, urlStr, http.StatusFound)
 }
 
+// PublicURL returns the absolute URL of the named route for links sent
+// outside the site like in emails. The scheme and host are read from
+// Server.PublicURL and never from the request because the Host header is
+// chosen by the client.
+func (c *Info) PublicURL(name string, params ...interface{}) (string, error) {
+	base := strings.TrimSuffix(c.Config.Server.PublicURL, "/")
+	if len(base) == 0 {
+		return "", ErrPublicURL
+	}
+
+	path, err := router.URL(name, params...)
+	if err != nil {
+		return "", err
+	}
+
+	return base + strings.TrimSuffix(c.View.BaseURI, "/") + path, nil
+}
+
 // FormValid determines if the user submitted all the required fields and then
 // saves an error flash. Returns true if form is valid.
 func (c *Info) FormValid(fields ...string) bool {
 	if valid, missingField := form.Required(c.R, fields...); !valid {
-		c.Sess.AddFlash(flash.Info{"Field missing: " + missingField, flash.Warning})
+		c.Sess.AddFlash(flash.Info{c.T("Field missing: %v", missingField), flash.Warning})
 		c.Sess.Save(c.R, c.W)
 		return false
 	}
@@ -126,34 +293,38 @@ func (c *Info) Repopulate(v map[string]interface{}, fields ...string) {
 	form.Repopulate(c.R.Form, v, fields...)
 }
 
-// FlashSuccess saves a success flash.
-func (c *Info) FlashSuccess(message string) {
-	c.Sess.AddFlash(flash.Info{message, flash.Success})
+// FlashSuccess saves a success flash. The message is translated and the
+// args are formatted into it.
+func (c *Info) FlashSuccess(message string, args ...interface{}) {
+	c.Sess.AddFlash(flash.Info{c.T(message, args...), flash.Success})
 	c.Sess.Save(c.R, c.W)
 }
 
-// FlashNotice saves a notice flash.
-func (c *Info) FlashNotice(message string) {
-	c.Sess.AddFlash(flash.Info{message, flash.Notice})
+// FlashNotice saves a notice flash. The message is translated and the
+// args are formatted into it.
+func (c *Info) FlashNotice(message string, args ...interface{}) {
+	c.Sess.AddFlash(flash.Info{c.T(message, args...), flash.Notice})
 	c.Sess.Save(c.R, c.W)
 }
 
-// FlashWarning saves a warning flash.
-func (c *Info) FlashWarning(message string) {
-	c.Sess.AddFlash(flash.Info{message, flash.Warning})
+// FlashWarning saves a warning flash. The message is translated and the
+// args are formatted into it.
+func (c *Info) FlashWarning(message string, args ...interface{}) {
+	c.Sess.AddFlash(flash.Info{c.T(message, args...), flash.Warning})
 	c.Sess.Save(c.R, c.W)
 }
 
-// FlashError saves an error flash and logs the error.
+// FlashError saves an error flash with the translated error and logs the
+// error.
 func (c *Info) FlashError(err error) {
 	log.Println(err)
-	c.Sess.AddFlash(flash.Info{err.Error(), flash.Error})
+	c.Sess.AddFlash(flash.Info{c.T(err.Error()), flash.Error})
 	c.Sess.Save(c.R, c.W)
 }
 
 // FlashErrorGeneric saves a generic error flash and logs the error.
 func (c *Info) FlashErrorGeneric(err error) {
 	log.Println(err)
-	c.Sess.AddFlash(flash.Info{"An error occurred on the server. Please try again later.", flash.Error})
+	c.Sess.AddFlash(flash.Info{c.T("An error occurred on the server. Please try again later."), flash.Error})
 	c.Sess.Save(c.R, c.W)
 }
@@ -2,6 +2,7 @@
 package flight_test
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,9 @@ import (
 
 	"github.com/pcieslar/goforge/lib/env"
 	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/lib/gorm"
+
+	"github.com/pcieslar/goforge/core/router"
 )
 
 // TestRace tests for race conditions.
@@ -47,3 +51,181 @@ func TestRace(t *testing.T) {
 		}()
 	}
 }
+
+// TestHandler ensures the settings stored by the middleware are returned.
+func TestHandler(t *testing.T) {
+	config, err := env.LoadConfig("../../env.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := config.Session.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Asset.Folder = "handlertest"
+	app := flight.New(config)
+
+	var stored, received flight.Info
+
+	handler := app.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stored = flight.Context(w, r)
+		stored.Sess.Values["foo"] = "bar"
+		received = flight.Context(w, r)
+	}))
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "http://localhost/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(w, r)
+
+	if received.Config.Asset.Folder != "handlertest" {
+		t.Fatalf("\nactual: %v\nexpected: %v", received.Config.Asset.Folder, "handlertest")
+	}
+
+	// The session should only be loaded once per request
+	if received.Sess != stored.Sess {
+		t.Fatal("session should be shared across the request")
+	}
+
+	if received.Sess.Values["foo"] != "bar" {
+		t.Fatalf("\nactual: %v\nexpected: %v", received.Sess.Values["foo"], "bar")
+	}
+}
+
+// TestHandlerIsolation ensures multiple apps can serve requests in parallel
+// without sharing settings.
+func TestHandlerIsolation(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		folder := fmt.Sprintf("folder%v", i)
+
+		t.Run(folder, func(t *testing.T) {
+			t.Parallel()
+
+			config, err := env.LoadConfig("../../env.json.example")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := config.Session.SetupConfig(); err != nil {
+				t.Fatal(err)
+			}
+
+			config.Asset.Folder = folder
+			app := flight.New(config)
+
+			for j := 0; j < 50; j++ {
+				var received string
+
+				handler := app.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					received = flight.Context(w, r).Config.Asset.Folder
+				}))
+
+				w := httptest.NewRecorder()
+				r, err := http.NewRequest("GET", "http://localhost/foo", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				handler.ServeHTTP(w, r)
+
+				if received != folder {
+					t.Fatalf("\nactual: %v\nexpected: %v", received, folder)
+				}
+			}
+		})
+	}
+}
+
+// TestWithGORM ensures the request database connection replaces the one
+// from the App.
+func TestWithGORM(t *testing.T) {
+	config, err := env.LoadConfig("../../env.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := config.Session.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	app := flight.New(config)
+	app.GORM = &gorm.DB{}
+	tx := &gorm.DB{}
+
+	var before, after *gorm.DB
+
+	handler := app.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before = flight.Context(w, r).GORM
+		r = flight.WithGORM(r, tx)
+		after = flight.Context(w, r).GORM
+	}))
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "http://localhost/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(w, r)
+
+	if before != app.GORM {
+		t.Fatal("expected the App database connection")
+	}
+
+	if after != tx {
+		t.Fatal("expected the request database connection")
+	}
+}
+
+// TestPublicURL ensures the absolute URL uses the configured host and never
+// the host sent by the client.
+func TestPublicURL(t *testing.T) {
+	config, err := env.LoadConfig("../../env.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := config.Session.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	router.Get("/flight/reset/:token", func(w http.ResponseWriter, r *http.Request) {}).Named("flight.reset")
+
+	for _, tt := range []struct {
+		public   string
+		expected string
+		err      error
+	}{
+		{"", "", flight.ErrPublicURL},
+		{"https://example.com/", "https://example.com/flight/reset/abc", nil},
+	} {
+		config.Server.PublicURL = tt.public
+		app := flight.New(config)
+
+		var received string
+		var urlErr error
+		handler := app.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := flight.Context(w, r)
+			received, urlErr = c.PublicURL("flight.reset", "token", "abc")
+		}))
+
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "http://evil.example/foo", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handler.ServeHTTP(w, r)
+
+		if urlErr != tt.err {
+			t.Fatalf("\nactual: %v\nexpected: %v", urlErr, tt.err)
+		}
+		if received != tt.expected {
+			t.Fatalf("\nactual: %v\nexpected: %v", received, tt.expected)
+		}
+	}
+}
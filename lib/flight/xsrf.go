@@ -1,27 +1,17 @@
 package flight
 
 import (
-	"sync"
-
 	"github.com/pcieslar/goforge/core/xsrf"
 )
 
-var (
-	xsrfInfo  xsrf.Info
-	xsrfMutex sync.RWMutex
-)
-
 // StoreXsrf sets the csrf configuration.
 func StoreXsrf(x xsrf.Info) {
-	xsrfMutex.Lock()
-	xsrfInfo = x
-	xsrfMutex.Unlock()
+	update(func(a *App) {
+		a.Xsrf = x
+	})
 }
 
 // Xsrf returns the csrf configuration.
 func Xsrf() xsrf.Info {
-	xsrfMutex.RLock()
-	x := xsrfInfo
-	xsrfMutex.RUnlock()
-	return x
+	return current().Xsrf
 }
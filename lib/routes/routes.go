@@ -0,0 +1,193 @@
+// Package routes lists the registered routes so the access control and CSRF
+// protection of each route can be reviewed without starting the server. It
+// also exports the OpenAPI document for the API routes.
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pcieslar/goforge/lib/boot"
+	"github.com/pcieslar/goforge/lib/env"
+
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// ErrAudit is when at least one route fails the audit.
+var ErrAudit = errors.New("Route audit failed.")
+
+// tokenAuth is the middleware that authenticates API requests.
+const tokenAuth = "bearer.Handler"
+
+// Info is a route with the protection that applies to it.
+type Info struct {
+	router.Route
+	CSRF bool // Checked for a CSRF token
+}
+
+// Run loads the routes the application serves and writes them to w. The
+// routes that depend on the settings, like the OpenAPI document, are read from
+// the configuration file. The args are the command line flags after the
+// command name:
+//
+//	-json            write the routes as JSON instead of a table
+//	-audit           only write the mutating routes that fail the audit
+//	-require name    middleware every mutating route must carry
+//	-allow names     comma separated route names that skip the audit
+//	-openapi         write the OpenAPI document instead of the routes
+//	-config file     configuration file, env.json by default
+func Run(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("routes", flag.ContinueOnError)
+	fs.SetOutput(w)
+	asJSON := fs.Bool("json", false, "write the routes as JSON")
+	audit := fs.Bool("audit", false, "only write the mutating routes that fail the audit")
+	require := fs.String("require", "acl.DisallowAnon", "middleware every mutating route must carry")
+	allow := fs.String("allow", "", "comma separated route names that skip the audit")
+	doc := fs.Bool("openapi", false, "write the OpenAPI document instead of the routes")
+	configFile := fs.String("config", "env.json", "configuration file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := env.LoadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	boot.LoadRoutes(config)
+
+	if *doc {
+		return writeIndented(w, config.OpenAPI.Generate(router.Routes()))
+	}
+	list := List(router.Routes())
+
+	if *audit {
+		list = Audit(list, *require, names(*allow))
+	}
+
+	if *asJSON {
+		err = WriteJSON(w, list)
+	} else {
+		err = WriteTable(w, list)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *audit && len(list) > 0 {
+		return ErrAudit
+	}
+
+	return nil
+}
+
+// List returns the routes with the protection that applies to each.
+func List(routes []router.Route) []Info {
+	list := make([]Info, len(routes))
+	for i, rt := range routes {
+		list[i] = Info{
+			Route: rt,
+			CSRF:  boot.CSRFProtected(rt.Path),
+		}
+	}
+
+	return list
+}
+
+// Audit returns the routes that change state but do not carry the required
+// middleware or are not checked for a CSRF token. Routes authenticated with a
+// bearer token and routes with a name in allow are skipped.
+func Audit(list []Info, require string, allow []string) []Info {
+	var failed []Info
+
+	for _, rt := range list {
+		if !mutating(rt.Method) || contains(allow, rt.Name) {
+			continue
+		}
+
+		// Token authentication does not rely on the session cookie so the
+		// CSRF check is not needed
+		if contains(rt.Middleware, tokenAuth) {
+			continue
+		}
+
+		if !rt.CSRF || !contains(rt.Middleware, require) {
+			failed = append(failed, rt)
+		}
+	}
+
+	return failed
+}
+
+// WriteTable writes the routes as aligned columns.
+func WriteTable(w io.Writer, list []Info) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "METHOD\tPATH\tNAME\tHANDLER\tMIDDLEWARE\tCSRF")
+	for _, rt := range list {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			rt.Method,
+			rt.Path,
+			rt.Name,
+			rt.Handler,
+			strings.Join(rt.Middleware, ", "),
+			rt.CSRF,
+		)
+	}
+
+	return tw.Flush()
+}
+
+// WriteJSON writes the routes as an indented JSON array.
+func WriteJSON(w io.Writer, list []Info) error {
+	if list == nil {
+		list = []Info{}
+	}
+
+	return writeIndented(w, list)
+}
+
+// writeIndented writes the value as indented JSON.
+func writeIndented(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// mutating returns true if the HTTP method changes state.
+func mutating(method string) bool {
+	switch method {
+	case "DELETE", "PATCH", "POST", "PUT":
+		return true
+	}
+
+	return false
+}
+
+// names splits the comma separated route names.
+func names(s string) []string {
+	var list []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			list = append(list, name)
+		}
+	}
+
+	return list
+}
+
+// contains returns true if the list contains the value.
+func contains(list []string, value string) bool {
+	for _, s := range list {
+		if s == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pcieslar/goforge/lib/routes"
+
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// TestAudit ensures only the unprotected mutating routes are returned.
+func TestAudit(t *testing.T) {
+	list := routes.List([]router.Route{
+		{Method: "GET", Path: "/notepad", Name: "notepad.index"},
+		{Method: "POST", Path: "/notepad", Name: "notepad.store", Middleware: []string{"acl.DisallowAnon"}},
+		{Method: "DELETE", Path: "/notepad/:id", Name: "notepad.destroy"},
+		{Method: "POST", Path: "/login", Name: "login.store", Middleware: []string{"acl.DisallowAuth"}},
+		{Method: "POST", Path: "/api/v1/notes", Name: "api.notes.store", Middleware: []string{"bearer.Handler"}},
+	})
+
+	failed := routes.Audit(list, "acl.DisallowAnon", nil)
+	if len(failed) != 2 {
+		t.Fatalf("\n got: %v\nwant: %v", len(failed), 2)
+	}
+	if failed[0].Name != "notepad.destroy" || failed[1].Name != "login.store" {
+		t.Fatalf("\n got: %v, %v\nwant: %v, %v", failed[0].Name, failed[1].Name, "notepad.destroy", "login.store")
+	}
+
+	failed = routes.Audit(list, "acl.DisallowAnon", []string{"login.store"})
+	if len(failed) != 1 {
+		t.Fatalf("\n got: %v\nwant: %v", len(failed), 1)
+	}
+}
+
+// TestRun ensures the routes from the controllers are written.
+func TestRun(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+
+	buf := new(bytes.Buffer)
+	if err := routes.Run(buf, []string{"-json", "-config", "../../env.json.example"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var list []routes.Info
+	if err := json.Unmarshal(buf.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+
+	found, openapi := false, false
+	for _, rt := range list {
+		if rt.Name == "openapi" {
+			openapi = true
+		}
+		if rt.Name == "notepad.store" {
+			found = true
+			if rt.Handler != "notepad.Store" {
+				t.Errorf("\n got: %v\nwant: %v", rt.Handler, "notepad.Store")
+			}
+			if !rt.CSRF {
+				t.Error("notepad.store should be checked for a CSRF token")
+			}
+		}
+	}
+	if !found {
+		t.Fatal("notepad.store route is missing")
+	}
+	if !openapi {
+		t.Error("openapi route from the settings is missing")
+	}
+
+	// The public forms fail the audit unless they are allowed
+	router.ResetConfig()
+	buf.Reset()
+	if err := routes.Run(buf, []string{"-audit", "-config", "../../env.json.example"}); err != routes.ErrAudit {
+		t.Fatalf("\n got: %v\nwant: %v", err, routes.ErrAudit)
+	}
+	if !strings.Contains(buf.String(), "login.store") {
+		t.Errorf("audit should list login.store:\n%v", buf.String())
+	}
+
+	router.ResetConfig()
+	buf.Reset()
+	if err := routes.Run(buf, []string{"-audit", "-config", "../../env.json.example", "-allow", "login.store,register.store,password.forgot.store,password.reset.update,register.resend.store,login.twofactor.store,api.tokens.store"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRunOpenAPI ensures the API document is written.
+func TestRunOpenAPI(t *testing.T) {
+	// Reset the router
+	router.ResetConfig()
+
+	buf := new(bytes.Buffer)
+	if err := routes.Run(buf, []string{"-openapi", "-config", "../../env.json.example"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := doc.Paths["/api/v1/notes/{id}"]["patch"]; !ok {
+		t.Errorf("PATCH /api/v1/notes/{id} is missing:\n%v", buf.String())
+	}
+}
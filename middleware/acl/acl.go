@@ -1,9 +1,11 @@
 // Package acl provides http.Handlers to prevent access to pages for
-// authenticated users and for non-authenticated users.
+// authenticated users, for non-authenticated users, and for users who are
+// not admins.
 package acl
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/pcieslar/goforge/lib/flight"
 )
@@ -37,3 +39,35 @@ func DisallowAnon(h http.Handler) http.Handler {
 		h.ServeHTTP(w, r)
 	})
 }
+
+// DisallowNonAdmin only allows the users with an email address in the Admins
+// list of the config to access the page.
+func DisallowNonAdmin(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := flight.Context(w, r)
+
+		// If user is not an admin, don't allow them to access the page
+		if !IsAdmin(c.Config.Admins, c.Sess.Values["email"]) {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// IsAdmin returns true if the email from the session is in the list.
+func IsAdmin(admins []string, email interface{}) bool {
+	s, ok := email.(string)
+	if !ok || len(s) == 0 {
+		return false
+	}
+
+	for _, v := range admins {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
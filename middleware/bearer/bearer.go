@@ -0,0 +1,95 @@
+// Package bearer provides an http.Handler that authenticates API requests
+// with a token from the Authorization header instead of the session.
+package bearer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/pcieslar/goforge/controller/status"
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/apitoken"
+	"github.com/pcieslar/goforge/model/user"
+	"github.com/pcieslar/goforge/model/userstatus"
+)
+
+// contextKey is the type of the key for the token.
+type contextKey int
+
+// tokenKey is the request context key for the token.
+const tokenKey contextKey = 0
+
+// Handler only allows requests with a valid bearer token of an active user.
+// The user ID of the token owner is available from flight.Context.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := flight.Context(w, r)
+
+		token, ok := parse(r.Header.Get("Authorization"))
+		if !ok {
+			unauthorized(w, "Bearer token is missing.")
+			return
+		}
+
+		if c.GORM == nil {
+			log.Println("Bearer token could not be checked without a database connection")
+			status.WriteError(w, http.StatusInternalServerError, "An internal server error occurred.")
+			return
+		}
+
+		item, err := apitoken.ByToken(c.GORM, token)
+		if err == model.ErrNoResult {
+			unauthorized(w, "Bearer token is not valid.")
+			return
+		} else if err != nil {
+			log.Println("Bearer token lookup failed:", err)
+			status.WriteError(w, http.StatusInternalServerError, "An internal server error occurred.")
+			return
+		}
+
+		// The account can be deactivated after the token was issued
+		u, err := user.ByID(c.GORM, item.UserID)
+		if err == model.ErrNoResult {
+			unauthorized(w, "Bearer token is not valid.")
+			return
+		} else if err != nil {
+			log.Println("Bearer token user lookup failed:", err)
+			status.WriteError(w, http.StatusInternalServerError, "An internal server error occurred.")
+			return
+		} else if u.StatusID != userstatus.Active {
+			unauthorized(w, "Account is inactive so login is disabled.")
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), tokenKey, item))
+		next.ServeHTTP(w, flight.WithUserID(r, fmt.Sprintf("%v", item.UserID)))
+	})
+}
+
+// Token returns the token that authenticated the request.
+func Token(r *http.Request) (apitoken.APIToken, bool) {
+	item, ok := r.Context().Value(tokenKey).(apitoken.APIToken)
+	return item, ok
+}
+
+// parse returns the token from an Authorization header value.
+func parse(header string) (string, bool) {
+	const prefix = "bearer "
+
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, len(token) > 0
+}
+
+// unauthorized writes the error with the authentication challenge.
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	status.WriteError(w, http.StatusUnauthorized, message)
+}
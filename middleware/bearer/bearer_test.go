@@ -0,0 +1,128 @@
+package bearer_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/env"
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/lib/gorm"
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/sqlite"
+	"github.com/pcieslar/goforge/middleware/bearer"
+	"github.com/pcieslar/goforge/model/apitoken"
+	"github.com/pcieslar/goforge/model/user"
+)
+
+// setup returns the handler that requires a token and the database with a
+// user.
+func setup(t *testing.T) (http.Handler, *gorm.DB, user.User) {
+	db, err := gorm.Open("sqlite3", t.TempDir()+"/test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, q := range []string{
+		`CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, first_name TEXT, last_name TEXT, email TEXT, password TEXT, status_id INT, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`,
+		`CREATE TABLE api_token (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, token_hash TEXT UNIQUE, user_id INT, expires_at TIMESTAMP, created_at TIMESTAMP, updated_at TIMESTAMP, deleted_at TIMESTAMP)`,
+	} {
+		if err := db.Exec(q).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := user.Create(db, "John", "Doe", "jdoe@domain.com", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := user.ByEmail(db, "jdoe@domain.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := env.LoadConfig("../../env.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := config.Session.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	app := flight.New(config)
+	app.GORM = db
+
+	handler := app.Handler(bearer.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		item, ok := bearer.Token(r)
+		if !ok || flight.Context(w, r).UserID != "1" || item.UserID != 1 {
+			t.Errorf("\nactual: %v, %v\nexpected: token of user 1", item, ok)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	return handler, db, u
+}
+
+// get makes a request with the Authorization header.
+func get(h http.Handler, authorization string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/notes", nil)
+	if len(authorization) > 0 {
+		r.Header.Set("Authorization", authorization)
+	}
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// TestHandler ensures only a valid token of an active user is allowed.
+func TestHandler(t *testing.T) {
+	h, db, u := setup(t)
+
+	token, item, err := apitoken.Create(db, "Test", u.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _, err := apitoken.Create(db, "Old", u.ID, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := get(h, "Bearer "+token); w.Code != http.StatusNoContent {
+		t.Fatalf("\nactual: %v\nexpected: %v", w.Code, http.StatusNoContent)
+	}
+
+	for name, authorization := range map[string]string{
+		"missing": "",
+		"scheme":  "Basic " + token,
+		"bad":     "Bearer not-a-token",
+		"expired": "Bearer " + expired,
+	} {
+		w := get(h, authorization)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", name, w.Code, http.StatusUnauthorized)
+		}
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%v: challenge is missing", name)
+		}
+	}
+
+	// A deactivated account loses access
+	if err := db.Exec("UPDATE user SET status_id = 2 WHERE id = ?", u.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if w := get(h, "Bearer "+token); w.Code != http.StatusUnauthorized {
+		t.Errorf("inactive: \nactual: %v\nexpected: %v", w.Code, http.StatusUnauthorized)
+	}
+	if err := db.Exec("UPDATE user SET status_id = 1 WHERE id = ?", u.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// A revoked token is not found
+	if err := apitoken.DeleteSoft(db, item.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(h, "Bearer "+token); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked: \nactual: %v\nexpected: %v", w.Code, http.StatusUnauthorized)
+	}
+}
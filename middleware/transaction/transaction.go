@@ -0,0 +1,314 @@
+// Package transaction provides an http.Handler that wraps a request in a
+// database transaction. The transaction is committed when the response status
+// is 2xx or 3xx and rolled back on a panic, a 4xx or 5xx status, or when an
+// error flash is added during the request.
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/lib/gorm"
+
+	"github.com/pcieslar/goforge/core/flash"
+
+	"github.com/gorilla/sessions"
+)
+
+var (
+	// ErrNoTransaction is when the request is not wrapped in a transaction.
+	ErrNoTransaction = errors.New("Request does not have an active transaction.")
+	// ErrSavepointName is when a savepoint name contains invalid characters.
+	ErrSavepointName = errors.New("Savepoint name must only contain letters, numbers, and underscores.")
+
+	savepointName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// contextKey is the type of the key for the transaction state.
+type contextKey int
+
+// stateKey is the request context key for the transaction state.
+const stateKey contextKey = 0
+
+// state tracks the transaction for a request.
+type state struct {
+	db    *gorm.DB // Connection before the transaction started
+	tx    *gorm.DB
+	skip  bool
+	after []func() // Run once the transaction commits
+}
+
+// recorder holds the response of the handler until the transaction ends so
+// the client does not see a success page for changes that were not saved.
+type recorder struct {
+	w        http.ResponseWriter
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	sent     bool // Response was flushed to the client by the handler
+	hijacked bool
+}
+
+// newRecorder returns a recorder for the response writer.
+func newRecorder(w http.ResponseWriter) *recorder {
+	return &recorder{
+		w:      w,
+		header: make(http.Header),
+	}
+}
+
+// Header returns the header held until the response is sent.
+func (w *recorder) Header() http.Header {
+	if w.sent {
+		return w.w.Header()
+	}
+	return w.header
+}
+
+// WriteHeader records the status code.
+func (w *recorder) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	if w.sent {
+		w.w.WriteHeader(code)
+	}
+}
+
+// Write holds the body and records an implicit 200 status.
+func (w *recorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.sent {
+		return w.w.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+// Flush sends the response so far and streams the rest. A commit that fails
+// after the response is flushed can only be logged.
+func (w *recorder) Flush() {
+	w.send()
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the handler take over the connection.
+func (w *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// succeeded returns true if the status is 2xx or 3xx. A handler that writes
+// nothing sends an implicit 200.
+func (w *recorder) succeeded() bool {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return status >= 200 && status < 400
+}
+
+// send writes the held header, status, and body to the client.
+func (w *recorder) send() {
+	if w.sent || w.hijacked {
+		return
+	}
+	w.sent = true
+
+	dst := w.w.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+
+	// Headers can still be added if the handler has not written yet
+	w.header = dst
+	if w.status == 0 {
+		return
+	}
+
+	w.w.WriteHeader(w.status)
+	w.w.Write(w.body.Bytes())
+	w.body.Reset()
+}
+
+// Handler begins a transaction and makes it available through
+// flight.Context for the rest of the request. The response is held until
+// the transaction ends and a 500 is sent instead if the commit fails.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := flight.Context(w, r)
+
+		// Nothing to wrap without a database connection
+		if c.GORM == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tx := c.GORM.Begin()
+		if tx.Error != nil {
+			log.Println("Transaction could not begin:", tx.Error)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		s := &state{
+			db: c.GORM,
+			tx: tx,
+		}
+
+		before := flashes(c.Sess)
+		rec := newRecorder(w)
+
+		// Roll back and continue panicking so the panic is still handled
+		defer func() {
+			if p := recover(); p != nil {
+				rollback(s)
+				panic(p)
+			}
+		}()
+
+		r = r.WithContext(context.WithValue(r.Context(), stateKey, s))
+		next.ServeHTTP(rec, flight.WithGORM(r, tx))
+
+		if s.skip || !rec.succeeded() || countErrorFlashes(flashes(c.Sess)) > countErrorFlashes(before) {
+			rollback(s)
+			rec.send()
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			log.Println("Transaction could not commit:", err)
+
+			// Too late to change the response
+			if rec.sent || rec.hijacked {
+				return
+			}
+
+			// Remove the flashes that report the changes as saved
+			if c.Sess != nil {
+				if len(before) > 0 {
+					c.Sess.Values["_flash"] = before
+				} else {
+					delete(c.Sess.Values, "_flash")
+				}
+				c.Sess.Save(r, w)
+			}
+
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		for _, fn := range s.after {
+			fn()
+		}
+
+		rec.send()
+	})
+}
+
+// AfterCommit runs fn once the transaction commits so work outside the
+// database, like logging out sessions, is not done for changes that are
+// rolled back. Without a transaction fn runs right away.
+func AfterCommit(r *http.Request, fn func()) {
+	s, ok := r.Context().Value(stateKey).(*state)
+	if !ok || s.skip {
+		fn()
+		return
+	}
+
+	s.after = append(s.after, fn)
+}
+
+// Skip opts the route out of the transaction. Queries made through
+// flight.Context use the connection without the transaction.
+func Skip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := r.Context().Value(stateKey).(*state)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.skip = true
+		next.ServeHTTP(w, flight.WithGORM(r, s.db))
+	})
+}
+
+// Savepoint marks a point in the transaction that can be rolled back to
+// without discarding the whole transaction.
+func Savepoint(r *http.Request, name string) error {
+	return exec(r, "SAVEPOINT ", name)
+}
+
+// RollbackTo discards the changes made after the savepoint.
+func RollbackTo(r *http.Request, name string) error {
+	return exec(r, "ROLLBACK TO SAVEPOINT ", name)
+}
+
+// Release removes the savepoint and keeps the changes made after it.
+func Release(r *http.Request, name string) error {
+	return exec(r, "RELEASE SAVEPOINT ", name)
+}
+
+// exec runs a savepoint statement on the request transaction.
+func exec(r *http.Request, statement, name string) error {
+	s, ok := r.Context().Value(stateKey).(*state)
+	if !ok || s.skip {
+		return ErrNoTransaction
+	}
+
+	if !savepointName.MatchString(name) {
+		return ErrSavepointName
+	}
+
+	return s.tx.Exec(statement + name).Error
+}
+
+// rollback discards the transaction and logs any error.
+func rollback(s *state) {
+	if err := s.tx.Rollback().Error; err != nil {
+		log.Println("Transaction could not roll back:", err)
+	}
+}
+
+// flashes returns a copy of the flashes in the session without removing
+// them.
+func flashes(sess *sessions.Session) []interface{} {
+	if sess == nil {
+		return nil
+	}
+
+	list, _ := sess.Values["_flash"].([]interface{})
+	return append([]interface{}(nil), list...)
+}
+
+// countErrorFlashes returns the number of error flashes.
+func countErrorFlashes(flashes []interface{}) int {
+	count := 0
+	for _, f := range flashes {
+		if fi, ok := f.(flash.Info); ok && fi.Class == flash.Error {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,311 @@
+package transaction_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pcieslar/goforge/lib/env"
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/lib/gorm"
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/sqlite"
+	"github.com/pcieslar/goforge/middleware/transaction"
+
+	"github.com/pcieslar/goforge/core/flash"
+)
+
+// setup returns the app and the database for the tests. Foreign keys are
+// checked when the transaction commits so a commit can fail.
+func setup(t *testing.T) (*flight.App, *gorm.DB) {
+	db, err := gorm.Open("sqlite3", t.TempDir()+"/test.db?_busy_timeout=5000&_foreign_keys=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, q := range []string{
+		`CREATE TABLE parent (id INTEGER PRIMARY KEY)`,
+		`CREATE TABLE item (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parent (id) DEFERRABLE INITIALLY DEFERRED)`,
+	} {
+		if err := db.Exec(q).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config, err := env.LoadConfig("../../env.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := config.Session.SetupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	app := flight.New(config)
+	app.GORM = db
+
+	return app, db
+}
+
+// insert adds an item through the request transaction.
+func insert(t *testing.T, r *http.Request, id int) {
+	if err := flight.Context(nil, r).GORM.Exec("INSERT INTO item (id) VALUES (?)", id).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// count returns the number of items.
+func count(t *testing.T, db *gorm.DB) int {
+	var n int
+	if err := db.Raw("SELECT COUNT(*) FROM item").Row().Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// serve runs the handler wrapped in the transaction.
+func serve(app *flight.App, h http.HandlerFunc) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/item", nil)
+	app.Handler(transaction.Handler(h)).ServeHTTP(w, r)
+	return w
+}
+
+// TestCommit ensures the changes are saved for 2xx and 3xx responses.
+func TestCommit(t *testing.T) {
+	app, db := setup(t)
+
+	w := serve(app, func(w http.ResponseWriter, r *http.Request) {
+		insert(t, r, 1)
+		http.Redirect(w, r, "/item", http.StatusFound)
+	})
+
+	if w.Code != http.StatusFound {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Code, http.StatusFound)
+	}
+	if w.Header().Get("Location") != "/item" {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "/item")
+	}
+	if n := count(t, db); n != 1 {
+		t.Errorf("\nactual: %v\nexpected: %v", n, 1)
+	}
+}
+
+// TestRollback ensures the changes are discarded for 4xx and 5xx responses.
+func TestRollback(t *testing.T) {
+	app, db := setup(t)
+
+	for _, code := range []int{http.StatusBadRequest, http.StatusInternalServerError} {
+		w := serve(app, func(w http.ResponseWriter, r *http.Request) {
+			insert(t, r, code)
+			http.Error(w, "failed", code)
+		})
+
+		if w.Code != code {
+			t.Errorf("\nactual: %v\nexpected: %v", w.Code, code)
+		}
+		if w.Body.String() != "failed\n" {
+			t.Errorf("\nactual: %q\nexpected: %q", w.Body.String(), "failed\n")
+		}
+	}
+
+	if n := count(t, db); n != 0 {
+		t.Errorf("\nactual: %v\nexpected: %v", n, 0)
+	}
+}
+
+// TestRollbackErrorFlash ensures the changes are discarded when an error
+// flash is added.
+func TestRollbackErrorFlash(t *testing.T) {
+	app, db := setup(t)
+
+	serve(app, func(w http.ResponseWriter, r *http.Request) {
+		insert(t, r, 1)
+		c := flight.Context(w, r)
+		c.Sess.AddFlash(flash.Info{Message: "Item could not be added.", Class: flash.Error})
+		http.Redirect(w, r, "/item", http.StatusFound)
+	})
+
+	if n := count(t, db); n != 0 {
+		t.Errorf("\nactual: %v\nexpected: %v", n, 0)
+	}
+}
+
+// TestCommitFailed ensures the response of the handler is replaced by a 500
+// and its flashes are removed when the commit fails.
+func TestCommitFailed(t *testing.T) {
+	app, db := setup(t)
+
+	var stored flight.Info
+	w := serve(app, func(w http.ResponseWriter, r *http.Request) {
+		if err := flight.Context(w, r).GORM.Exec("INSERT INTO item (id, parent_id) VALUES (1, 5)").Error; err != nil {
+			t.Fatal(err)
+		}
+		stored = flight.Context(w, r)
+		stored.Sess.AddFlash(flash.Info{Message: "Item added.", Class: flash.Success})
+		stored.Sess.Save(r, w)
+		http.Redirect(w, r, "/item", http.StatusFound)
+	})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Code, http.StatusInternalServerError)
+	}
+	if w.Header().Get("Location") != "" {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Header().Get("Location"), "")
+	}
+	if _, ok := stored.Sess.Values["_flash"]; ok {
+		t.Error("success flash should be removed")
+	}
+	if n := count(t, db); n != 0 {
+		t.Errorf("\nactual: %v\nexpected: %v", n, 0)
+	}
+}
+
+// TestAfterCommit ensures the funcs only run when the transaction commits.
+func TestAfterCommit(t *testing.T) {
+	app, _ := setup(t)
+
+	for code, expected := range map[int]bool{
+		http.StatusFound:      true,
+		http.StatusBadRequest: false,
+	} {
+		ran := false
+		serve(app, func(w http.ResponseWriter, r *http.Request) {
+			insert(t, r, code)
+			transaction.AfterCommit(r, func() { ran = true })
+			if ran {
+				t.Error("should not run before the commit")
+			}
+			w.WriteHeader(code)
+		})
+
+		if ran != expected {
+			t.Errorf("%v: \nactual: %v\nexpected: %v", code, ran, expected)
+		}
+	}
+
+	// The commit fails so the func is not run
+	ran := false
+	serve(app, func(w http.ResponseWriter, r *http.Request) {
+		if err := flight.Context(w, r).GORM.Exec("INSERT INTO item (id, parent_id) VALUES (2, 5)").Error; err != nil {
+			t.Fatal(err)
+		}
+		transaction.AfterCommit(r, func() { ran = true })
+	})
+	if ran {
+		t.Error("should not run when the commit fails")
+	}
+}
+
+// TestSkip ensures a skipped route writes without the transaction.
+func TestSkip(t *testing.T) {
+	app, db := setup(t)
+
+	w := serve(app, transaction.Skip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		insert(t, r, 1)
+		if err := transaction.Savepoint(r, "before"); err != transaction.ErrNoTransaction {
+			t.Errorf("\nactual: %v\nexpected: %v", err, transaction.ErrNoTransaction)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	})).ServeHTTP)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("\nactual: %v\nexpected: %v", w.Code, http.StatusInternalServerError)
+	}
+	if n := count(t, db); n != 1 {
+		t.Errorf("\nactual: %v\nexpected: %v", n, 1)
+	}
+}
+
+// TestSavepoint ensures the changes after a savepoint can be discarded
+// while the rest of the transaction is kept.
+func TestSavepoint(t *testing.T) {
+	app, db := setup(t)
+
+	serve(app, func(w http.ResponseWriter, r *http.Request) {
+		insert(t, r, 1)
+		if err := transaction.Savepoint(r, "second"); err != nil {
+			t.Fatal(err)
+		}
+		insert(t, r, 2)
+		if err := transaction.RollbackTo(r, "second"); err != nil {
+			t.Fatal(err)
+		}
+		if err := transaction.Release(r, "second"); err != nil {
+			t.Fatal(err)
+		}
+		if err := transaction.Savepoint(r, "bad name"); err != transaction.ErrSavepointName {
+			t.Errorf("\nactual: %v\nexpected: %v", err, transaction.ErrSavepointName)
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	var ids []int
+	if err := db.Raw("SELECT id FROM item").Pluck("id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("\nactual: %v\nexpected: %v", ids, []int{1})
+	}
+}
+
+// TestPanic ensures the changes are discarded and the panic continues.
+func TestPanic(t *testing.T) {
+	app, db := setup(t)
+
+	errPanic := errors.New("handler failed")
+
+	func() {
+		defer func() {
+			if p := recover(); p != errPanic {
+				t.Errorf("\nactual: %v\nexpected: %v", p, errPanic)
+			}
+		}()
+
+		serve(app, func(w http.ResponseWriter, r *http.Request) {
+			insert(t, r, 1)
+			panic(errPanic)
+		})
+	}()
+
+	if n := count(t, db); n != 0 {
+		t.Errorf("\nactual: %v\nexpected: %v", n, 0)
+	}
+}
+
+// TestFlusherHijacker ensures the handler can still flush and hijack the
+// response.
+func TestFlusherHijacker(t *testing.T) {
+	app, db := setup(t)
+
+	w := serve(app, func(w http.ResponseWriter, r *http.Request) {
+		insert(t, r, 1)
+
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("response should implement http.Flusher")
+		}
+		w.Write([]byte("first"))
+		f.Flush()
+
+		h, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("response should implement http.Hijacker")
+		}
+		if _, _, err := h.Hijack(); err != http.ErrNotSupported {
+			t.Errorf("\nactual: %v\nexpected: %v", err, http.ErrNotSupported)
+		}
+		w.Write([]byte(" second"))
+	})
+
+	if !w.Flushed {
+		t.Error("response should be flushed")
+	}
+	if w.Body.String() != "first second" {
+		t.Errorf("\nactual: %q\nexpected: %q", w.Body.String(), "first second")
+	}
+	if n := count(t, db); n != 1 {
+		t.Errorf("\nactual: %v\nexpected: %v", n, 1)
+	}
+}
@@ -0,0 +1,26 @@
+# ******************************************************************************
+# Settings
+# ******************************************************************************
+SET foreign_key_checks = 1;
+SET time_zone = '+00:00';
+
+# ******************************************************************************
+# Create tables
+# ******************************************************************************
+CREATE TABLE session (
+    id VARCHAR(64) NOT NULL,
+    
+    user_id VARCHAR(20) NOT NULL DEFAULT '',
+    data MEDIUMTEXT NOT NULL,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL DEFAULT NULL,
+    expires_at TIMESTAMP NULL DEFAULT NULL,
+    
+    KEY (user_id),
+    KEY (expires_at),
+    
+    PRIMARY KEY (id)
+);
@@ -0,0 +1,27 @@
+# ******************************************************************************
+# Settings
+# ******************************************************************************
+SET foreign_key_checks = 1;
+SET time_zone = '+00:00';
+
+# ******************************************************************************
+# Create tables
+# ******************************************************************************
+CREATE TABLE password_reset (
+    id INT(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+    
+    token_hash CHAR(64) NOT NULL,
+    
+    user_id INT(10) UNSIGNED NOT NULL,
+    
+    expires_at TIMESTAMP NULL DEFAULT NULL,
+    
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL DEFAULT NULL,
+    
+    UNIQUE KEY (token_hash),
+    CONSTRAINT `f_password_reset_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    
+    PRIMARY KEY (id)
+);
@@ -0,0 +1,10 @@
+# ******************************************************************************
+# Settings
+# ******************************************************************************
+SET foreign_key_checks = 1;
+
+# ******************************************************************************
+# Remove rows
+# ******************************************************************************
+UPDATE `user` SET `status_id` = 1 WHERE `status_id` = 3;
+DELETE FROM `user_status` WHERE `id` = 3;
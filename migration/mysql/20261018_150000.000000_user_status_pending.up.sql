@@ -0,0 +1,11 @@
+# ******************************************************************************
+# Settings
+# ******************************************************************************
+SET foreign_key_checks = 1;
+SET time_zone = '+00:00';
+
+# ******************************************************************************
+# Insert rows
+# ******************************************************************************
+INSERT INTO `user_status` (`id`, `status`, `created_at`, `updated_at`, `deleted_at`) VALUES
+(3, 'pending',  CURRENT_TIMESTAMP,  NULL,  NULL);
@@ -0,0 +1,38 @@
+# ******************************************************************************
+# Settings
+# ******************************************************************************
+SET foreign_key_checks = 1;
+SET time_zone = '+00:00';
+
+# ******************************************************************************
+# Create tables
+# ******************************************************************************
+CREATE TABLE login_attempt (
+    attempt_key VARCHAR(191) NOT NULL,
+    
+    failures INT(10) UNSIGNED NOT NULL DEFAULT 0,
+    
+    last_failure TIMESTAMP NULL DEFAULT NULL,
+    locked_until TIMESTAMP NULL DEFAULT NULL,
+    expires_at TIMESTAMP NULL DEFAULT NULL,
+    
+    KEY (locked_until),
+    KEY (expires_at),
+    
+    PRIMARY KEY (attempt_key)
+);
+
+CREATE TABLE audit_log (
+    id INT(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+    
+    event VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    actor VARCHAR(100) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    
+    KEY (event),
+    
+    PRIMARY KEY (id)
+);
@@ -0,0 +1,42 @@
+# ******************************************************************************
+# Settings
+# ******************************************************************************
+SET foreign_key_checks = 1;
+SET time_zone = '+00:00';
+
+# ******************************************************************************
+# Create tables
+# ******************************************************************************
+CREATE TABLE two_factor (
+    id INT(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+    
+    user_id INT(10) UNSIGNED NOT NULL,
+    
+    secret VARCHAR(64) NOT NULL,
+    last_counter BIGINT NOT NULL DEFAULT 0,
+    enabled_at TIMESTAMP NULL DEFAULT NULL,
+    
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP,
+    
+    UNIQUE KEY (user_id),
+    CONSTRAINT `f_two_factor_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE recovery_code (
+    id INT(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+    
+    user_id INT(10) UNSIGNED NOT NULL,
+    
+    code_hash CHAR(60) NOT NULL,
+    
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL DEFAULT NULL,
+    
+    CONSTRAINT `f_recovery_code_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    
+    PRIMARY KEY (id)
+);
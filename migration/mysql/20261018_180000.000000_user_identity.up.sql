@@ -0,0 +1,26 @@
+# ******************************************************************************
+# Settings
+# ******************************************************************************
+SET foreign_key_checks = 1;
+SET time_zone = '+00:00';
+
+# ******************************************************************************
+# Create tables
+# ******************************************************************************
+CREATE TABLE user_identity (
+    id INT(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+    
+    user_id INT(10) UNSIGNED NOT NULL,
+    
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(191) NOT NULL,
+    
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP,
+    
+    UNIQUE KEY (provider, subject),
+    UNIQUE KEY (user_id, provider),
+    CONSTRAINT `f_user_identity_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    
+    PRIMARY KEY (id)
+);
@@ -0,0 +1,4 @@
+-- *****************************************************************************
+-- Remove tables
+-- *****************************************************************************
+DROP TABLE IF EXISTS session;
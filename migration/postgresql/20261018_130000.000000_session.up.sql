@@ -0,0 +1,25 @@
+-- *****************************************************************************
+-- Settings
+-- *****************************************************************************
+SET TIME ZONE 'UTC';
+
+-- *****************************************************************************
+-- Create tables
+-- *****************************************************************************
+CREATE TABLE session (
+    id VARCHAR(64) NOT NULL,
+    
+    user_id VARCHAR(20) NOT NULL DEFAULT '',
+    data TEXT NOT NULL,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL DEFAULT NULL,
+    expires_at TIMESTAMP NULL DEFAULT NULL,
+    
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX session_user_id ON session (user_id);
+CREATE INDEX session_expires_at ON session (expires_at);
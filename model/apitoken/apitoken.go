@@ -0,0 +1,84 @@
+// Package apitoken provides access to the api_token table in the MySQL
+// database. Only the SHA-256 hash of each token is stored and each token
+// expires after its lifetime.
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/gorm"
+	"github.com/pcieslar/goforge/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// tokenBytes is the number of random bytes in a token.
+const tokenBytes = 32
+
+// APIToken table.
+type APIToken struct {
+	ID        uint32         `db:"id"`
+	Name      string         `db:"name"`
+	TokenHash string         `db:"token_hash"`
+	UserID    uint32         `db:"user_id"`
+	ExpiresAt time.Time      `db:"expires_at"`
+	CreatedAt mysql.NullTime `db:"created_at"`
+	UpdatedAt mysql.NullTime `db:"updated_at"`
+	DeletedAt mysql.NullTime `db:"deleted_at"`
+}
+
+// TableName for api_token table.
+func (APIToken) TableName() string {
+	return "api_token"
+}
+
+// Hash returns the hex encoded SHA-256 hash of the token.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create issues a new token for the user that expires after the lifetime.
+// The plain text token is only available from the return value.
+func Create(db *gorm.DB, name string, userID uint32, lifetime time.Duration) (string, APIToken, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", APIToken{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	item := APIToken{
+		Name:      name,
+		TokenHash: Hash(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(lifetime),
+	}
+
+	err := model.StandardError(db.Create(&item).Error)
+	return token, item, err
+}
+
+// ByToken gets the token from the plain text value if it has not expired.
+func ByToken(db *gorm.DB, token string) (APIToken, error) {
+	result := APIToken{}
+	return result, model.StandardError(db.Where("token_hash = ?", Hash(token)).
+		Where("expires_at > ?", time.Now()).
+		First(&result).Error)
+}
+
+// DeleteSoft revokes a token.
+func DeleteSoft(db *gorm.DB, ID uint32, userID uint32) error {
+	return model.StandardError(db.Where("user_id = ?", userID).
+		Where("id = ?", ID).Delete(APIToken{}).Error)
+}
+
+// DeleteByUser revokes every token of the user. Call it when the password
+// is reset so a stolen token stops working.
+func DeleteByUser(db *gorm.DB, userID uint32) error {
+	return model.StandardError(db.Where("user_id = ?", userID).
+		Delete(APIToken{}).Error)
+}
@@ -0,0 +1,112 @@
+package apitoken_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/gorm"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/apitoken"
+	"github.com/pcieslar/goforge/model/user"
+
+	"github.com/pcieslar/goforge/core/storage/migration/mysql"
+
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	db  *sqlx.DB
+	gdb *gorm.DB
+)
+
+// TestMain runs setup, tests, and then teardown.
+func TestMain(m *testing.M) {
+	setup()
+	returnCode := m.Run()
+	teardown()
+	os.Exit(returnCode)
+}
+
+// setup handles any start up tasks.
+func setup() {
+	_, conf := mysql.SetUp("../../env.json.example", "database_test")
+
+	// Connect to the database
+	db, _ = conf.Connect(true)
+
+	// Share the connection with GORM
+	gdb, _ = gorm.Open("mysql", db.DB)
+}
+
+// teardown handles any clean up tasks.
+func teardown() {
+	mysql.TearDown(db, "database_test")
+}
+
+// TestComplete
+func TestComplete(t *testing.T) {
+	err := user.Create(gdb, "John", "Doe", "jdoe@domain.com", "p@$$W0rD")
+	if err != nil {
+		t.Error("could not create user:", err)
+	}
+
+	u, err := user.ByEmail(gdb, "jdoe@domain.com")
+	if err != nil {
+		t.Fatal("could not retrieve user:", err)
+	}
+
+	// Issue a token
+	token, item, err := apitoken.Create(gdb, "Mobile", u.ID, time.Hour)
+	if err != nil {
+		t.Fatal("could not create token:", err)
+	}
+
+	// Only the hash should be stored
+	if item.TokenHash == token || item.TokenHash != apitoken.Hash(token) {
+		t.Errorf("token should be stored hashed: got '%v'", item.TokenHash)
+	}
+
+	// Find the token
+	found, err := apitoken.ByToken(gdb, token)
+	if err != nil {
+		t.Fatal("could not retrieve token:", err)
+	} else if found.UserID != u.ID {
+		t.Errorf("retrieved wrong token: got '%v' want '%v'", found.UserID, u.ID)
+	}
+
+	// An expired token should not be found
+	expired, _, err := apitoken.Create(gdb, "Old", u.ID, -time.Minute)
+	if err != nil {
+		t.Fatal("could not create token:", err)
+	}
+	if _, err := apitoken.ByToken(gdb, expired); err != model.ErrNoResult {
+		t.Error("token should be expired:", err)
+	}
+
+	// Revoke the token
+	err = apitoken.DeleteSoft(gdb, found.ID, u.ID)
+	if err != nil {
+		t.Error("could not delete token:", err)
+	}
+
+	// The token should no longer be found
+	_, err = apitoken.ByToken(gdb, token)
+	if err != model.ErrNoResult {
+		t.Error("token should be deleted:", err)
+	}
+
+	// Revoke every token of the user
+	other, _, err := apitoken.Create(gdb, "Laptop", u.ID, time.Hour)
+	if err != nil {
+		t.Fatal("could not create token:", err)
+	}
+	if err := apitoken.DeleteByUser(gdb, u.ID); err != nil {
+		t.Error("could not delete tokens:", err)
+	}
+	if _, err := apitoken.ByToken(gdb, other); err != model.ErrNoResult {
+		t.Error("tokens of the user should be deleted:", err)
+	}
+}
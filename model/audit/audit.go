@@ -0,0 +1,50 @@
+// Package audit provides access to the audit_log table in the MySQL database.
+// The table records security events like lockouts so they can be reviewed.
+package audit
+
+import (
+	"github.com/pcieslar/goforge/lib/gorm"
+	"github.com/pcieslar/goforge/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// The events in the audit log.
+const (
+	EventLockout = "lockout" // Too many failed logins locked an account or IP address
+	EventUnlock  = "unlock"  // An admin removed a lockout
+)
+
+// Audit table.
+type Audit struct {
+	ID        uint32         `db:"id"`
+	Event     string         `db:"event"`
+	Subject   string         `db:"subject"`
+	Actor     string         `db:"actor"`
+	IP        string         `db:"ip"`
+	CreatedAt mysql.NullTime `db:"created_at"`
+}
+
+// TableName for audit_log table.
+func (Audit) TableName() string {
+	return "audit_log"
+}
+
+// Create writes an event about the subject, like the locked account. The
+// actor is the email of the user who caused it or empty for the application.
+func Create(db *gorm.DB, event, subject, actor, ip string) error {
+	item := &Audit{
+		Event:   event,
+		Subject: subject,
+		Actor:   actor,
+		IP:      ip,
+	}
+	return model.StandardError(db.Create(item).Error)
+}
+
+// Recent gets the latest events first.
+func Recent(db *gorm.DB, limit int) ([]Audit, error) {
+	var result []Audit
+	err := db.Order("id desc").Limit(limit).Find(&result).Error
+	return result, model.StandardError(err)
+}
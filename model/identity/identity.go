@@ -0,0 +1,60 @@
+// Package identity provides access to the user_identity table in the MySQL
+// database. Each row links the subject of an external login provider to a
+// user.
+package identity
+
+import (
+	"github.com/pcieslar/goforge/lib/gorm"
+	"github.com/pcieslar/goforge/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Identity table.
+type Identity struct {
+	ID        uint32         `db:"id"`
+	UserID    uint32         `db:"user_id"`
+	Provider  string         `db:"provider"`
+	Subject   string         `db:"subject"`
+	CreatedAt mysql.NullTime `db:"created_at"`
+	UpdatedAt mysql.NullTime `db:"updated_at"`
+}
+
+// TableName for user_identity table.
+func (Identity) TableName() string {
+	return "user_identity"
+}
+
+// BySubject gets the link of the subject at the provider.
+func BySubject(db *gorm.DB, provider, subject string) (Identity, error) {
+	result := Identity{}
+	return result, model.StandardError(db.Where("provider = ?", provider).
+		Where("subject = ?", subject).
+		First(&result).Error)
+}
+
+// ByUserID gets the links of the user.
+func ByUserID(db *gorm.DB, userID uint32) ([]Identity, error) {
+	var result []Identity
+	return result, model.StandardError(db.Where("user_id = ?", userID).
+		Order("provider").
+		Find(&result).Error)
+}
+
+// Create links the subject at the provider to the user. A subject can only
+// be linked to one user and a user can only have one link for each provider.
+func Create(db *gorm.DB, userID uint32, provider, subject string) error {
+	item := &Identity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+	}
+	return model.StandardError(db.Create(item).Error)
+}
+
+// Delete removes the link of the user for the provider.
+func Delete(db *gorm.DB, userID uint32, provider string) error {
+	return model.StandardError(db.Where("user_id = ?", userID).
+		Where("provider = ?", provider).
+		Delete(Identity{}).Error)
+}
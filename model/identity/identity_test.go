@@ -0,0 +1,93 @@
+package identity_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pcieslar/goforge/lib/gorm"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/identity"
+	"github.com/pcieslar/goforge/model/user"
+
+	"github.com/pcieslar/goforge/core/storage/migration/mysql"
+
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	db  *sqlx.DB
+	gdb *gorm.DB
+)
+
+// TestMain runs setup, tests, and then teardown.
+func TestMain(m *testing.M) {
+	setup()
+	returnCode := m.Run()
+	teardown()
+	os.Exit(returnCode)
+}
+
+// setup handles any start up tasks.
+func setup() {
+	_, conf := mysql.SetUp("../../env.json.example", "database_test")
+
+	// Connect to the database
+	db, _ = conf.Connect(true)
+
+	// Share the connection with GORM
+	gdb, _ = gorm.Open("mysql", db.DB)
+}
+
+// teardown handles any clean up tasks.
+func teardown() {
+	mysql.TearDown(db, "database_test")
+}
+
+// TestComplete
+func TestComplete(t *testing.T) {
+	err := user.Create(gdb, "John", "Doe", "jdoe@domain.com", "p@$$W0rD")
+	if err != nil {
+		t.Error("could not create user:", err)
+	}
+
+	u, err := user.ByEmail(gdb, "jdoe@domain.com")
+	if err != nil {
+		t.Fatal("could not retrieve user:", err)
+	}
+
+	// Link the subject
+	if err := identity.Create(gdb, u.ID, "test", "248289761001"); err != nil {
+		t.Fatal("could not create link:", err)
+	}
+
+	item, err := identity.BySubject(gdb, "test", "248289761001")
+	if err != nil {
+		t.Fatal("could not retrieve link:", err)
+	} else if item.UserID != u.ID {
+		t.Errorf("retrieved wrong link: got '%v' want '%v'", item.UserID, u.ID)
+	}
+
+	// The subject can only be linked once
+	if err := identity.Create(gdb, u.ID, "test", "248289761001"); err == nil {
+		t.Error("subject should only be linked once")
+	}
+
+	items, err := identity.ByUserID(gdb, u.ID)
+	if err != nil {
+		t.Fatal("could not retrieve links:", err)
+	} else if len(items) != 1 {
+		t.Errorf("retrieved wrong links: got '%v' want '%v'", len(items), 1)
+	}
+
+	// Remove the link
+	if err := identity.Delete(gdb, u.ID, "test"); err != nil {
+		t.Fatal("could not delete link:", err)
+	}
+
+	_, err = identity.BySubject(gdb, "test", "248289761001")
+	if err != model.ErrNoResult {
+		t.Error("link should be removed:", err)
+	}
+}
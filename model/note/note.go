@@ -2,9 +2,9 @@
 package note
 
 import (
-	"fmt"
+	"strconv"
 
-	database "github.com/pcieslar/goforge/core/storage/driver/gorm"
+	"github.com/pcieslar/goforge/lib/gorm"
 	"github.com/pcieslar/goforge/model"
 
 	"github.com/go-sql-driver/mysql"
@@ -31,62 +31,67 @@ func (Note) TableName() string {
 }
 
 // ByID gets an item by ID.
-func ByID(ID string, userID string) (Note, bool, error) {
+func ByID(db *gorm.DB, ID int, userID string) (Note, bool, error) {
 	result := Note{}
-	err := model.StandardError(database.SQL.Where("user_id = ?", userID).
-		First(&result).Error)
+	err := model.StandardError(db.Where("user_id = ?", userID).
+		Where("id = ?", ID).First(&result).Error)
 	return result, err == model.ErrNoResult, err
 }
 
 // ByUserID gets all items for a user.
-func ByUserID(userID string) ([]Note, bool, error) {
+func ByUserID(db *gorm.DB, userID string) ([]Note, bool, error) {
 	var result []Note
-	err := model.StandardError(database.SQL.Where("user_id = ?", userID).
+	err := model.StandardError(db.Where("user_id = ?", userID).
 		Find(&result).Error)
 	return result, err == model.ErrNoResult, err
 }
 
 // ByUserIDPaginate gets items for a user based on page and max variables.
-func ByUserIDPaginate(userID string, max int, page int) ([]Note, bool, error) {
+func ByUserIDPaginate(db *gorm.DB, userID string, max int, page int) ([]Note, bool, error) {
 	var result []Note
-	err := model.StandardError(database.SQL.Limit(max).Offset(page).Where("user_id = ?", userID).
+	err := model.StandardError(db.Limit(max).Offset(page).Where("user_id = ?", userID).
 		Find(&result).Error)
 	return result, err == model.ErrNoResult, err
 }
 
 // ByUserIDCount counts the number of items for a user.
-func ByUserIDCount(userID string) (int, error) {
+func ByUserIDCount(db *gorm.DB, userID string) (int, error) {
 	var result int
-	err := model.StandardError(database.SQL.Model(Note{}).Where("user_id = ?", userID).
+	err := model.StandardError(db.Model(Note{}).Where("user_id = ?", userID).
 		Count(&result).Error)
 	return result, err
 }
 
-// Create adds an item.
-func Create(name string, userID string) error {
-	return model.StandardError(database.SQL.Exec(fmt.Sprintf(`
-		INSERT INTO %v
-		(name, user_id)
-		VALUES
-		(?,?)
-		`, table),
-		name, userID).Error)
+// Create adds an item and returns it.
+func Create(db *gorm.DB, name string, userID string) (Note, error) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return Note{}, err
+	}
+
+	item := Note{
+		Name:   name,
+		UserID: uint32(id),
+	}
+
+	err = model.StandardError(db.Create(&item).Error)
+	return item, err
 }
 
 // Update makes changes to an existing item.
-func Update(name string, ID string, userID string) error {
-	return model.StandardError(database.SQL.Model(Note{}).Where("user_id = ?", userID).
-		Update("name", name).Error)
+func Update(db *gorm.DB, name string, ID int, userID string) error {
+	return model.StandardError(db.Model(Note{}).Where("user_id = ?", userID).
+		Where("id = ?", ID).Update("name", name).Error)
 }
 
 // DeleteHard removes an item.
-func DeleteHard(ID string, userID string) error {
-	return model.StandardError(database.SQL.Unscoped().Where("user_id = ?", userID).
+func DeleteHard(db *gorm.DB, ID int, userID string) error {
+	return model.StandardError(db.Unscoped().Where("user_id = ?", userID).
 		Where("id = ?", ID).Delete(Note{}).Error)
 }
 
 // DeleteSoft marks an item as removed.
-func DeleteSoft(ID string, userID string) error {
-	return model.StandardError(database.SQL.Where("user_id = ?", userID).
+func DeleteSoft(db *gorm.DB, ID int, userID string) error {
+	return model.StandardError(db.Where("user_id = ?", userID).
 		Where("id = ?", ID).Delete(Note{}).Error)
 }
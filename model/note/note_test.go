@@ -5,15 +5,21 @@ import (
 	"os"
 	"testing"
 
+	"github.com/pcieslar/goforge/lib/gorm"
+	"github.com/pcieslar/goforge/model"
 	"github.com/pcieslar/goforge/model/note"
 	"github.com/pcieslar/goforge/model/user"
+
 	"github.com/pcieslar/goforge/core/storage/migration/mysql"
 
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/mysql"
+
 	"github.com/jmoiron/sqlx"
 )
 
 var (
-	db *sqlx.DB
+	db  *sqlx.DB
+	gdb *gorm.DB
 )
 
 // TestMain runs setup, tests, and then teardown.
@@ -30,6 +36,9 @@ func setup() {
 
 	// Connect to the database
 	db, _ = conf.Connect(true)
+
+	// Share the connection with GORM
+	gdb, _ = gorm.Open("mysql", db.DB)
 }
 
 // teardown handles any clean up tasks.
@@ -42,36 +51,35 @@ func TestComplete(t *testing.T) {
 	data := "Test data."
 	dataNew := "New test data."
 
-	result, err := user.Create(db, "John", "Doe", "jdoe@domain.com", "p@$$W0rD")
+	err := user.Create(gdb, "John", "Doe", "jdoe@domain.com", "p@$$W0rD")
 	if err != nil {
 		t.Error("could not create user:", err)
 	}
 
-	uID, err := result.LastInsertId()
+	u, err := user.ByEmail(gdb, "jdoe@domain.com")
 	if err != nil {
-		t.Error("could not convert user ID:", err)
+		t.Error("could not retrieve user:", err)
 	}
 
 	// Convert ID to string
-	userID := fmt.Sprintf("%v", uID)
+	userID := fmt.Sprintf("%v", u.ID)
 
 	// Create a record
-	result, err = note.Create(db, data, userID)
+	_, err = note.Create(gdb, data, userID)
 	if err != nil {
 		t.Error("could not create record:", err)
 	}
 
 	// Get the last ID
-	ID, err := result.LastInsertId()
-	if err != nil {
-		t.Error("could not convert ID:", err)
+	items, _, err := note.ByUserID(gdb, userID)
+	if err != nil || len(items) != 1 {
+		t.Fatal("could not retrieve records:", err)
 	}
 
-	// Convert ID to string
-	lastID := fmt.Sprintf("%v", ID)
+	lastID := int(items[0].ID)
 
 	// Select a record
-	record, _, err := note.ByID(db, lastID, userID)
+	record, _, err := note.ByID(gdb, lastID, userID)
 	if err != nil {
 		t.Error("could not retrieve record:", err)
 	} else if record.Name != data {
@@ -79,13 +87,13 @@ func TestComplete(t *testing.T) {
 	}
 
 	// Update a record
-	result, err = note.Update(db, dataNew, lastID, userID)
+	err = note.Update(gdb, dataNew, lastID, userID)
 	if err != nil {
 		t.Error("could not update record:", err)
 	}
 
 	// Select a record
-	record, _, err = note.ByID(db, lastID, userID)
+	record, _, err = note.ByID(gdb, lastID, userID)
 	if err != nil {
 		t.Error("could not retrieve record:", err)
 	} else if record.Name != dataNew {
@@ -93,16 +101,14 @@ func TestComplete(t *testing.T) {
 	}
 
 	// Delete a record by ID
-	result, err = note.DeleteSoft(db, lastID, userID)
+	err = note.DeleteSoft(gdb, lastID, userID)
 	if err != nil {
 		t.Error("could not delete record:", err)
 	}
 
-	// Count the number of deleted rows
-	rows, err := result.RowsAffected()
-	if err != nil {
-		t.Error("could not count affected rows:", err)
-	} else if rows != 1 {
-		t.Error("incorrect number of affected rows:", rows)
+	// The record should no longer be found
+	_, missing, err := note.ByID(gdb, lastID, userID)
+	if !missing || err != model.ErrNoResult {
+		t.Error("record should be deleted:", err)
 	}
 }
@@ -0,0 +1,96 @@
+// Package passwordreset provides access to the password_reset table in the
+// MySQL database. Only the SHA-256 hash of each token is stored and a token
+// can be used once before it expires.
+package passwordreset
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/gorm"
+	"github.com/pcieslar/goforge/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// tokenBytes is the number of random bytes in a token.
+const tokenBytes = 32
+
+// PasswordReset table.
+type PasswordReset struct {
+	ID        uint32         `db:"id"`
+	TokenHash string         `db:"token_hash"`
+	UserID    uint32         `db:"user_id"`
+	ExpiresAt time.Time      `db:"expires_at"`
+	CreatedAt mysql.NullTime `db:"created_at"`
+	UpdatedAt mysql.NullTime `db:"updated_at"`
+	DeletedAt mysql.NullTime `db:"deleted_at"`
+}
+
+// TableName for password_reset table.
+func (PasswordReset) TableName() string {
+	return "password_reset"
+}
+
+// Hash returns the hex encoded SHA-256 hash of the token.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create issues a token for the user that expires after the lifetime. The
+// plain text token is only available from the return value.
+func Create(db *gorm.DB, userID uint32, lifetime time.Duration) (string, PasswordReset, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", PasswordReset{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	item := PasswordReset{
+		TokenHash: Hash(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(lifetime),
+	}
+
+	err := model.StandardError(db.Create(&item).Error)
+	return token, item, err
+}
+
+// ByToken gets the token from the plain text value if it has not been used
+// and has not expired.
+func ByToken(db *gorm.DB, token string) (PasswordReset, error) {
+	result := PasswordReset{}
+	return result, model.StandardError(db.Where("token_hash = ?", Hash(token)).
+		Where("expires_at > ?", time.Now()).
+		First(&result).Error)
+}
+
+// Use removes the token so it cannot be used again and returns it. Only one
+// of the requests using the same token at the same time succeeds, the others
+// get model.ErrNoResult.
+func Use(db *gorm.DB, token string) (PasswordReset, error) {
+	item, err := ByToken(db, token)
+	if err != nil {
+		return item, err
+	}
+
+	result := db.Where("id = ?", item.ID).Delete(PasswordReset{})
+	if result.Error != nil {
+		return item, result.Error
+	} else if result.RowsAffected == 0 {
+		return item, model.ErrNoResult
+	}
+
+	return item, nil
+}
+
+// Invalidate removes every token of the user. Call it when the password
+// changes so a link sent before cannot change it again.
+func Invalidate(db *gorm.DB, userID uint32) error {
+	return model.StandardError(db.Where("user_id = ?", userID).
+		Delete(PasswordReset{}).Error)
+}
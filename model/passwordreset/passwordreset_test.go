@@ -0,0 +1,138 @@
+package passwordreset_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/gorm"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/passwordreset"
+	"github.com/pcieslar/goforge/model/user"
+
+	"github.com/pcieslar/goforge/core/storage/migration/mysql"
+
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	db  *sqlx.DB
+	gdb *gorm.DB
+)
+
+// TestMain runs setup, tests, and then teardown.
+func TestMain(m *testing.M) {
+	setup()
+	returnCode := m.Run()
+	teardown()
+	os.Exit(returnCode)
+}
+
+// setup handles any start up tasks.
+func setup() {
+	_, conf := mysql.SetUp("../../env.json.example", "database_test")
+
+	// Connect to the database
+	db, _ = conf.Connect(true)
+
+	// Share the connection with GORM
+	gdb, _ = gorm.Open("mysql", db.DB)
+}
+
+// teardown handles any clean up tasks.
+func teardown() {
+	mysql.TearDown(db, "database_test")
+}
+
+// TestComplete
+func TestComplete(t *testing.T) {
+	err := user.Create(gdb, "John", "Doe", "jdoe@domain.com", "p@$$W0rD")
+	if err != nil {
+		t.Error("could not create user:", err)
+	}
+
+	u, err := user.ByEmail(gdb, "jdoe@domain.com")
+	if err != nil {
+		t.Fatal("could not retrieve user:", err)
+	}
+
+	// Issue a token
+	token, item, err := passwordreset.Create(gdb, u.ID, time.Hour)
+	if err != nil {
+		t.Fatal("could not create token:", err)
+	}
+
+	// Only the hash should be stored
+	if item.TokenHash == token || item.TokenHash != passwordreset.Hash(token) {
+		t.Errorf("token should be stored hashed: got '%v'", item.TokenHash)
+	}
+
+	// Find the token
+	found, err := passwordreset.ByToken(gdb, token)
+	if err != nil {
+		t.Fatal("could not retrieve token:", err)
+	} else if found.UserID != u.ID {
+		t.Errorf("retrieved wrong token: got '%v' want '%v'", found.UserID, u.ID)
+	}
+
+	// Use the token
+	used, err := passwordreset.Use(gdb, token)
+	if err != nil {
+		t.Fatal("could not use token:", err)
+	} else if used.UserID != u.ID {
+		t.Errorf("used wrong token: got '%v' want '%v'", used.UserID, u.ID)
+	}
+
+	// The token can only be used once
+	_, err = passwordreset.Use(gdb, token)
+	if err != model.ErrNoResult {
+		t.Error("token should be used:", err)
+	}
+}
+
+// TestExpired ensures an expired token is not found.
+func TestExpired(t *testing.T) {
+	u, err := user.ByEmail(gdb, "jdoe@domain.com")
+	if err != nil {
+		t.Fatal("could not retrieve user:", err)
+	}
+
+	token, _, err := passwordreset.Create(gdb, u.ID, -time.Minute)
+	if err != nil {
+		t.Fatal("could not create token:", err)
+	}
+
+	_, err = passwordreset.ByToken(gdb, token)
+	if err != model.ErrNoResult {
+		t.Error("token should be expired:", err)
+	}
+}
+
+// TestInvalidate ensures every token of the user is removed.
+func TestInvalidate(t *testing.T) {
+	u, err := user.ByEmail(gdb, "jdoe@domain.com")
+	if err != nil {
+		t.Fatal("could not retrieve user:", err)
+	}
+
+	first, _, err := passwordreset.Create(gdb, u.ID, time.Hour)
+	if err != nil {
+		t.Fatal("could not create token:", err)
+	}
+	second, _, err := passwordreset.Create(gdb, u.ID, time.Hour)
+	if err != nil {
+		t.Fatal("could not create token:", err)
+	}
+
+	if err := passwordreset.Invalidate(gdb, u.ID); err != nil {
+		t.Fatal("could not invalidate tokens:", err)
+	}
+
+	for _, token := range []string{first, second} {
+		if _, err := passwordreset.ByToken(gdb, token); err != model.ErrNoResult {
+			t.Error("token should be removed:", err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Package twofactor provides access to the two_factor and recovery_code
+// tables in the MySQL database. The TOTP secret of a user is kept until the
+// user turns off two-factor authentication and only the bcrypt hash of each
+// recovery code is stored.
+package twofactor
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/gorm"
+	"github.com/pcieslar/goforge/model"
+
+	"github.com/pcieslar/goforge/core/passhash"
+	"github.com/pcieslar/goforge/core/totp"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// RecoveryCodes is the number of recovery codes issued at a time.
+	RecoveryCodes = 10
+
+	// recoveryBytes is the number of random bytes in a recovery code.
+	recoveryBytes = 5
+)
+
+var (
+	// ErrEnabled is when two-factor authentication is already turned on.
+	ErrEnabled = errors.New("Two-factor authentication is already enabled.")
+)
+
+// recoveryEncoding writes the recovery codes in lower case without padding.
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").
+	WithPadding(base32.NoPadding)
+
+// TwoFactor table.
+type TwoFactor struct {
+	ID          uint32         `db:"id"`
+	UserID      uint32         `db:"user_id"`
+	Secret      string         `db:"secret"`
+	LastCounter int64          `db:"last_counter"`
+	EnabledAt   mysql.NullTime `db:"enabled_at"`
+	CreatedAt   mysql.NullTime `db:"created_at"`
+	UpdatedAt   mysql.NullTime `db:"updated_at"`
+}
+
+// TableName for two_factor table.
+func (TwoFactor) TableName() string {
+	return "two_factor"
+}
+
+// Enabled returns true if the secret was confirmed with a code.
+func (t TwoFactor) Enabled() bool {
+	return t.EnabledAt.Valid
+}
+
+// RecoveryCode table.
+type RecoveryCode struct {
+	ID        uint32         `db:"id"`
+	UserID    uint32         `db:"user_id"`
+	CodeHash  string         `db:"code_hash"`
+	CreatedAt mysql.NullTime `db:"created_at"`
+	UpdatedAt mysql.NullTime `db:"updated_at"`
+	DeletedAt mysql.NullTime `db:"deleted_at"`
+}
+
+// TableName for recovery_code table.
+func (RecoveryCode) TableName() string {
+	return "recovery_code"
+}
+
+// ByUserID gets the secret of the user whether it is confirmed or not.
+func ByUserID(db *gorm.DB, userID uint32) (TwoFactor, error) {
+	result := TwoFactor{}
+	return result, model.StandardError(db.Where("user_id = ?", userID).
+		First(&result).Error)
+}
+
+// Enabled returns true if the user must enter a code to log in.
+func Enabled(db *gorm.DB, userID uint32) (bool, error) {
+	result, err := ByUserID(db, userID)
+	if err == model.ErrNoResult {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return result.Enabled(), nil
+}
+
+// Begin stores a new secret for the user that is not used to log in until it
+// is confirmed with Enable. A secret that was not confirmed is replaced.
+func Begin(db *gorm.DB, userID uint32, secret string) error {
+	enabled, err := Enabled(db, userID)
+	if err != nil {
+		return err
+	} else if enabled {
+		return ErrEnabled
+	}
+
+	err = db.Where("user_id = ?", userID).
+		Where("enabled_at IS NULL").
+		Delete(TwoFactor{}).Error
+	if err != nil {
+		return model.StandardError(err)
+	}
+
+	item := &TwoFactor{
+		UserID: userID,
+		Secret: secret,
+	}
+	return model.StandardError(db.Create(item).Error)
+}
+
+// Enable turns on two-factor authentication after the secret is confirmed
+// with the code of the counter. Returns model.ErrNoResult when there is no
+// secret waiting for confirmation.
+func Enable(db *gorm.DB, userID uint32, counter int64) error {
+	result := db.Model(&TwoFactor{}).Where("user_id = ?", userID).
+		Where("enabled_at IS NULL").
+		Updates(map[string]interface{}{
+			"enabled_at":   time.Now(),
+			"last_counter": counter,
+		})
+	if result.Error != nil {
+		return model.StandardError(result.Error)
+	} else if result.RowsAffected == 0 {
+		return model.ErrNoResult
+	}
+
+	return nil
+}
+
+// UseCounter stores the counter of a code used to log in. Returns
+// model.ErrNoResult when the counter is not newer than the last one so a
+// code cannot be used twice.
+func UseCounter(db *gorm.DB, userID uint32, counter int64) error {
+	result := db.Model(&TwoFactor{}).Where("user_id = ?", userID).
+		Where("enabled_at IS NOT NULL").
+		Where("last_counter < ?", counter).
+		Update("last_counter", counter)
+	if result.Error != nil {
+		return model.StandardError(result.Error)
+	} else if result.RowsAffected == 0 {
+		return model.ErrNoResult
+	}
+
+	return nil
+}
+
+// Disable removes the secret and the recovery codes of the user.
+func Disable(db *gorm.DB, userID uint32) error {
+	err := db.Where("user_id = ?", userID).Delete(TwoFactor{}).Error
+	if err != nil {
+		return model.StandardError(err)
+	}
+
+	return model.StandardError(db.Where("user_id = ?", userID).
+		Delete(RecoveryCode{}).Error)
+}
+
+// normalize removes the separators and spaces from a recovery code.
+func normalize(code string) string {
+	code = strings.Replace(code, "-", "", -1)
+	code = strings.Replace(code, " ", "", -1)
+	return strings.ToLower(code)
+}
+
+// CreateRecoveryCodes replaces the recovery codes of the user. The plain text
+// codes are only available from the return value.
+func CreateRecoveryCodes(db *gorm.DB, userID uint32) ([]string, error) {
+	err := db.Where("user_id = ?", userID).Delete(RecoveryCode{}).Error
+	if err != nil {
+		return nil, model.StandardError(err)
+	}
+
+	codes := make([]string, 0, RecoveryCodes)
+	for n := 0; n < RecoveryCodes; n++ {
+		b := make([]byte, recoveryBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := recoveryEncoding.EncodeToString(b)
+
+		hash, err := passhash.HashString(code)
+		if err != nil {
+			return nil, err
+		}
+
+		item := &RecoveryCode{
+			UserID:   userID,
+			CodeHash: hash,
+		}
+		if err := db.Create(item).Error; err != nil {
+			return nil, model.StandardError(err)
+		}
+
+		codes = append(codes, code[:len(code)/2]+"-"+code[len(code)/2:])
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode removes the recovery code of the user so it cannot be used
+// again. Returns model.ErrNoResult when the code does not match one that is
+// left.
+func UseRecoveryCode(db *gorm.DB, userID uint32, code string) error {
+	code = normalize(code)
+	if len(code) != recoveryEncoding.EncodedLen(recoveryBytes) {
+		return model.ErrNoResult
+	}
+
+	var items []RecoveryCode
+	err := db.Where("user_id = ?", userID).Find(&items).Error
+	if err != nil {
+		return model.StandardError(err)
+	}
+
+	for _, item := range items {
+		if !passhash.MatchString(item.CodeHash, code) {
+			continue
+		}
+
+		result := db.Where("id = ?", item.ID).Delete(RecoveryCode{})
+		if result.Error != nil {
+			return model.StandardError(result.Error)
+		} else if result.RowsAffected == 0 {
+			return model.ErrNoResult
+		}
+		return nil
+	}
+
+	return model.ErrNoResult
+}
+
+// RecoveryCodesLeft returns the number of recovery codes the user has not
+// used.
+func RecoveryCodesLeft(db *gorm.DB, userID uint32) (int, error) {
+	var count int
+	err := db.Model(&RecoveryCode{}).Where("user_id = ?", userID).
+		Count(&count).Error
+	return count, model.StandardError(err)
+}
+
+// Authenticate checks the code from the authenticator app or a recovery code
+// of the user at the time. Returns true if a recovery code was used and
+// totp.ErrInvalid if the code does not match or was used before.
+func Authenticate(db *gorm.DB, o totp.Info, userID uint32, code string, now time.Time) (bool, error) {
+	item, err := ByUserID(db, userID)
+	if err == model.ErrNoResult || (err == nil && !item.Enabled()) {
+		return false, totp.ErrInvalid
+	} else if err != nil {
+		return false, err
+	}
+
+	counter, err := o.Validate(item.Secret, code, now)
+	if err == nil {
+		err = UseCounter(db, userID, counter)
+		if err == model.ErrNoResult {
+			return false, totp.ErrInvalid
+		}
+		return false, err
+	} else if err != totp.ErrInvalid {
+		return false, err
+	}
+
+	err = UseRecoveryCode(db, userID, code)
+	if err == model.ErrNoResult {
+		return false, totp.ErrInvalid
+	}
+	return err == nil, err
+}
@@ -0,0 +1,146 @@
+package twofactor_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pcieslar/goforge/lib/gorm"
+	"github.com/pcieslar/goforge/model"
+	"github.com/pcieslar/goforge/model/twofactor"
+	"github.com/pcieslar/goforge/model/user"
+
+	"github.com/pcieslar/goforge/core/storage/migration/mysql"
+	"github.com/pcieslar/goforge/core/totp"
+
+	_ "github.com/pcieslar/goforge/lib/gorm/dialects/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	db  *sqlx.DB
+	gdb *gorm.DB
+)
+
+// TestMain runs setup, tests, and then teardown.
+func TestMain(m *testing.M) {
+	setup()
+	returnCode := m.Run()
+	teardown()
+	os.Exit(returnCode)
+}
+
+// setup handles any start up tasks.
+func setup() {
+	_, conf := mysql.SetUp("../../env.json.example", "database_test")
+
+	// Connect to the database
+	db, _ = conf.Connect(true)
+
+	// Share the connection with GORM
+	gdb, _ = gorm.Open("mysql", db.DB)
+}
+
+// teardown handles any clean up tasks.
+func teardown() {
+	mysql.TearDown(db, "database_test")
+}
+
+// TestComplete
+func TestComplete(t *testing.T) {
+	err := user.Create(gdb, "John", "Doe", "jdoe@domain.com", "p@$$W0rD")
+	if err != nil {
+		t.Error("could not create user:", err)
+	}
+
+	u, err := user.ByEmail(gdb, "jdoe@domain.com")
+	if err != nil {
+		t.Fatal("could not retrieve user:", err)
+	}
+
+	o := totp.Info{Digits: 6, Period: 30, Skew: 1}
+	now := time.Unix(1111111111, 0)
+
+	secret, err := totp.Secret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The secret is not used until it is confirmed
+	if err := twofactor.Begin(gdb, u.ID, secret); err != nil {
+		t.Fatal("could not begin:", err)
+	}
+	if enabled, err := twofactor.Enabled(gdb, u.ID); err != nil || enabled {
+		t.Errorf("should not be enabled: %v %v", enabled, err)
+	}
+
+	code, _ := o.Code(secret, now)
+	counter, err := o.Validate(secret, code, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := twofactor.Enable(gdb, u.ID, counter); err != nil {
+		t.Fatal("could not enable:", err)
+	}
+	if err := twofactor.Begin(gdb, u.ID, secret); err != twofactor.ErrEnabled {
+		t.Errorf("\nactual: %v\nexpected: %v", err, twofactor.ErrEnabled)
+	}
+
+	// The code used to confirm cannot be used again
+	if _, err := twofactor.Authenticate(gdb, o, u.ID, code, now); err != totp.ErrInvalid {
+		t.Errorf("\nactual: %v\nexpected: %v", err, totp.ErrInvalid)
+	}
+
+	// The next code works once
+	later := now.Add(30 * time.Second)
+	code, _ = o.Code(secret, later)
+	if recovery, err := twofactor.Authenticate(gdb, o, u.ID, code, later); err != nil || recovery {
+		t.Errorf("code should be accepted: %v %v", recovery, err)
+	}
+	if _, err := twofactor.Authenticate(gdb, o, u.ID, code, later); err != totp.ErrInvalid {
+		t.Errorf("\nactual: %v\nexpected: %v", err, totp.ErrInvalid)
+	}
+}
+
+// TestRecoveryCodes ensures each recovery code works once.
+func TestRecoveryCodes(t *testing.T) {
+	u, err := user.ByEmail(gdb, "jdoe@domain.com")
+	if err != nil {
+		t.Fatal("could not retrieve user:", err)
+	}
+
+	codes, err := twofactor.CreateRecoveryCodes(gdb, u.ID)
+	if err != nil {
+		t.Fatal("could not create codes:", err)
+	}
+	if len(codes) != twofactor.RecoveryCodes {
+		t.Fatalf("\nactual: %v\nexpected: %v", len(codes), twofactor.RecoveryCodes)
+	}
+
+	o := totp.Info{}
+	if recovery, err := twofactor.Authenticate(gdb, o, u.ID, codes[0], time.Now()); err != nil || !recovery {
+		t.Errorf("recovery code should be accepted: %v %v", recovery, err)
+	}
+	if _, err := twofactor.Authenticate(gdb, o, u.ID, codes[0], time.Now()); err != totp.ErrInvalid {
+		t.Errorf("\nactual: %v\nexpected: %v", err, totp.ErrInvalid)
+	}
+
+	left, err := twofactor.RecoveryCodesLeft(gdb, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if left != twofactor.RecoveryCodes-1 {
+		t.Errorf("\nactual: %v\nexpected: %v", left, twofactor.RecoveryCodes-1)
+	}
+
+	// Turning it off removes the secret and the codes
+	if err := twofactor.Disable(gdb, u.ID); err != nil {
+		t.Fatal("could not disable:", err)
+	}
+	if _, err := twofactor.ByUserID(gdb, u.ID); err != model.ErrNoResult {
+		t.Errorf("\nactual: %v\nexpected: %v", err, model.ErrNoResult)
+	}
+	if left, _ := twofactor.RecoveryCodesLeft(gdb, u.ID); left != 0 {
+		t.Errorf("\nactual: %v\nexpected: %v", left, 0)
+	}
+}
@@ -2,11 +2,11 @@
 package user
 
 import (
-	database "github.com/pcieslar/goforge/core/storage/driver/gorm"
-
-	"github.com/go-sql-driver/mysql"
+	"github.com/pcieslar/goforge/lib/gorm"
 	"github.com/pcieslar/goforge/model"
 	"github.com/pcieslar/goforge/model/userstatus"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 // User table.
@@ -29,20 +29,55 @@ func (User) TableName() string {
 }
 
 // ByEmail gets user information from email.
-func ByEmail(email string) (User, error) {
+func ByEmail(db *gorm.DB, email string) (User, error) {
 	result := User{}
-	return result, model.StandardError(database.SQL.Where("email = ?", email).
+	return result, model.StandardError(db.Where("email = ?", email).
 		First(&result).Error)
 }
 
-// Create creates user.
-func Create(firstName, lastName, email, password string) error {
+// Create creates an active user.
+func Create(db *gorm.DB, firstName, lastName, email, password string) error {
+	return CreateWithStatus(db, firstName, lastName, email, password, userstatus.Active)
+}
+
+// CreateWithStatus creates user with the status like userstatus.Pending.
+func CreateWithStatus(db *gorm.DB, firstName, lastName, email, password string, statusID uint8) error {
 	item := &User{
 		FirstName: firstName,
 		LastName:  lastName,
 		Email:     email,
 		Password:  password,
-		StatusID:  1,
+		StatusID:  statusID,
+	}
+	return model.StandardError(db.Create(item).Error)
+}
+
+// ByID gets user information from ID.
+func ByID(db *gorm.DB, ID uint32) (User, error) {
+	result := User{}
+	return result, model.StandardError(db.Where("id = ?", ID).
+		First(&result).Error)
+}
+
+// UpdatePassword replaces the password hash of the user. Call
+// passwordreset.Invalidate too so the reset links sent before are removed.
+func UpdatePassword(db *gorm.DB, ID uint32, password string) error {
+	return model.StandardError(db.Model(&User{}).Where("id = ?", ID).
+		Update("password", password).Error)
+}
+
+// Verify activates the user when the email address is waiting for
+// verification. Returns model.ErrNoResult when the user is not pending so a
+// link cannot activate a user that was disabled.
+func Verify(db *gorm.DB, ID uint32) error {
+	result := db.Model(&User{}).Where("id = ?", ID).
+		Where("status_id = ?", userstatus.Pending).
+		Update("status_id", userstatus.Active)
+	if result.Error != nil {
+		return model.StandardError(result.Error)
+	} else if result.RowsAffected == 0 {
+		return model.ErrNoResult
 	}
-	return model.StandardError(database.SQL.Create(item).Error)
+
+	return nil
 }
@@ -5,6 +5,13 @@ import (
 	"time"
 )
 
+// The IDs of the rows in the user_status table.
+const (
+	Active   uint8 = 1 // Can log in
+	Inactive uint8 = 2 // Login is disabled
+	Pending  uint8 = 3 // Email address is not verified yet
+)
+
 // UserStatus table.
 type UserStatus struct {
 	ID        uint8     `db:"id"`
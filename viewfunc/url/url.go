@@ -0,0 +1,28 @@
+// Package url provides a funcmap for html/template to build the path of a
+// named route.
+package url
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/pcieslar/goforge/core/router"
+)
+
+// Map returns a template.FuncMap for URL that returns the path of a named
+// route with the URL parameters replaced:
+//   {{URL "notepad.edit" "id" .ID}}
+func Map(baseURI string) template.FuncMap {
+	f := make(template.FuncMap)
+
+	f["URL"] = func(name string, params ...interface{}) (string, error) {
+		path, err := router.URL(name, params...)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSuffix(baseURI, "/") + path, nil
+	}
+
+	return f
+}
@@ -1,15 +1,18 @@
-// Package authlevel adds an AuthLevel variable to the view template.
+// Package authlevel adds the AuthLevel and IsAdmin variables to the view
+// template.
 package authlevel
 
 import (
 	"net/http"
 
 	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/middleware/acl"
 	"github.com/pcieslar/goforge/core/view"
 )
 
 // Modify sets AuthLevel in the template to auth if the user is authenticated.
-// Sets AuthLevel to anon if not authenticated.
+// Sets AuthLevel to anon if not authenticated. Sets IsAdmin to true if the
+// user is in the Admins list of the config.
 func Modify(w http.ResponseWriter, r *http.Request, v *view.Info) {
 	c := flight.Context(w, r)
 
@@ -19,4 +22,6 @@ func Modify(w http.ResponseWriter, r *http.Request, v *view.Info) {
 	} else {
 		v.Vars["AuthLevel"] = "anon"
 	}
+
+	v.Vars["IsAdmin"] = acl.IsAdmin(c.Config.Admins, c.Sess.Values["email"])
 }
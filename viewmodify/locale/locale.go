@@ -0,0 +1,18 @@
+// Package locale adds the locale of the request to the view template.
+package locale
+
+import (
+	"net/http"
+
+	"github.com/pcieslar/goforge/lib/flight"
+	"github.com/pcieslar/goforge/core/view"
+)
+
+// Modify sets locale in the template so the T and TN funcs translate to it.
+// Sets locales to the supported locales for a language menu.
+func Modify(w http.ResponseWriter, r *http.Request, v *view.Info) {
+	c := flight.Context(w, r)
+
+	v.Vars["locale"] = c.Locale
+	v.Vars["locales"] = c.I18n.Locales()
+}